@@ -0,0 +1,308 @@
+package src
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var changesMutex sync.Mutex
+
+// Änderungen einer Playlist zwischen der lokalen Kopie und der neuen Datei vom Provider speichern
+func recordPlaylistChanges(fileID, fileType string, previous, current []interface{}) {
+
+	var report = diffPlaylist(previous, current)
+	if len(report.Changes) == 0 {
+		return
+	}
+
+	report.ID = randomString(16)
+	report.FileID = fileID
+	report.FileType = fileType
+	report.FileName = getProviderParameter(fileID, fileType, "name")
+	report.Time = time.Now().Format("2006-01-02 15:04:05")
+	report.Status = "pending"
+
+	changesMutex.Lock()
+	defer changesMutex.Unlock()
+
+	reports, err := loadPlaylistChanges()
+	if err != nil {
+		ShowError(err, 000)
+		return
+	}
+
+	reports = append(reports, report)
+
+	err = savePlaylistChanges(reports)
+	if err != nil {
+		ShowError(err, 000)
+		return
+	}
+
+	var summary = make([]string, 0, len(report.Summary))
+	for _, changeType := range []string{"added", "removed", "renamed", "url", "group", "epg"} {
+		if count := report.Summary[changeType]; count > 0 {
+			summary = append(summary, fmt.Sprintf("%d %s", count, changeType))
+		}
+	}
+
+	showInfo(fmt.Sprintf("Playlist Changes:%s (%s)", report.FileName, strings.Join(summary, ", ")))
+	addNotification(Notification{Type: "info", Headline: "Playlist changes: " + report.FileName, Message: strings.Join(summary, ", ")})
+
+}
+
+// Kanäle der alten und neuen Playlist vergleichen.
+// Reihenfolge der Zuordnung: UUID, tvg-id, URL, Name
+func diffPlaylist(previous, current []interface{}) (report PlaylistChangeReport) {
+
+	report.Summary = make(map[string]int)
+	report.Changes = []PlaylistChange{}
+
+	var toStreams = func(channels []interface{}) (streams []map[string]string) {
+		for _, channel := range channels {
+			if stream, ok := channel.(map[string]string); ok {
+				streams = append(streams, stream)
+			}
+		}
+		return
+	}
+
+	var oldStreams, newStreams = toStreams(previous), toStreams(current)
+	var oldMatched = make([]bool, len(oldStreams))
+	var newMatched = make([]int, len(newStreams))
+
+	for i := range newMatched {
+		newMatched[i] = -1
+	}
+
+	for _, key := range []string{"_uuid.value", "tvg-id", "url", "name"} {
+
+		var index = make(map[string][]int)
+		for i, stream := range oldStreams {
+			if value := stream[key]; len(value) > 0 && !oldMatched[i] {
+				index[value] = append(index[value], i)
+			}
+		}
+
+		for i, stream := range newStreams {
+
+			if newMatched[i] != -1 || len(stream[key]) == 0 {
+				continue
+			}
+
+			for _, o := range index[stream[key]] {
+				if !oldMatched[o] {
+					oldMatched[o] = true
+					newMatched[i] = o
+					break
+				}
+			}
+
+		}
+
+	}
+
+	var add = func(change PlaylistChange) {
+		report.Changes = append(report.Changes, change)
+		report.Summary[change.Type]++
+	}
+
+	for i, stream := range newStreams {
+
+		if newMatched[i] == -1 {
+			add(PlaylistChange{Type: "added", Name: stream["name"], Group: stream["group-title"], New: stream["url"], URL: stream["url"]})
+			continue
+		}
+
+		var old = oldStreams[newMatched[i]]
+
+		var fields = []struct {
+			changeType, key string
+		}{
+			{"renamed", "name"},
+			{"url", "url"},
+			{"group", "group-title"},
+			{"epg", "tvg-id"},
+		}
+
+		for _, field := range fields {
+			if old[field.key] != stream[field.key] {
+				add(PlaylistChange{Type: field.changeType, Name: stream["name"], Group: stream["group-title"], Old: old[field.key], New: stream[field.key], URL: stream["url"]})
+			}
+		}
+
+	}
+
+	for i, stream := range oldStreams {
+		if !oldMatched[i] {
+			add(PlaylistChange{Type: "removed", Name: stream["name"], Group: stream["group-title"], Old: stream["url"], URL: stream["url"]})
+		}
+	}
+
+	return
+}
+
+// Offene Berichte nach dem Erstellen der XEPG Datenbank mit den betroffenen XEPG Kanälen ergänzen
+func applyPlaylistChanges() {
+
+	changesMutex.Lock()
+	defer changesMutex.Unlock()
+
+	reports, err := loadPlaylistChanges()
+	if err != nil {
+		ShowError(err, 000)
+		return
+	}
+
+	var pending bool
+	for _, report := range reports {
+		if report.Status == "pending" {
+			pending = true
+		}
+	}
+
+	if !pending {
+		return
+	}
+
+	// Index der XEPG Kanäle: Playlist ID + URL / Name
+	var xepgByURL = make(map[string]XEPGChannelStruct)
+	var xepgByName = make(map[string]XEPGChannelStruct)
+
+	for _, dxc := range Data.XEPG.Channels {
+
+		var xepgChannel XEPGChannelStruct
+		if err := json.Unmarshal([]byte(mapToJSON(dxc)), &xepgChannel); err != nil {
+			continue
+		}
+
+		xepgByURL[xepgChannel.FileM3UID+xepgChannel.URL] = xepgChannel
+		xepgByName[xepgChannel.FileM3UID+xepgChannel.Name] = xepgChannel
+
+	}
+
+	for r, report := range reports {
+
+		if report.Status != "pending" {
+			continue
+		}
+
+		for c, change := range report.Changes {
+
+			var xepgChannel, ok = xepgByURL[report.FileID+change.URL]
+			if !ok {
+				xepgChannel, ok = xepgByName[report.FileID+change.Name]
+			}
+
+			if ok {
+				change.XEPG = xepgChannel.XEPG
+				change.XName = xepgChannel.XName
+				change.Active = xepgChannel.XActive
+				report.Changes[c] = change
+			}
+
+		}
+
+		report.Status = "applied"
+		reports[r] = report
+
+	}
+
+	err = savePlaylistChanges(reports)
+	if err != nil {
+		ShowError(err, 000)
+	}
+
+}
+
+// Berichte ohne die einzelnen Änderungen (API: changes.list)
+func getPlaylistChanges() (reports []PlaylistChangeReport, err error) {
+
+	changesMutex.Lock()
+	defer changesMutex.Unlock()
+
+	reports, err = loadPlaylistChanges()
+	if err != nil {
+		return
+	}
+
+	for i := range reports {
+		reports[i].Changes = nil
+	}
+
+	return
+}
+
+// Vollständiger Bericht (API: changes.get)
+func getPlaylistChangeReport(id string) (report PlaylistChangeReport, err error) {
+
+	changesMutex.Lock()
+	defer changesMutex.Unlock()
+
+	reports, err := loadPlaylistChanges()
+	if err != nil {
+		return
+	}
+
+	for _, report := range reports {
+		if report.ID == id {
+			return report, nil
+		}
+	}
+
+	err = errors.New(getErrMsg(1019))
+	return
+}
+
+// changes.json laden, die Berichte sind nach Zeit sortiert (älteste zuerst)
+func loadPlaylistChanges() (reports []PlaylistChangeReport, err error) {
+
+	tmpMap, err := loadJSONFileToMap(System.File.Changes)
+	if err != nil {
+		return
+	}
+
+	var changes = make(map[string]PlaylistChangeReport)
+	err = json.Unmarshal([]byte(mapToJSON(tmpMap)), &changes)
+	if err != nil {
+		return
+	}
+
+	for _, report := range changes {
+		reports = append(reports, report)
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].Time == reports[j].Time {
+			return reports[i].ID < reports[j].ID
+		}
+		return reports[i].Time < reports[j].Time
+	})
+
+	return
+}
+
+// changes.json speichern, es werden nur die letzten Berichte behalten (changes.keep)
+func savePlaylistChanges(reports []PlaylistChangeReport) (err error) {
+
+	var keep = Settings.ChangesKeep
+	if keep <= 0 {
+		keep = 20
+	}
+
+	if len(reports) > keep {
+		reports = reports[len(reports)-keep:]
+	}
+
+	var changes = make(map[string]PlaylistChangeReport)
+	for _, report := range reports {
+		changes[report.ID] = report
+	}
+
+	return saveMapToJSONFile(System.File.Changes, changes)
+}
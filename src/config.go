@@ -25,7 +25,7 @@ var Settings SettingsStruct
 var Data DataStruct
 
 // SystemFiles : Alle Systemdateien
-var SystemFiles = []string{"authentication.json", "pms.json", "settings.json", "xepg.json", "urls.json"}
+var SystemFiles = []string{"authentication.json", "pms.json", "settings.json", "xepg.json", "urls.json", "changes.json", "matches.json", "probe.json", "shards.json", "programs.json"}
 
 // BufferInformation : Informationen über den Buffer (aktive Streams, maximale Streams)
 var BufferInformation sync.Map
@@ -44,6 +44,7 @@ var (
 	infoMutex   sync.Mutex
 	logMutex    sync.Mutex
 	systemMutex sync.Mutex
+	probeMutex  sync.Mutex
 )
 
 // Init : Systeminitialisierung
@@ -12,6 +12,8 @@ import (
 	"time"
 
 	"threadfin/src/internal/authentication"
+	"threadfin/src/internal/cron"
+	"threadfin/src/internal/filterexpr"
 	"threadfin/src/internal/imgcache"
 )
 
@@ -23,6 +25,7 @@ func updateServerSettings(request RequestStruct) (settings SettingsStruct, err e
 	var reloadData = false
 	var cacheImages = false
 	var createXEPGFiles = false
+	var restartDLNA = false
 	var debug string
 
 	// -vvv [URL] --sout '#transcode{vcodec=mp4v, acodec=mpga} :standard{access=http, mux=ogg}'
@@ -36,7 +39,7 @@ func updateServerSettings(request RequestStruct) (settings SettingsStruct, err e
 			case "tuner":
 				showWarning(2105)
 
-			case "epgSource":
+			case "epgSource", "lineup.shards":
 				reloadData = true
 
 			case "update":
@@ -66,6 +69,9 @@ func updateServerSettings(request RequestStruct) (settings SettingsStruct, err e
 			case "cache.images":
 				cacheImages = true
 
+			case "dlna", "ssdp":
+				restartDLNA = true
+
 			case "xepg.replace.missing.images":
 			case "xepg.replace.channel.title":
 				createXEPGFiles = true
@@ -116,6 +122,39 @@ func updateServerSettings(request RequestStruct) (settings SettingsStruct, err e
 			case "scheme.m3u", "scheme.xml":
 				createXEPGFiles = true
 
+			case "epg.window.past", "epg.window.future":
+				if v, ok := value.(float64); !ok || v < 0 {
+					err = errors.New(getErrMsg(1035))
+					return Settings, err
+				}
+
+				createXEPGFiles = true
+
+			case "ppv.lead", "ppv.lag":
+				if v, ok := value.(float64); !ok || v < 0 || v > 1440 {
+					err = errors.New(getErrMsg(1043))
+					return Settings, err
+				}
+
+			case "genre.normalize":
+				if v, ok := value.(string); !ok || indexOfString(v, []string{"off", "add", "replace"}) == -1 {
+					err = errors.New(getErrMsg(1045))
+					return Settings, err
+				}
+
+				createXEPGFiles = true
+
+			case "genre.infer":
+				createXEPGFiles = true
+
+			case "event.duration":
+				if v, ok := value.(float64); !ok || v < 0 || v > 1440 {
+					err = errors.New(getErrMsg(1042))
+					return Settings, err
+				}
+
+				createXEPGFiles = true
+
 			}
 
 			oldSettings[key] = value
@@ -205,7 +244,7 @@ func updateServerSettings(request RequestStruct) (settings SettingsStruct, err e
 
 		if cacheImages == true {
 
-			if Settings.EpgSource == "XEPG" && System.ImageCachingInProgress == 0 {
+			if Settings.EpgSource == "XEPG" {
 
 				Data.Cache.Images, err = imgcache.New(System.Folder.ImagesCache, fmt.Sprintf("%s://%s/images/", System.ServerProtocol.WEB, System.Domain), Settings.CacheImages)
 				if err != nil {
@@ -224,14 +263,7 @@ func updateServerSettings(request RequestStruct) (settings SettingsStruct, err e
 						createXMLTVFile()
 						createM3UFile()
 
-						System.ImageCachingInProgress = 1
-						showInfo("Image Caching:Images are cached")
-
-						Data.Cache.Images.Image.Caching()
-						showInfo("Image Caching:Done")
-
-						System.ImageCachingInProgress = 0
-
+						startImageCaching().wait()
 						buildXEPG(false)
 
 					}()
@@ -251,6 +283,10 @@ func updateServerSettings(request RequestStruct) (settings SettingsStruct, err e
 
 		}
 
+		if restartDLNA {
+			advertiseDLNA()
+		}
+
 	}
 
 	return
@@ -287,6 +323,61 @@ func saveFiles(request RequestStruct, fileType string) (err error) {
 
 	for dataID, data := range newData {
 
+		// Zeitplan für die Aktualisierung prüfen (Cron Ausdruck oder @every)
+		if spec, ok := data.(map[string]interface{})["update.schedule"].(string); ok && len(strings.TrimSpace(spec)) > 0 {
+
+			if _, err = cron.Parse(spec); err != nil {
+				err = fmt.Errorf("%s (%s)", getErrMsg(1015), err)
+				return
+			}
+
+		}
+
+		// Zeitkorrektur der XMLTV Datei prüfen
+		if tz, ok := data.(map[string]interface{})["epg.timezone"].(string); ok && len(strings.TrimSpace(tz)) > 0 {
+
+			if _, err = parseEPGTimezone(tz); err != nil {
+				err = fmt.Errorf("%s (%s)", getErrMsg(1034), err)
+				return
+			}
+
+		}
+
+		// Zahlen aus der API werden wie beim Mapping (x-epg-offset) als Text gespeichert und ebenfalls geprüft
+		if offset, ok := data.(map[string]interface{})["epg.offset"].(float64); ok {
+			data.(map[string]interface{})["epg.offset"] = strconv.Itoa(int(offset))
+		}
+
+		if offset, ok := data.(map[string]interface{})["epg.offset"].(string); ok {
+
+			if _, err = parseEPGOffset(offset); err != nil {
+				err = fmt.Errorf("%s (%s)", getErrMsg(1033), err)
+				return
+			}
+
+		}
+
+		// EPG Zeitfenster der XMLTV Datei prüfen (Stunden / Tage, leer = Einstellungen)
+		for _, key := range []string{"epg.window.past", "epg.window.future"} {
+
+			switch v := data.(map[string]interface{})[key].(type) {
+
+			case float64:
+				if v < 0 {
+					err = errors.New(getErrMsg(1035))
+					return
+				}
+
+			case string:
+				if n, errAtoi := strconv.Atoi(strings.TrimSpace(v)); len(strings.TrimSpace(v)) > 0 && (errAtoi != nil || n < 0) {
+					err = errors.New(getErrMsg(1035))
+					return
+				}
+
+			}
+
+		}
+
 		if dataID == "-" {
 
 			// Neue Providerdatei
@@ -432,6 +523,11 @@ func deleteLocalProviderFiles(dataID, fileType string) {
 	if _, ok := removeData[dataID]; ok {
 		delete(removeData, dataID)
 		os.RemoveAll(System.Folder.Data + dataID + fileExtension)
+
+		// Index der XMLTV Datei
+		if fileType == "xmltv" {
+			os.RemoveAll(System.Folder.Cache + "xmltv" + string(os.PathSeparator) + dataID + fileExtension + ".idx")
+		}
 	}
 
 	return
@@ -481,7 +577,9 @@ func saveFilter(request RequestStruct) (settings SettingsStruct, err error) {
 
 			if filter, ok := data.(map[string]interface{})["filter"].(string); ok {
 
-				if len(filter) == 0 {
+				var expression, _ = data.(map[string]interface{})["expression"].(string)
+
+				if len(filter) == 0 && len(expression) == 0 {
 
 					err = errors.New(getErrMsg(1014))
 					if newFilter {
@@ -493,12 +591,40 @@ func saveFilter(request RequestStruct) (settings SettingsStruct, err error) {
 
 			}
 
+			if expression, ok := data.(map[string]interface{})["expression"].(string); ok && len(expression) > 0 {
+
+				if _, errCompile := filterexpr.Compile(expression, filterexpr.Options{}); errCompile != nil {
+
+					err = fmt.Errorf("%s: %s", getErrMsg(1014), errCompile)
+					if newFilter {
+						delete(filterMap, dataID)
+					}
+
+					return
+				}
+
+			}
+
 			if oldData, ok := filterMap[dataID].(map[string]interface{}); ok {
 				oldData[key] = value
 			}
 
 		}
 
+		// Das Webinterface sendet nur filter / include / exclude. Ein gespeicherter Ausdruck würde diese Änderungen überdecken.
+		if values, ok := data.(map[string]interface{}); ok {
+
+			_, hasExpression := values["expression"]
+			_, hasFilter := values["filter"]
+			_, hasInclude := values["include"]
+			_, hasExclude := values["exclude"]
+
+			if oldData, ok := filterMap[dataID].(map[string]interface{}); ok && !hasExpression && (hasFilter || hasInclude || hasExclude) {
+				delete(oldData, "expression")
+			}
+
+		}
+
 	}
 
 	err = saveSettings(Settings)
@@ -535,51 +661,40 @@ func saveXEpgMapping(request RequestStruct) (err error) {
 		return
 	}
 
-	err = saveMapToJSONFile(System.File.XEPG, request.EpgMapping)
-	if err != nil {
-		return err
-	}
-
-	Data.XEPG.Channels = request.EpgMapping
-
-	if System.ScanInProgress == 0 {
+	// Versatz der Kanäle prüfen (x-epg-offset)
+	for _, channel := range request.EpgMapping {
 
-		System.ScanInProgress = 1
-		cleanupXEPG()
-		System.ScanInProgress = 0
-		buildXEPG(true)
+		if c, ok := channel.(map[string]interface{}); ok {
 
-	} else {
-
-		// Wenn während des erstellen der Datanbank das Mapping erneut gespeichert wird, wird die Datenbank erst später erneut aktualisiert.
-		go func() {
-
-			if System.BackgroundProcess == true {
-				return
+			// Zahlen aus der WebUI / API werden als Text gespeichert
+			if offset, ok := c["x-epg-offset"].(float64); ok {
+				c["x-epg-offset"] = strconv.Itoa(int(offset))
 			}
 
-			System.BackgroundProcess = true
+			if offset, ok := c["x-epg-offset"].(string); ok {
 
-			for {
-				time.Sleep(time.Duration(1) * time.Second)
-				if System.ScanInProgress == 0 {
-					break
+				if _, err = parseEPGOffset(offset); err != nil {
+					err = fmt.Errorf("%s: %s (%s)", c["x-name"], getErrMsg(1033), err)
+					return
 				}
 
 			}
 
-			System.ScanInProgress = 1
-			cleanupXEPG()
-			System.ScanInProgress = 0
-			buildXEPG(false)
-			showInfo("XEPG:" + fmt.Sprintf("Ready to use"))
-
-			System.BackgroundProcess = false
+		}
 
-		}()
+	}
 
+	err = saveMapToJSONFile(System.File.XEPG, request.EpgMapping)
+	if err != nil {
+		return err
 	}
 
+	Data.XEPG.Channels = request.EpgMapping
+
+	// Wenn während des Erstellens der Datenbank das Mapping erneut gespeichert wird, wartet der Job bis die Datenbank fertig ist.
+	// Mehrere Änderungen in dieser Zeit werden zu einem Job zusammengefasst.
+	buildXEPG(true)
+
 	return
 }
 
@@ -763,47 +878,51 @@ func saveWizard(request RequestStruct) (nextStep int, err error) {
 // Filterregeln erstellen
 func createFilterRules() (err error) {
 
-	Data.Filter = nil
-	var dataFilter Filter
+	var invalid map[int64]error
+	Data.Filter, invalid = compileFilters(Settings.Filter)
 
-	for _, f := range Settings.Filter {
+	// Fehlerhafte Filter werden übersprungen, die übrigen Filter bleiben aktiv
+	for _, errFilter := range invalid {
+		ShowError(errFilter, 0)
+	}
 
-		var filter FilterStruct
+	return
+}
 
-		var exclude, include string
+// Filter kompilieren und nach Priorität sortieren
+func compileFilters(filters map[int64]interface{}) (list []Filter, invalid map[int64]error) {
 
-		err = json.Unmarshal([]byte(mapToJSON(f)), &filter)
-		if err != nil {
-			return
-		}
+	invalid = make(map[int64]error)
 
-		switch filter.Type {
+	for id, f := range filters {
 
-		case "custom-filter":
-			dataFilter.CaseSensitive = filter.CaseSensitive
-			dataFilter.Rule = filter.Filter
-			dataFilter.Type = filter.Type
+		var filter FilterStruct
+
+		err := json.Unmarshal([]byte(mapToJSON(f)), &filter)
+		if err != nil {
+			invalid[id] = err
+			continue
+		}
 
-			Data.Filter = append(Data.Filter, dataFilter)
+		dataFilter, err := compileFilter(id, filter)
+		if err != nil {
+			invalid[id] = err
+			continue
+		}
 
-		case "group-title":
-			if len(filter.Include) > 0 {
-				include = fmt.Sprintf(" {%s}", filter.Include)
-			}
+		list = append(list, dataFilter)
 
-			if len(filter.Exclude) > 0 {
-				exclude = fmt.Sprintf(" !{%s}", filter.Exclude)
-			}
+	}
 
-			dataFilter.CaseSensitive = filter.CaseSensitive
-			dataFilter.LiveEvent = filter.LiveEvent
-			dataFilter.Rule = fmt.Sprintf("%s%s%s", filter.Filter, include, exclude)
-			dataFilter.Type = filter.Type
+	// Höhere Priorität zuerst, bei gleicher Priorität in der Reihenfolge der Erstellung
+	sort.SliceStable(list, func(i, j int) bool {
 
-			Data.Filter = append(Data.Filter, dataFilter)
+		if list[i].Priority != list[j].Priority {
+			return list[i].Priority > list[j].Priority
 		}
 
-	}
+		return list[i].ID < list[j].ID
+	})
 
 	return
 }
@@ -0,0 +1,403 @@
+package src
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"threadfin/src/internal/hdhomerun"
+
+	"github.com/koron/go-ssdp"
+)
+
+// Virtuelle HDHomeRun Geräte. Jedes Gerät hat eine eigene Geräte ID, einen eigenen Namen, eine eigene Anzahl an Tunern
+// und eine eigene Kanalauswahl. Das Gerät ist unter /devices/<id>/ erreichbar (discover.json, lineup.json, lineup_status.json,
+// device.xml) und mit einem Port zusätzlich über einen eigenen Port, damit Plex und Emby jedes Gerät als eigenen DVR hinzufügen können.
+
+var (
+	deviceMutex      sync.RWMutex
+	virtualDevices   []VirtualDevice
+	deviceServers    = make(map[string]*http.Server)
+	deviceAdvertiser []*ssdp.Advertiser
+	deviceAliveOnce  sync.Once
+)
+
+var deviceIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// startVirtualDevices : Listener der Ports und SSDP Ankündigung der aktiven Geräte starten
+func startVirtualDevices() {
+
+	deviceMutex.Lock()
+	virtualDevices = make([]VirtualDevice, 0, len(Settings.Devices))
+	for _, device := range Settings.Devices {
+		if device.Active {
+			virtualDevices = append(virtualDevices, device)
+		}
+	}
+	deviceMutex.Unlock()
+
+	updateDeviceServers()
+	advertiseVirtualDevices()
+
+}
+
+// getVirtualDevice : Aktives Gerät mit der ID (Pfad Präfix)
+func getVirtualDevice(id string) (device *VirtualDevice, ok bool) {
+
+	deviceMutex.RLock()
+	defer deviceMutex.RUnlock()
+
+	for _, d := range append(append([]VirtualDevice{}, virtualDevices...), shardDevices...) {
+		if d.ID == id {
+			return &d, true
+		}
+	}
+
+	return nil, false
+}
+
+func getVirtualDevices() (list []VirtualDevice) {
+
+	deviceMutex.RLock()
+	defer deviceMutex.RUnlock()
+
+	list = append(list, virtualDevices...)
+	return append(list, shardDevices...)
+}
+
+// saveVirtualDevices : Geräte prüfen und speichern, Listener und SSDP werden sofort aktualisiert
+func saveVirtualDevices(devices []VirtualDevice) (settings SettingsStruct, err error) {
+
+	if devices == nil {
+		devices = []VirtualDevice{}
+	}
+
+	var ids = make(map[string]bool)
+	var deviceIDs = map[string]bool{System.DeviceID: true, Settings.HDHRDeviceID: true}
+	var ports = map[string]bool{Settings.Port: true}
+
+	for i := range devices {
+
+		var device = &devices[i]
+
+		device.ID = strings.ToLower(strings.TrimSpace(device.ID))
+		device.Port = strings.TrimSpace(device.Port)
+
+		// shard-<n> wird von den Lineup Shards verwendet
+		if !deviceIDPattern.MatchString(device.ID) || ids[device.ID] || strings.HasPrefix(device.ID, "shard-") {
+			err = fmt.Errorf("%s: id %q", getErrMsg(1028), device.ID)
+			return
+		}
+
+		if len(device.Name) == 0 {
+			device.Name = fmt.Sprintf("%s %s", System.Name, device.ID)
+		}
+
+		if device.Tuner <= 0 {
+			device.Tuner = Settings.Tuner
+		}
+
+		if len(device.DeviceID) == 0 {
+			device.DeviceID = Settings.UUID + device.ID
+		}
+
+		// IDs ohne gültige Prüfsumme werden durch die ID der HDHomeRun Discovery ersetzt
+		device.DeviceID = hdhomerun.FormatDeviceID(hdhomerun.DeviceID(device.DeviceID))
+
+		if deviceIDs[device.DeviceID] {
+			err = fmt.Errorf("%s: device ID %q", getErrMsg(1028), device.DeviceID)
+			return
+		}
+
+		if len(device.Port) > 0 {
+
+			port, errPort := strconv.Atoi(device.Port)
+			if errPort != nil || port < 1 || port > 65535 || ports[device.Port] {
+				err = fmt.Errorf("%s: port %q", getErrMsg(1028), device.Port)
+				return
+			}
+
+			ports[device.Port] = true
+
+		}
+
+		ids[device.ID] = true
+		deviceIDs[device.DeviceID] = true
+
+	}
+
+	Settings.Devices = devices
+
+	err = saveSettings(Settings)
+	if err != nil {
+		return
+	}
+
+	settings = Settings
+
+	startVirtualDevices()
+
+	return
+}
+
+// selects : true, wenn der Kanal zum Lineup des Geräts gehört. Ohne Auswahl enthält das Gerät alle Kanäle.
+func (device *VirtualDevice) selects(xepgChannel XEPGChannelStruct) bool {
+
+	if device == nil {
+		return inMainShard(xepgChannel.XEPG)
+	}
+
+	if len(device.Groups) == 0 && len(device.Filters) == 0 && len(device.Channels) == 0 {
+		return true
+	}
+
+	if len(xepgChannel.XEPG) > 0 && indexOfString(xepgChannel.XEPG, device.Channels) != -1 {
+		return true
+	}
+
+	var group = xepgChannel.XGroupTitle
+	if len(group) == 0 {
+		group = xepgChannel.GroupTitle
+	}
+
+	if indexOfString(group, device.Groups) != -1 {
+		return true
+	}
+
+	if len(device.Filters) > 0 {
+
+		if i := numberingFilter(xepgChannel, Data.Filter); i >= 0 {
+			for _, id := range device.Filters {
+				if Data.Filter[i].ID == id {
+					return true
+				}
+			}
+		}
+
+	}
+
+	return false
+}
+
+// deviceInfo : Werte des Hauptgeräts (nil) oder eines virtuellen Geräts
+func deviceInfo(device *VirtualDevice) (deviceID, name, baseURL string, tuner int) {
+
+	if device == nil {
+		return System.DeviceID, System.Name, System.ServerProtocol.WEB + "://" + System.Domain, Settings.Tuner
+	}
+
+	baseURL = System.ServerProtocol.WEB + "://" + System.Domain + "/devices/" + device.ID
+
+	if len(device.Port) > 0 {
+
+		var host = System.Domain
+		if h, _, err := net.SplitHostPort(System.Domain); err == nil {
+			host = h
+		}
+
+		baseURL = System.ServerProtocol.WEB + "://" + net.JoinHostPort(host, device.Port)
+
+	}
+
+	return device.DeviceID, device.Name, baseURL, device.Tuner
+}
+
+// Devices : Webserver /devices/<id>/
+func Devices(w http.ResponseWriter, r *http.Request) {
+
+	var parts = strings.SplitN(strings.TrimPrefix(r.URL.Path, "/devices/"), "/", 2)
+
+	device, ok := getVirtualDevice(parts[0])
+	if !ok {
+		httpStatusError(w, r, 404)
+		return
+	}
+
+	var path = "/"
+	if len(parts) == 2 {
+		path += parts[1]
+	}
+
+	serveHDHR(w, r, device, path)
+}
+
+// updateDeviceServers : Ein Listener für jedes Gerät mit eigenem Port
+func updateDeviceServers() {
+
+	var ipAddress = System.IPAddress
+	if Settings.BindIpAddress != "" {
+		ipAddress = Settings.BindIpAddress
+	}
+
+	var wanted = make(map[string]string)
+	for _, device := range getVirtualDevices() {
+		if len(device.Port) > 0 {
+			wanted[device.Port] = device.ID
+		}
+	}
+
+	deviceMutex.Lock()
+	defer deviceMutex.Unlock()
+
+	for port, server := range deviceServers {
+		if _, ok := wanted[port]; !ok {
+			server.Close()
+			delete(deviceServers, port)
+		}
+	}
+
+	for port := range wanted {
+
+		if _, ok := deviceServers[port]; ok {
+			continue
+		}
+
+		var listenPort = port
+		var server = &http.Server{
+			Addr: net.JoinHostPort(ipAddress, listenPort),
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+				for _, device := range getVirtualDevices() {
+					if device.Port == listenPort {
+						serveHDHR(w, r, &device, r.URL.Path)
+						return
+					}
+				}
+
+				httpStatusError(w, r, 404)
+			}),
+		}
+
+		deviceServers[port] = server
+
+		go func() {
+			showInfo(fmt.Sprintf("Virtual Device:%s (port %s)", wanted[listenPort], listenPort))
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				ShowError(err, 1001)
+			}
+		}()
+
+	}
+
+}
+
+// advertiseVirtualDevices : SSDP Ankündigung der virtuellen Geräte, das Hauptgerät wird von SSDP() angekündigt
+func advertiseVirtualDevices() {
+
+	deviceMutex.Lock()
+	defer deviceMutex.Unlock()
+
+	for _, ad := range deviceAdvertiser {
+		ad.Bye()
+		ad.Close()
+	}
+
+	deviceAdvertiser = nil
+
+	if !Settings.SSDP || System.Flag.Info {
+		return
+	}
+
+	for _, device := range append(append([]VirtualDevice{}, virtualDevices...), shardDevices...) {
+
+		deviceID, _, baseURL, _ := deviceInfo(&device)
+
+		ad, err := ssdp.Advertise(
+			"upnp:rootdevice",
+			fmt.Sprintf("uuid:%s::upnp:rootdevice", deviceID),
+			fmt.Sprintf("%s/device.xml", baseURL),
+			fmt.Sprintf("Linux/3.14 UPnP/1.0 %s/%s", System.Name, System.Version),
+			1800)
+
+		if err != nil {
+			ShowError(err, 0)
+			continue
+		}
+
+		deviceAdvertiser = append(deviceAdvertiser, ad)
+
+	}
+
+	deviceAliveOnce.Do(func() {
+
+		go func() {
+
+			for range time.Tick(300 * time.Second) {
+
+				deviceMutex.RLock()
+				for _, ad := range deviceAdvertiser {
+					if err := ad.Alive(); err != nil {
+						ShowError(err, 0)
+					}
+				}
+				deviceMutex.RUnlock()
+
+			}
+
+		}()
+
+	})
+
+}
+
+// byeVirtualDevices : SSDP Abmeldung der virtuellen Geräte vor dem Beenden
+func byeVirtualDevices() {
+
+	deviceMutex.Lock()
+	defer deviceMutex.Unlock()
+
+	for _, ad := range deviceAdvertiser {
+		ad.Bye()
+		ad.Close()
+	}
+
+	deviceAdvertiser = nil
+
+}
+
+// discoveryDevices : Hauptgerät und virtuelle Geräte für die binäre HDHomeRun Discovery
+func discoveryDevices() (devices []hdhomerun.Device) {
+
+	var list = []*VirtualDevice{nil}
+	for _, device := range getVirtualDevices() {
+		list = append(list, &device)
+	}
+
+	for _, device := range list {
+
+		deviceID, _, baseURL, tuner := deviceInfo(device)
+
+		// Das Hauptgerät antwortet mit der gespeicherten ID, System.DeviceID hat keine gültige Prüfsumme
+		if device == nil {
+			deviceID = Settings.HDHRDeviceID
+		}
+
+		devices = append(devices, hdhomerun.Device{
+			ID:         hdhomerun.DeviceID(deviceID),
+			TunerCount: tuner,
+			BaseURL:    baseURL,
+			LineupURL:  baseURL + "/lineup.json",
+			DeviceAuth: System.AppName,
+		})
+
+	}
+
+	return
+}
+
+// virtualDeviceDiscovery : Antwortzeilen der textbasierten UDP Discovery ("<device id> <base url>")
+func virtualDeviceDiscovery() (lines []string) {
+
+	for _, device := range getVirtualDevices() {
+		deviceID, _, baseURL, _ := deviceInfo(&device)
+		lines = append(lines, fmt.Sprintf("%s %s", deviceID, baseURL))
+	}
+
+	return
+}
@@ -0,0 +1,334 @@
+package src
+
+import (
+	"fmt"
+	"hash/crc32"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"threadfin/src/internal/dlna"
+
+	"github.com/koron/go-ssdp"
+)
+
+// DLNA / UPnP AV MediaServer. Mit der Einstellung dlna enthält die Gerätebeschreibung (device.xml) des Hauptgeräts
+// ein ContentDirectory und einen ConnectionManager, Fernseher und Player können die Kanäle nach Gruppen durchsuchen
+// und über /stream/ abspielen.
+//
+//	0                  Wurzel, ein Container pro Gruppe
+//	g-<md5 der Gruppe>  Kanäle der Gruppe
+//	c-<xepg id>        Kanal, die Ressource ist die Streaming URL
+
+var (
+	dlnaMutex      sync.Mutex
+	dlnaAdvertiser []*ssdp.Advertiser
+	dlnaAliveOnce  sync.Once
+
+	dlnaObjectsMutex sync.Mutex
+	dlnaObjectsCache []dlna.Object
+	dlnaUpdateID     uint32
+)
+
+// dlnaServices : Dienste für die Gerätebeschreibung des Hauptgeräts
+func dlnaServices() (services []CapabilityService) {
+
+	if !Settings.DLNA {
+		return
+	}
+
+	return []CapabilityService{
+		{ServiceType: dlna.ContentDirectory, ServiceID: "urn:upnp-org:serviceId:ContentDirectory", SCPDURL: "/dlna/ContentDirectory.xml", ControlURL: "/dlna/control/ContentDirectory", EventSubURL: "/dlna/event/ContentDirectory"},
+		{ServiceType: dlna.ConnectionManager, ServiceID: "urn:upnp-org:serviceId:ConnectionManager", SCPDURL: "/dlna/ConnectionManager.xml", ControlURL: "/dlna/control/ConnectionManager", EventSubURL: "/dlna/event/ConnectionManager"},
+	}
+}
+
+// DLNA : Webserver /dlna/
+func DLNA(w http.ResponseWriter, r *http.Request) {
+
+	if !Settings.DLNA {
+		httpStatusError(w, r, 404)
+		return
+	}
+
+	systemMutex.Lock()
+	if Settings.HttpThreadfinDomain != "" {
+		setGlobalDomain(getBaseUrl(Settings.HttpThreadfinDomain, Settings.Port))
+	} else {
+		setGlobalDomain(r.Host)
+	}
+	systemMutex.Unlock()
+
+	switch {
+
+	case r.URL.Path == "/dlna/ContentDirectory.xml":
+		writeDLNA(w, http.StatusOK, []byte(dlna.ContentDirectorySCPD))
+
+	case r.URL.Path == "/dlna/ConnectionManager.xml":
+		writeDLNA(w, http.StatusOK, []byte(dlna.ConnectionManagerSCPD))
+
+	case strings.HasPrefix(r.URL.Path, "/dlna/control/"):
+		if r.Method != http.MethodPost {
+			httpStatusError(w, r, 405)
+			return
+		}
+
+		// Browse listet die Streaming URLs, daher die gleiche Authentifizierung wie bei /lineup.json
+		systemMutex.Lock()
+		var authentication = Settings.AuthenticationPMS
+		systemMutex.Unlock()
+
+		if authentication {
+			if _, err := basicAuth(r, "authentication.pms"); err != nil {
+				ShowError(err, 000)
+				httpStatusError(w, r, 403)
+				return
+			}
+		}
+
+		dlnaControl(w, r)
+
+	case strings.HasPrefix(r.URL.Path, "/dlna/event/"):
+		// Es werden keine Events gesendet, das Abonnement wird nur bestätigt
+		switch r.Method {
+		case "SUBSCRIBE":
+			var sid = r.Header.Get("SID")
+			if len(sid) == 0 {
+				sid = "uuid:" + getMD5(r.RemoteAddr+time.Now().String())
+			}
+			w.Header().Set("SID", sid)
+			w.Header().Set("TIMEOUT", "Second-1800")
+			w.WriteHeader(http.StatusOK)
+		case "UNSUBSCRIBE":
+			w.WriteHeader(http.StatusOK)
+		default:
+			httpStatusError(w, r, 405)
+		}
+
+	default:
+		httpStatusError(w, r, 404)
+
+	}
+
+}
+
+func dlnaControl(w http.ResponseWriter, r *http.Request) {
+
+	action, err := dlna.ParseAction(r.Body)
+	if err != nil {
+		writeDLNA(w, http.StatusInternalServerError, dlna.Fault(dlna.ErrInvalidAction, "Invalid Action"))
+		return
+	}
+
+	switch action.Service + "#" + action.Name {
+
+	case dlna.ContentDirectory + "#Browse":
+		var objects, updateID = dlnaObjects()
+		list, total, err := dlna.Browse(objects, action.Args["ObjectID"], action.Args["BrowseFlag"], action.Int("StartingIndex", 0), action.Int("RequestedCount", 0))
+		if err != nil {
+			var upnpErr = err.(*dlna.Error)
+			writeDLNA(w, http.StatusInternalServerError, dlna.Fault(upnpErr.Code, upnpErr.Description))
+			return
+		}
+		writeDLNA(w, http.StatusOK, dlna.Response(action.Service, action.Name,
+			"Result", dlna.DIDL(list),
+			"NumberReturned", strconv.Itoa(len(list)),
+			"TotalMatches", strconv.Itoa(total),
+			"UpdateID", strconv.FormatUint(uint64(updateID), 10)))
+
+	case dlna.ContentDirectory + "#GetSearchCapabilities":
+		writeDLNA(w, http.StatusOK, dlna.Response(action.Service, action.Name, "SearchCaps", ""))
+
+	case dlna.ContentDirectory + "#GetSortCapabilities":
+		writeDLNA(w, http.StatusOK, dlna.Response(action.Service, action.Name, "SortCaps", ""))
+
+	case dlna.ContentDirectory + "#GetSystemUpdateID":
+		var _, updateID = dlnaObjects()
+		writeDLNA(w, http.StatusOK, dlna.Response(action.Service, action.Name, "Id", strconv.FormatUint(uint64(updateID), 10)))
+
+	case dlna.ConnectionManager + "#GetProtocolInfo":
+		writeDLNA(w, http.StatusOK, dlna.Response(action.Service, action.Name, "Source", dlna.ProtocolInfo, "Sink", ""))
+
+	case dlna.ConnectionManager + "#GetCurrentConnectionIDs":
+		writeDLNA(w, http.StatusOK, dlna.Response(action.Service, action.Name, "ConnectionIDs", "0"))
+
+	case dlna.ConnectionManager + "#GetCurrentConnectionInfo":
+		writeDLNA(w, http.StatusOK, dlna.Response(action.Service, action.Name,
+			"RcsID", "-1", "AVTransportID", "-1", "ProtocolInfo", "", "PeerConnectionManager", "", "PeerConnectionID", "-1", "Direction", "Output", "Status", "OK"))
+
+	default:
+		writeDLNA(w, http.StatusInternalServerError, dlna.Fault(dlna.ErrInvalidAction, "Invalid Action"))
+
+	}
+
+}
+
+func writeDLNA(w http.ResponseWriter, status int, content []byte) {
+
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	w.Header().Set("EXT", "")
+	w.WriteHeader(status)
+	w.Write(content)
+
+}
+
+// updateDLNAObjects : Inhalt des ContentDirectory, wird einmal pro XEPG Build erstellt. Die Stream URLs enthalten nur
+// den Pfad, Protokoll und Domain der Anfrage ergänzt dlnaObjects.
+func updateDLNAObjects() (err error) {
+
+	var objects []dlna.Object
+	var groups = make(map[string][]dlna.Object)
+
+	for _, channel := range activeLineupChannels() {
+
+		var group = channel.XGroupTitle
+		if len(group) == 0 {
+			group = System.Name
+		}
+
+		streamURL, err := createStreamingURL("DVR", channel.FileM3UID, channel.XChannelID, channel.XName, channel.URL, channel.BackupChannel1, channel.BackupChannel2, channel.BackupChannel3)
+		if err != nil {
+			continue
+		}
+
+		groups[group] = append(groups[group], dlna.Object{
+			ID:            "c-" + channel.XEPG,
+			ParentID:      "g-" + getMD5(group),
+			Title:         channel.XName,
+			Class:         dlna.ClassChannel,
+			ChannelNumber: channel.XChannelID,
+			Icon:          channel.TvgLogo,
+			URL:           "/stream/" + path.Base(streamURL),
+		})
+
+	}
+
+	var names = make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	objects = append(objects, dlna.Object{ID: "0", ParentID: "-1", Title: System.Name, Class: dlna.ClassFolder, ChildCount: len(names)})
+
+	var hash = crc32.NewIEEE()
+
+	for _, name := range names {
+
+		objects = append(objects, dlna.Object{ID: "g-" + getMD5(name), ParentID: "0", Title: name, Class: dlna.ClassFolder, ChildCount: len(groups[name])})
+
+		for _, item := range groups[name] {
+			objects = append(objects, item)
+			fmt.Fprintf(hash, "%s|%s|%s|%s\n", item.ID, item.ParentID, item.Title, item.ChannelNumber)
+		}
+
+	}
+
+	saveMapToJSONFile(System.File.URLS, Data.Cache.StreamingURLS)
+
+	dlnaObjectsMutex.Lock()
+	dlnaObjectsCache, dlnaUpdateID = objects, hash.Sum32()
+	dlnaObjectsMutex.Unlock()
+
+	return
+}
+
+// dlnaObjects : Inhalt des ContentDirectory mit den Stream URLs der aktuellen Domain, die Update ID ändert sich mit der Kanalliste
+func dlnaObjects() (objects []dlna.Object, updateID uint32) {
+
+	var base = streamingBaseURL("DVR")
+
+	dlnaObjectsMutex.Lock()
+	defer dlnaObjectsMutex.Unlock()
+
+	objects = make([]dlna.Object, len(dlnaObjectsCache))
+
+	for i, object := range dlnaObjectsCache {
+
+		if len(object.URL) > 0 {
+			object.URL = base + object.URL
+		}
+
+		objects[i] = object
+
+	}
+
+	return objects, dlnaUpdateID
+}
+
+// advertiseDLNA : SSDP Ankündigung als MediaServer mit ContentDirectory, Fernseher suchen nach diesen Typen
+func advertiseDLNA() {
+
+	dlnaMutex.Lock()
+	defer dlnaMutex.Unlock()
+
+	for _, ad := range dlnaAdvertiser {
+		ad.Bye()
+		ad.Close()
+	}
+
+	dlnaAdvertiser = nil
+
+	if !Settings.DLNA || !Settings.SSDP || System.Flag.Info {
+		return
+	}
+
+	for _, st := range []string{dlna.MediaServer, dlna.ContentDirectory, dlna.ConnectionManager} {
+
+		ad, err := ssdp.Advertise(
+			st,
+			fmt.Sprintf("uuid:%s::%s", System.DeviceID, st),
+			fmt.Sprintf("%s/device.xml", System.URLBase),
+			fmt.Sprintf("Linux/3.14 UPnP/1.0 %s/%s", System.Name, System.Version),
+			1800)
+
+		if err != nil {
+			ShowError(err, 0)
+			continue
+		}
+
+		dlnaAdvertiser = append(dlnaAdvertiser, ad)
+
+	}
+
+	dlnaAliveOnce.Do(func() {
+
+		go func() {
+
+			for range time.Tick(300 * time.Second) {
+
+				dlnaMutex.Lock()
+				for _, ad := range dlnaAdvertiser {
+					if err := ad.Alive(); err != nil {
+						ShowError(err, 0)
+					}
+				}
+				dlnaMutex.Unlock()
+
+			}
+
+		}()
+
+	})
+
+}
+
+// byeDLNA : SSDP Abmeldung vor dem Beenden
+func byeDLNA() {
+
+	dlnaMutex.Lock()
+	defer dlnaMutex.Unlock()
+
+	for _, ad := range dlnaAdvertiser {
+		ad.Bye()
+		ad.Close()
+	}
+
+	dlnaAdvertiser = nil
+
+}
@@ -0,0 +1,446 @@
+package src
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Dummy Vorlagen (Einstellung dummy.templates) ersetzen die allgemeinen Dummy Blöcke der Kanäle, die dem Threadfin Dummy
+// zugeordnet sind. Eine Vorlage gilt zuerst für die über die XEPG ID aufgeführten Kanäle, danach für die Kanäle ihrer Gruppen.
+//
+// Tagesabschnitte ("Morning News" 06:00 - 09:00) werden an den Tagen des Abschnitts erstellt, ein Ende vor dem Beginn liegt
+// am nächsten Tag. Überschneiden sich Abschnitte, gilt der Abschnitt, der zuerst beginnt. Die Zeit außerhalb der Abschnitte
+// wird mit Blöcken der Länge der Vorlage (oder der Länge aus x-mapping) gefüllt.
+
+const dummyTemplateDays = 4
+
+var dummyPlaceholder = regexp.MustCompile(`\{[a-z]+\}`)
+
+var dummyPlaceholders = map[string]bool{
+	"{channel}": true, "{group}": true, "{category}": true, "{daypart}": true, "{weekday}": true,
+	"{day}": true, "{date}": true, "{start}": true, "{stop}": true, "{length}": true,
+}
+
+// dummyBlock : Sendung des Dummy EPG, part ist außerhalb der Tagesabschnitte nil
+type dummyBlock struct {
+	start, stop time.Time
+	part        *DummyDayPart
+}
+
+// dummyTemplateFor : Vorlage des Kanals, über die XEPG ID aufgeführte Kanäle vor den Gruppen
+func dummyTemplateFor(templates []DummyTemplate, xepgChannel XEPGChannelStruct) (template DummyTemplate, ok bool) {
+
+	for _, t := range templates {
+		for _, id := range t.Channels {
+			if id == xepgChannel.XEPG {
+				return t, true
+			}
+		}
+	}
+
+	for _, t := range templates {
+		for _, group := range t.Groups {
+			if strings.EqualFold(group, xepgChannel.XGroupTitle) || strings.EqualFold(group, xepgChannel.GroupTitle) {
+				return t, true
+			}
+		}
+	}
+
+	return
+}
+
+// parseDayTime : Minuten seit Mitternacht von 06:00 (24:00 ist das Ende des Tages)
+func parseDayTime(value string) (minutes int, err error) {
+
+	value = strings.TrimSpace(value)
+	if value == "24:00" {
+		return 1440, nil
+	}
+
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, use hh:mm", value)
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// dummyPartDays : Wochentage eines Tagesabschnitts: leer / daily, weekdays, weekends oder eine Liste (mon,wed,fri)
+func dummyPartDays(days string) (map[time.Weekday]bool, error) {
+
+	days = strings.ToLower(strings.TrimSpace(days))
+
+	switch days {
+
+	case "":
+		return parseRecurrence("daily", time.Sunday)
+
+	case "daily", "weekdays", "weekends":
+		return parseRecurrence(days, time.Sunday)
+
+	}
+
+	return parseRecurrence("weekly:"+days, time.Sunday)
+}
+
+// validate : Kanäle oder Gruppen, Längen, Tagesabschnitte und Platzhalter der Vorlage prüfen
+func (t DummyTemplate) validate() (err error) {
+
+	if len(t.Channels) == 0 && len(t.Groups) == 0 {
+		return errors.New("no channels or groups")
+	}
+
+	if t.Length < 0 || t.Length > 1440 {
+		return fmt.Errorf("length %d, use 1 - 1440 minutes or 0", t.Length)
+	}
+
+	var texts = []string{t.Title, t.Description, t.Category}
+
+	for i, part := range t.Parts {
+
+		start, errStart := parseDayTime(part.Start)
+		if errStart != nil {
+			return fmt.Errorf("part %d: start: %s", i+1, errStart)
+		}
+
+		stop, errStop := parseDayTime(part.Stop)
+		if errStop != nil {
+			return fmt.Errorf("part %d: stop: %s", i+1, errStop)
+		}
+
+		if start == stop {
+			return fmt.Errorf("part %d: start and stop are equal", i+1)
+		}
+
+		if _, err = dummyPartDays(part.Days); err != nil {
+			return fmt.Errorf("part %d: %s", i+1, err)
+		}
+
+		if part.Length < 0 || part.Length > 1440 {
+			return fmt.Errorf("part %d: length %d, use 1 - 1440 minutes or 0", i+1, part.Length)
+		}
+
+		texts = append(texts, part.Title, part.Description, part.Category)
+
+	}
+
+	for _, text := range texts {
+		for _, placeholder := range dummyPlaceholder.FindAllString(text, -1) {
+			if !dummyPlaceholders[placeholder] {
+				return fmt.Errorf("unknown placeholder %s", placeholder)
+			}
+		}
+	}
+
+	return
+}
+
+// blocks : Sendungen zwischen from und to, length ist die Länge der Blöcke außerhalb der Tagesabschnitte
+func (t DummyTemplate) blocks(from, to time.Time, length int) (blocks []dummyBlock) {
+
+	var parts []dummyBlock
+
+	for d := -1; d <= int(to.Sub(from).Hours()/24)+1; d++ {
+
+		var day = from.AddDate(0, 0, d)
+
+		for i := range t.Parts {
+
+			var part = &t.Parts[i]
+
+			days, err := dummyPartDays(part.Days)
+			if err != nil || !days[day.Weekday()] {
+				continue
+			}
+
+			start, errStart := parseDayTime(part.Start)
+			stop, errStop := parseDayTime(part.Stop)
+			if errStart != nil || errStop != nil {
+				continue
+			}
+
+			if stop <= start {
+				stop += 1440
+			}
+
+			// time.Date normalisiert die Minuten und behält die lokale Zeit bei der Zeitumstellung bei
+			parts = append(parts, dummyBlock{
+				start: time.Date(day.Year(), day.Month(), day.Day(), 0, start, 0, 0, day.Location()),
+				stop:  time.Date(day.Year(), day.Month(), day.Day(), 0, stop, 0, 0, day.Location()),
+				part:  part,
+			})
+
+		}
+
+	}
+
+	sort.SliceStable(parts, func(i, j int) bool { return parts[i].start.Before(parts[j].start) })
+
+	var fill = func(start, stop time.Time, length int, part *DummyDayPart) {
+
+		for s := start; s.Before(stop); {
+
+			var e = s.Add(time.Duration(length) * time.Minute)
+			if length <= 0 || e.After(stop) {
+				e = stop
+			}
+
+			blocks = append(blocks, dummyBlock{start: s, stop: e, part: part})
+			s = e
+
+		}
+
+	}
+
+	var cursor = from
+
+	for _, p := range parts {
+
+		if p.start.Before(cursor) {
+			p.start = cursor
+		}
+
+		if p.stop.After(to) {
+			p.stop = to
+		}
+
+		if !p.stop.After(p.start) {
+			continue
+		}
+
+		fill(cursor, p.start, length, nil)
+		fill(p.start, p.stop, p.part.Length, p.part)
+		cursor = p.stop
+
+	}
+
+	fill(cursor, to, length, nil)
+
+	return
+}
+
+// dayPartName : Tageszeit des Beginns (Morning, Afternoon, Evening, Night)
+func dayPartName(t time.Time) string {
+
+	switch hour := t.Hour(); {
+
+	case hour >= 5 && hour < 12:
+		return "Morning"
+
+	case hour >= 12 && hour < 17:
+		return "Afternoon"
+
+	case hour >= 17 && hour < 22:
+		return "Evening"
+
+	}
+
+	return "Night"
+}
+
+// dummyText : Text ohne Nicht-ASCII Zeichen, sofern diese nicht aktiviert sind
+func dummyText(text string) string {
+
+	if Settings.EnableNonAscii {
+		return text
+	}
+
+	return strings.TrimSpace(strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII {
+			return -1
+		}
+		return r
+	}, text))
+}
+
+// templateDummyProgram : Dummy Sendungen des Kanals aus der Vorlage, beginnend um Mitternacht des Tages von now
+func templateDummyProgram(xepgChannel XEPGChannelStruct, template DummyTemplate, now time.Time) (programs []*Program) {
+
+	var length = template.Length
+	if length == 0 {
+		length = dummyMappingLength(xepgChannel.XMapping)
+	}
+
+	var group = xepgChannel.XGroupTitle
+	if len(group) == 0 {
+		group = xepgChannel.GroupTitle
+	}
+
+	var from = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	var to = from.AddDate(0, 0, dummyTemplateDays)
+
+	for _, block := range template.blocks(from, to, length) {
+
+		var title, desc, category, icon = template.Title, template.Description, template.Category, template.Icon
+
+		if block.part != nil {
+
+			if len(block.part.Title) > 0 {
+				title = block.part.Title
+			}
+
+			if len(block.part.Description) > 0 {
+				desc = block.part.Description
+			}
+
+			if len(block.part.Category) > 0 {
+				category = block.part.Category
+			}
+
+			if len(block.part.Icon) > 0 {
+				icon = block.part.Icon
+			}
+
+		}
+
+		if len(title) == 0 {
+			title = "{channel} ({day}. {start} - {stop})"
+		}
+
+		if len(desc) == 0 {
+			desc = xepgChannel.XDescription
+		}
+
+		if len(desc) == 0 {
+			desc = "Threadfin: ({length} Minutes) {weekday} {start} - {stop}"
+		}
+
+		var replacer = strings.NewReplacer(
+			"{channel}", xepgChannel.XName,
+			"{group}", group,
+			"{category}", xepgChannel.XCategory,
+			"{daypart}", dayPartName(block.start),
+			"{weekday}", block.start.Weekday().String(),
+			"{day}", block.start.Weekday().String()[0:2],
+			"{date}", block.start.Format("2006-01-02"),
+			"{start}", block.start.Format("15:04"),
+			"{stop}", block.stop.Format("15:04"),
+			"{length}", strconv.Itoa(int(block.stop.Sub(block.start).Minutes())),
+		)
+
+		var epg = &Program{
+			Channel: xepgChannel.XMapping,
+			Start:   block.start.Format("20060102150405 -0700"),
+			Stop:    block.stop.Format("20060102150405 -0700"),
+			Title:   []*Title{{Value: dummyText(replacer.Replace(title)), Lang: "en"}},
+			Desc:    []*Desc{{Value: dummyText(replacer.Replace(desc)), Lang: "en"}},
+		}
+
+		if len(category) > 0 {
+			epg.Category = append(epg.Category, &Category{Value: replacer.Replace(category), Lang: "en"})
+		}
+
+		if len(icon) > 0 {
+			epg.Poster = append(epg.Poster, Poster{Src: icon})
+		} else if Settings.XepgReplaceMissingImages {
+			epg.Poster = append(epg.Poster, Poster{Src: Data.Cache.Images.Image.GetURL(xepgChannel.TvgLogo, Settings.HttpThreadfinDomain, Settings.Port, Settings.ForceHttps, Settings.HttpsPort, Settings.HttpsThreadfinDomain)})
+		}
+
+		if xepgChannel.XCategory != "Movie" {
+			epg.EpisodeNum = append(epg.EpisodeNum, &EpisodeNum{Value: block.start.Format("2006-01-02 15:04:05"), System: "original-air-date"})
+		}
+
+		epg.New = &New{Value: ""}
+
+		programs = append(programs, epg)
+
+	}
+
+	return
+}
+
+// validateDummyTemplates : Alle Vorlagen prüfen, der Fehler enthält den Namen oder die Position der ungültigen Vorlage
+func validateDummyTemplates(templates []DummyTemplate) (err error) {
+
+	for i, template := range templates {
+
+		if err = template.validate(); err != nil {
+
+			var name = template.Name
+			if len(name) == 0 {
+				name = strconv.Itoa(i + 1)
+			}
+
+			return fmt.Errorf("%s (%s: %s)", getErrMsg(1040), name, err)
+		}
+
+	}
+
+	return
+}
+
+// saveDummyTemplates : Vorlagen ersetzen und die XMLTV Datei neu schreiben
+func saveDummyTemplates(templates []DummyTemplate) (settings SettingsStruct, err error) {
+
+	if templates == nil {
+		templates = []DummyTemplate{}
+	}
+
+	if err = validateDummyTemplates(templates); err != nil {
+		return
+	}
+
+	Settings.DummyTemplates = templates
+
+	err = saveSettings(Settings)
+	if err != nil {
+		return
+	}
+
+	settings = Settings
+
+	queueXMLTVFile()
+
+	return
+}
+
+// previewDummyTemplates : Dummy Sendungen des Kanals mit den Vorlagen (bei nil die gespeicherten Vorlagen), es wird nichts gespeichert
+func previewDummyTemplates(xepgID string, templates []DummyTemplate) (programmes []EPGProgramme, err error) {
+
+	if templates == nil {
+		templates = Settings.DummyTemplates
+	}
+
+	if err = validateDummyTemplates(templates); err != nil {
+		return
+	}
+
+	xepgMutex.Lock()
+	dxc, ok := Data.XEPG.Channels[xepgID]
+	xepgMutex.Unlock()
+
+	if !ok {
+		err = errors.New(getErrMsg(1032))
+		return
+	}
+
+	var xepgChannel XEPGChannelStruct
+	if err = json.Unmarshal([]byte(mapToJSON(dxc)), &xepgChannel); err != nil {
+		return
+	}
+
+	var programs []*Program
+
+	if template, ok := dummyTemplateFor(templates, xepgChannel); ok {
+		programs = templateDummyProgram(xepgChannel, template, time.Now())
+	} else {
+		programs = createDummyProgram(xepgChannel).Program
+	}
+
+	programmes = make([]EPGProgramme, 0, len(programs))
+
+	for _, program := range programs {
+		if p, ok := newEPGProgramme(program); ok {
+			programmes = append(programmes, p)
+		}
+	}
+
+	return
+}
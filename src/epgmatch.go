@@ -0,0 +1,314 @@
+package src
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"threadfin/src/internal/fuzzy"
+)
+
+// Kanäle ohne passende tvg-id werden über den Namen mit allen Kanälen aller XMLTV Dateien verglichen
+// (display-name und die Kanal ID ohne Länderkennung, z.B. "BBCOne.uk").
+// Eindeutige Treffer ab epgMatchAutoScore werden in mapping() automatisch zugeordnet,
+// die besten Kandidaten jedes nicht zugeordneten Kanals liefert der API Befehl epg.suggestions.
+// mapping() ordnet nur neue Kanäle zu, epg.suggestions.apply ordnet die Kanäle ohne EPG ("-") nachträglich zu.
+
+const (
+	epgMatchAutoScore     = 0.9
+	epgMatchMinScore      = 0.5
+	epgMatchMaxCandidates = 5
+)
+
+type epgMatchCandidate struct {
+	file, id, displayName string
+	names                 []fuzzy.Name
+	compactID             string
+}
+
+// epgMatcher : Alle Kanäle der XMLTV Dateien
+type epgMatcher struct {
+	candidates []epgMatchCandidate
+}
+
+func newEPGMatcher() (m *epgMatcher) {
+
+	m = &epgMatcher{}
+
+	var files = make([]string, 0, len(Data.XMLTV.Mapping))
+	for file := range Data.XMLTV.Mapping {
+		if file != "Threadfin Dummy" {
+			files = append(files, file)
+		}
+	}
+
+	sort.Strings(files)
+
+	for _, file := range files {
+
+		channels, ok := Data.XMLTV.Mapping[file].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		var ids = make([]string, 0, len(channels))
+		for id := range channels {
+			ids = append(ids, id)
+		}
+
+		sort.Strings(ids)
+
+		for _, id := range ids {
+
+			channel, ok := channels[id].(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			var candidate = epgMatchCandidate{file: file, id: id, compactID: compactName(channelIDStem(id))}
+			candidate.displayName, _ = channel["display-name"].(string)
+
+			var aliases []string
+			switch v := channel["aliases"].(type) {
+			case []string:
+				aliases = v
+			case []interface{}:
+				for _, alias := range v {
+					if s, ok := alias.(string); ok {
+						aliases = append(aliases, s)
+					}
+				}
+			}
+
+			if len(aliases) == 0 && len(candidate.displayName) > 0 {
+				aliases = []string{candidate.displayName}
+			}
+
+			for _, alias := range aliases {
+				if name := fuzzy.NewName(alias); len(name.Normalized) > 0 {
+					candidate.names = append(candidate.names, name)
+				}
+			}
+
+			m.candidates = append(m.candidates, candidate)
+
+		}
+
+	}
+
+	return
+}
+
+// channelIDStem : XMLTV Kanal ID ohne Länder- / Anbieterkennung (BBCOne.uk -> BBCOne)
+func channelIDStem(id string) string {
+
+	if ext := path.Ext(id); len(ext) > 1 && len(ext) <= 4 {
+		return strings.TrimSuffix(id, ext)
+	}
+
+	return id
+}
+
+// compactName : Normalisierter Name ohne Leerzeichen (BBC One -> bbcone)
+func compactName(name string) string {
+	return strings.ReplaceAll(fuzzy.Normalize(name), " ", "")
+}
+
+// suggest : Beste Kandidaten für die Namen eines Kanals, höchste Bewertung zuerst
+func (m *epgMatcher) suggest(names ...string) (candidates []EPGCandidate) {
+
+	var channelNames []fuzzy.Name
+	var compact = make(map[string]bool)
+
+	for _, name := range names {
+
+		var n = fuzzy.NewName(name)
+		if len(n.Normalized) == 0 {
+			continue
+		}
+
+		channelNames = append(channelNames, n)
+		compact[strings.ReplaceAll(n.Normalized, " ", "")] = true
+
+	}
+
+	if len(channelNames) == 0 {
+		return
+	}
+
+	for _, candidate := range m.candidates {
+
+		var best EPGCandidate
+
+		for _, name := range channelNames {
+			for _, alias := range candidate.names {
+				if s := name.Similarity(alias); s > best.Score {
+					best.Score, best.Method = s, "name"
+				}
+			}
+		}
+
+		// Die ID enthält den Namen (BBCOne.uk für BBC One)
+		if best.Score < 0.95 && len(candidate.compactID) > 0 && compact[candidate.compactID] {
+			best.Score, best.Method = 0.95, "id"
+		}
+
+		if best.Score < epgMatchMinScore {
+			continue
+		}
+
+		best.File = candidate.file
+		best.Mapping = candidate.id
+		best.DisplayName = candidate.displayName
+
+		candidates = append(candidates, best)
+
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Score > candidates[j].Score })
+
+	if len(candidates) > epgMatchMaxCandidates {
+		candidates = candidates[:epgMatchMaxCandidates]
+	}
+
+	return
+}
+
+// auto : Kandidat für die automatische Zuordnung. Der beste Kandidat muss epgMatchAutoScore erreichen und
+// besser als alle anderen Kanäle sein (die gleiche Kanal ID in einer anderen XMLTV Datei ist kein Konflikt).
+func (m *epgMatcher) auto(names ...string) (candidate EPGCandidate, ok bool) {
+
+	var candidates = m.suggest(names...)
+	if len(candidates) == 0 || candidates[0].Score < epgMatchAutoScore {
+		return
+	}
+
+	for _, other := range candidates[1:] {
+		if other.Score == candidates[0].Score && other.Mapping != candidates[0].Mapping {
+			return
+		}
+	}
+
+	return candidates[0], true
+}
+
+// channelMatchNames : Namen des XEPG Kanals für den Vergleich
+func channelMatchNames(xepgChannel XEPGChannelStruct) []string {
+	return []string{xepgChannel.XName, xepgChannel.Name, xepgChannel.TvgName, channelIDStem(xepgChannel.TvgID)}
+}
+
+// getEPGSuggestions : Kandidaten für alle Kanäle ohne EPG oder nur für den Kanal xepgID
+func getEPGSuggestions(xepgID string) (suggestions []EPGSuggestion, err error) {
+
+	var matcher = newEPGMatcher()
+	suggestions = make([]EPGSuggestion, 0)
+
+	xepgMutex.Lock()
+	defer xepgMutex.Unlock()
+
+	if len(xepgID) > 0 {
+		if _, ok := Data.XEPG.Channels[xepgID]; !ok {
+			err = errors.New(getErrMsg(1032))
+			return
+		}
+	}
+
+	for id, dxc := range Data.XEPG.Channels {
+
+		if len(xepgID) > 0 && id != xepgID {
+			continue
+		}
+
+		var xepgChannel XEPGChannelStruct
+		if err := json.Unmarshal([]byte(mapToJSON(dxc)), &xepgChannel); err != nil {
+			continue
+		}
+
+		var mapped = len(xepgChannel.XmltvFile) > 0 && xepgChannel.XmltvFile != "-" && len(xepgChannel.XMapping) > 0 && xepgChannel.XMapping != "-"
+		if mapped && len(xepgID) == 0 {
+			continue
+		}
+
+		var suggestion = EPGSuggestion{XEPG: id, Name: xepgChannel.XName, Candidates: matcher.suggest(channelMatchNames(xepgChannel)...)}
+		if len(suggestion.Name) == 0 {
+			suggestion.Name = xepgChannel.Name
+		}
+
+		if suggestion.Candidates == nil {
+			suggestion.Candidates = []EPGCandidate{}
+		}
+
+		suggestions = append(suggestions, suggestion)
+
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool { return suggestions[i].Name < suggestions[j].Name })
+
+	return
+}
+
+// applyEPGSuggestions : Ordnet alle Kanäle ohne EPG mit einem eindeutigen Kandidaten zu (siehe auto) und erstellt die
+// XEPG Datenbank neu. Die übernommenen Kandidaten werden zurückgegeben.
+func applyEPGSuggestions() (applied []EPGSuggestion, err error) {
+
+	var matcher = newEPGMatcher()
+	applied = make([]EPGSuggestion, 0)
+
+	xepgMutex.Lock()
+
+	for id, dxc := range Data.XEPG.Channels {
+
+		var xepgChannel XEPGChannelStruct
+		if err := json.Unmarshal([]byte(mapToJSON(dxc)), &xepgChannel); err != nil {
+			continue
+		}
+
+		var unmapped = (len(xepgChannel.XmltvFile) == 0 || xepgChannel.XmltvFile == "-") && (len(xepgChannel.XMapping) == 0 || xepgChannel.XMapping == "-")
+		if !unmapped || xepgChannel.Live {
+			continue
+		}
+
+		candidate, ok := matcher.auto(channelMatchNames(xepgChannel)...)
+		if !ok {
+			continue
+		}
+
+		// Umbenannte Kanäle (rematch.go) sind als XEPGChannelStruct gespeichert, geändert werden nur die Werte in der Map
+		channel, ok := dxc.(map[string]interface{})
+		if !ok {
+			channel = jsonToMap(mapToJSON(dxc))
+		}
+
+		channel["x-xmltv-file"] = candidate.File
+		channel["x-mapping"] = candidate.Mapping
+		channel["x-active"] = true
+		Data.XEPG.Channels[id] = channel
+
+		var suggestion = EPGSuggestion{XEPG: id, Name: xepgChannel.XName, Candidates: []EPGCandidate{candidate}}
+		if len(suggestion.Name) == 0 {
+			suggestion.Name = xepgChannel.Name
+		}
+
+		applied = append(applied, suggestion)
+		showInfo("XEPG:" + fmt.Sprintf("Mapped by name: %s -> %s (%.2f)", suggestion.Name, candidate.DisplayName, candidate.Score))
+
+	}
+
+	if len(applied) > 0 {
+		err = saveMapToJSONFile(System.File.XEPG, Data.XEPG.Channels)
+	}
+
+	xepgMutex.Unlock()
+
+	if err != nil || len(applied) == 0 {
+		return
+	}
+
+	sort.Slice(applied, func(i, j int) bool { return applied[i].Name < applied[j].Name })
+	buildXEPG(false)
+
+	return
+}
@@ -0,0 +1,271 @@
+package src
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// EPG Quellen eines Kanals: zuerst x-xmltv-file / x-mapping, danach x-epg-sources in ihrer Reihenfolge.
+// Die Sendungen der ersten Quelle bleiben erhalten. Eine Sendung einer späteren Quelle
+//
+//	mit dem gleichen Beginn wie eine vorhandene Sendung ergänzt deren fehlende Felder (Beschreibung, Poster, Episodennummer, ...)
+//	vollständig in einer Lücke des EPG wird hinzugefügt
+//	mit Überschneidung zu anderen Sendungen wird verworfen
+//
+// Manuelle Sendungen des Kanals (programs.go) werden zuletzt hinzugefügt und haben Vorrang vor allen Quellen.
+// Die Herkunft jeder Sendung und jedes ergänzten Felds liefert der API Befehl epg.provenance.
+
+// epgMatchTolerance : Sendungen zweier Quellen, die innerhalb dieser Zeit beginnen, sind die gleiche Sendung
+const epgMatchTolerance = 5 * time.Minute
+
+type mergedProgram struct {
+	program     *Program
+	start, stop time.Time
+	provenance  EPGProvenance
+}
+
+// epgSources : Alle EPG Quellen des Kanals, leere und doppelte Quellen werden übersprungen
+func epgSources(xepgChannel XEPGChannelStruct) (sources []EPGSource) {
+
+	var seen = make(map[EPGSource]bool)
+
+	for _, source := range append([]EPGSource{{File: xepgChannel.XmltvFile, Mapping: xepgChannel.XMapping}}, xepgChannel.XEPGSources...) {
+
+		if len(source.File) == 0 || source.File == "-" || len(source.Mapping) == 0 || seen[source] {
+			continue
+		}
+
+		seen[source] = true
+		sources = append(sources, source)
+
+	}
+
+	return
+}
+
+// mergeProgramData : Sendungen aller EPG Quellen des Kanals, begrenzt auf das EPG Zeitfenster (epgwindow.go)
+func mergeProgramData(xepgChannel XEPGChannelStruct) (xepgXML XMLTV, provenance []EPGProvenance, stats epgPruneStats, err error) {
+
+	var sources = epgSources(xepgChannel)
+	var merged []*mergedProgram
+	var now = time.Now()
+
+	for i, source := range sources {
+
+		data, errSource := getSourceProgramData(xepgChannel, source)
+		if errSource != nil {
+
+			// Ohne die erste Quelle gibt es wie bisher keinen EPG
+			if i == 0 {
+				err = errSource
+				return
+			}
+
+			continue
+		}
+
+		var name = source.File + "/" + source.Mapping
+
+		if source.File != "Threadfin Dummy" {
+			var from, to = sourceEPGWindow(now, source.File)
+			var dropped int
+			data.Program, dropped = filterEPGWindow(data.Program, from, to)
+			stats.Window += dropped
+		}
+
+		for _, program := range data.Program {
+
+			start, errStart := parseXMLTVTime(program.Start)
+			stop, errStop := parseXMLTVTime(program.Stop)
+
+			if i == 0 {
+				merged = append(merged, &mergedProgram{program: program, start: start, stop: stop, provenance: EPGProvenance{Source: name}})
+				continue
+			}
+
+			if errStart != nil || errStop != nil || !stop.After(start) {
+				continue
+			}
+
+			if existing := findMergedProgram(merged, start); existing != nil {
+				fillProgram(existing, program, name)
+				continue
+			}
+
+			if !overlapsMergedProgram(merged, start, stop) {
+				merged = append(merged, &mergedProgram{program: program, start: start, stop: stop, provenance: EPGProvenance{Source: name}})
+			}
+
+		}
+
+	}
+
+	// Manuelle Sendungen ersetzen die überschneidenden Sendungen der Quellen (programs.go)
+	if manual := manualProgramData(xepgChannel, now); len(manual) > 0 {
+
+		var manualMerged = make([]*mergedProgram, 0, len(manual))
+		for _, program := range manual {
+			start, _ := parseXMLTVTime(program.Start)
+			stop, _ := parseXMLTVTime(program.Stop)
+			manualMerged = append(manualMerged, &mergedProgram{program: program, start: start, stop: stop, provenance: EPGProvenance{Source: "manual"}})
+		}
+
+		var kept = make([]*mergedProgram, 0, len(merged))
+		for _, m := range merged {
+			if m.start.IsZero() || !overlapsMergedProgram(manualMerged, m.start, m.stop) {
+				kept = append(kept, m)
+			}
+		}
+
+		merged = append(kept, manualMerged...)
+
+	}
+
+	var programs = make([]*Program, 0, len(merged))
+	var byProgram = make(map[*Program]*mergedProgram, len(merged))
+
+	for _, m := range merged {
+		programs = append(programs, m.program)
+		byProgram[m.program] = m
+	}
+
+	var pruned epgPruneStats
+	programs, pruned = pruneProgramData(programs, now)
+	stats.add(pruned)
+
+	for _, program := range programs {
+
+		var m = byProgram[program]
+
+		xepgXML.Program = append(xepgXML.Program, m.program)
+
+		m.provenance.Start = m.program.Start
+		m.provenance.Stop = m.program.Stop
+		if len(m.program.Title) > 0 {
+			m.provenance.Title = m.program.Title[0].Value
+		}
+
+		provenance = append(provenance, m.provenance)
+
+	}
+
+	return
+}
+
+// epgProvenance : Zusammengeführte Sendungen eines XEPG Kanals mit der Quelle jeder Sendung und jedes Felds
+func epgProvenance(xepgID string) (provenance []EPGProvenance, err error) {
+
+	xepgMutex.Lock()
+	var xepgChannel, ok = Data.XEPG.Channels[xepgID]
+	xepgMutex.Unlock()
+
+	if !ok {
+		err = errors.New(getErrMsg(1032))
+		return
+	}
+
+	var channel XEPGChannelStruct
+	if err = json.Unmarshal([]byte(mapToJSON(xepgChannel)), &channel); err != nil {
+		return
+	}
+
+	_, provenance, _, err = mergeProgramData(channel)
+	if provenance == nil {
+		provenance = []EPGProvenance{}
+	}
+
+	return
+}
+
+func findMergedProgram(merged []*mergedProgram, start time.Time) *mergedProgram {
+
+	for _, m := range merged {
+
+		var diff = m.start.Sub(start)
+		if diff < 0 {
+			diff = -diff
+		}
+
+		if !m.start.IsZero() && diff <= epgMatchTolerance {
+			return m
+		}
+
+	}
+
+	return nil
+}
+
+func overlapsMergedProgram(merged []*mergedProgram, start, stop time.Time) bool {
+
+	for _, m := range merged {
+		if m.start.Before(stop) && start.Before(m.stop) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fillProgram : Kopiert die Felder, die in der vorhandenen Sendung fehlen
+func fillProgram(m *mergedProgram, from *Program, source string) {
+
+	var to = m.program
+
+	var fill = func(field string, missing bool, copy func()) {
+
+		if !missing {
+			return
+		}
+
+		copy()
+
+		if m.provenance.Filled == nil {
+			m.provenance.Filled = make(map[string]string)
+		}
+
+		m.provenance.Filled[field] = source
+
+	}
+
+	fill("sub-title", len(to.SubTitle) == 0 && len(from.SubTitle) > 0, func() { to.SubTitle = from.SubTitle })
+	fill("desc", !hasDesc(to) && hasDesc(from), func() { to.Desc = from.Desc })
+	fill("category", len(to.Category) == 0 && len(from.Category) > 0, func() { to.Category = from.Category })
+	fill("icon", len(to.Poster) == 0 && len(from.Poster) > 0, func() { to.Poster = from.Poster })
+	fill("episode-num", len(to.EpisodeNum) == 0 && len(from.EpisodeNum) > 0, func() { to.EpisodeNum = from.EpisodeNum })
+	fill("credits", isEmptyCredits(to.Credits) && !isEmptyCredits(from.Credits), func() { to.Credits = from.Credits })
+	fill("rating", len(to.Rating) == 0 && len(from.Rating) > 0, func() { to.Rating = from.Rating })
+	fill("star-rating", len(to.StarRating) == 0 && len(from.StarRating) > 0, func() { to.StarRating = from.StarRating })
+	fill("country", len(to.Country) == 0 && len(from.Country) > 0, func() { to.Country = from.Country })
+	fill("language", len(to.Language) == 0 && len(from.Language) > 0, func() { to.Language = from.Language })
+	fill("date", len(to.Date) == 0 && len(from.Date) > 0, func() { to.Date = from.Date })
+
+}
+
+func hasDesc(program *Program) bool {
+
+	for _, desc := range program.Desc {
+		if desc != nil && len(strings.TrimSpace(desc.Value)) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isEmptyCredits(credits Credits) bool {
+	return len(credits.Director)+len(credits.Actor)+len(credits.Writer)+len(credits.Presenter)+len(credits.Producer) == 0
+}
+
+// parseXMLTVTime : XMLTV Zeit mit oder ohne Versatz (20240101120000 +0100)
+func parseXMLTVTime(value string) (time.Time, error) {
+
+	value = strings.TrimSpace(value)
+
+	if t, err := time.Parse("20060102150405 -0700", value); err == nil {
+		return t, nil
+	}
+
+	return time.Parse("20060102150405", value)
+}
@@ -0,0 +1,122 @@
+package src
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Zeitkorrekturen der Sendungen, werden in getSourceProgramData angewendet:
+//
+//	epg.timezone (XMLTV Datei): die Zeiten der Datei sind lokale Zeiten dieser Zeitzone, der Versatz in der Datei wird ignoriert
+//	epg.offset   (XMLTV Datei): Minuten, die zu allen Sendungen der Datei addiert werden
+//	x-epg-offset (XEPG Kanal): Minuten, die zu den Sendungen des Kanals addiert werden (Timeshift Kanäle, z.B. +60)
+
+// epgTimeShift : Zeitkorrektur einer EPG Quelle für einen Kanal
+type epgTimeShift struct {
+	location *time.Location
+	offset   time.Duration
+}
+
+// getEPGTimeShift : Zeitkorrektur der Quelle (Einstellungen der XMLTV Datei) und des Kanals
+func getEPGTimeShift(xepgChannel XEPGChannelStruct, source EPGSource) (shift epgTimeShift) {
+
+	var fileID = xmltvFileID(source.File)
+
+	if tz := getProviderParameter(fileID, "xmltv", "epg.timezone"); len(tz) > 0 {
+
+		location, err := parseEPGTimezone(tz)
+		if err != nil {
+			ShowError(fmt.Errorf("%s: %s", getProviderParameter(fileID, "xmltv", "name"), err), 1034)
+		} else {
+			shift.location = location
+		}
+
+	}
+
+	var minutes int
+
+	if m, err := parseEPGOffset(getProviderParameter(fileID, "xmltv", "epg.offset")); err == nil {
+		minutes += m
+	}
+
+	if m, err := parseEPGOffset(xepgChannel.XEPGOffset); err == nil {
+		minutes += m
+	}
+
+	shift.offset = time.Duration(minutes) * time.Minute
+
+	return
+}
+
+// apply : Korrigierte XMLTV Zeit, unverändert wenn es nichts zu korrigieren gibt oder die Zeit ungültig ist
+func (s epgTimeShift) apply(value string) string {
+
+	if s.location == nil && s.offset == 0 {
+		return value
+	}
+
+	var t time.Time
+	var err error
+
+	if s.location != nil {
+
+		var local = strings.TrimSpace(value)
+		if len(local) > 14 {
+			local = local[:14]
+		}
+
+		t, err = time.ParseInLocation("20060102150405", local, s.location)
+
+	} else {
+		t, err = parseXMLTVTime(value)
+	}
+
+	if err != nil {
+		return value
+	}
+
+	return t.Add(s.offset).Format("20060102150405 -0700")
+}
+
+// parseEPGTimezone : IANA Zeitzone (Europe/Berlin) oder fester Versatz (+0100, -05:30)
+func parseEPGTimezone(value string) (location *time.Location, err error) {
+
+	value = strings.TrimSpace(value)
+
+	if strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-") {
+
+		var t time.Time
+		t, err = time.Parse("-0700", strings.Replace(value, ":", "", 1))
+		if err != nil {
+			err = fmt.Errorf("invalid timezone offset %q", value)
+			return
+		}
+
+		_, seconds := t.Zone()
+		location = time.FixedZone(value, seconds)
+
+		return
+	}
+
+	location, err = time.LoadLocation(value)
+
+	return
+}
+
+// parseEPGOffset : Versatz in Minuten, ein leerer Wert ist kein Versatz
+func parseEPGOffset(value string) (minutes int, err error) {
+
+	value = strings.TrimPrefix(strings.TrimSpace(value), "+")
+	if len(value) == 0 {
+		return
+	}
+
+	minutes, err = strconv.Atoi(value)
+	if err == nil && (minutes < -1440 || minutes > 1440) {
+		err = fmt.Errorf("offset %d out of range", minutes)
+	}
+
+	return
+}
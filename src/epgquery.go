@@ -0,0 +1,381 @@
+package src
+
+import (
+	"encoding/xml"
+	"errors"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"threadfin/src/internal/xmltvindex"
+)
+
+// Abfragen des erstellten EPG (threadfin.xml): Jetzt/Danach, ein Zeitraster und eine Volltextsuche.
+// Die Datei wird mit xmltvindex indiziert und in kompakter Form im Speicher gehalten, bis sie neu geschrieben wird.
+
+const (
+	epgGridDefault   = 3 * time.Hour
+	epgGridMax       = 14 * 24 * time.Hour
+	epgSearchDefault = 100
+)
+
+// epgGuide : Sendungen des erstellten EPG
+type epgGuide struct {
+	size    int64
+	modTime time.Time
+
+	channels   []EPGChannelGuide
+	programmes map[string][]EPGProgramme
+	text       map[string][]string // Titel, Untertitel, Beschreibung und Kategorien jeder Sendung in Kleinbuchstaben
+}
+
+var (
+	epgGuideMutex sync.Mutex
+	epgGuideCache *epgGuide
+)
+
+// loadEPGGuide : EPG der aktuellen XMLTV Datei, wird nach einer Änderung der Datei neu gelesen
+func loadEPGGuide() (guide *epgGuide, err error) {
+
+	info, err := os.Stat(System.File.XML)
+	if err != nil {
+		err = errors.New(getErrMsg(1038))
+		return
+	}
+
+	epgGuideMutex.Lock()
+	defer epgGuideMutex.Unlock()
+
+	if g := epgGuideCache; g != nil && g.size == info.Size() && g.modTime.Equal(info.ModTime()) {
+		return g, nil
+	}
+
+	index, err := xmltvindex.BuildFile(System.File.XML)
+	if err != nil {
+		return
+	}
+
+	guide = &epgGuide{size: index.Size, modTime: index.ModTime, programmes: make(map[string][]EPGProgramme), text: make(map[string][]string)}
+
+	err = xmltvindex.Read(System.File.XML, index.Channels, func(element []byte) error {
+
+		var channel Channel
+		if err := xml.Unmarshal(element, &channel); err != nil {
+			return err
+		}
+
+		var c = EPGChannelGuide{Channel: channel.ID}
+		if len(channel.DisplayName) > 0 {
+			c.Name = channel.DisplayName[0].Value
+		}
+
+		guide.channels = append(guide.channels, c)
+		return nil
+	})
+
+	if err != nil {
+		return
+	}
+
+	for channelID, spans := range index.Programmes {
+
+		var programmes = make([]EPGProgramme, 0, len(spans))
+		var text = make([]string, 0, len(spans))
+
+		err = xmltvindex.Read(System.File.XML, spans, func(element []byte) error {
+
+			var program Program
+			if err := xml.Unmarshal(element, &program); err != nil {
+				return err
+			}
+
+			var p, ok = newEPGProgramme(&program)
+			if !ok {
+				return nil
+			}
+
+			programmes = append(programmes, p)
+			text = append(text, strings.ToLower(strings.Join(append([]string{p.Title, p.SubTitle, p.Desc}, p.Category...), "\n")))
+
+			return nil
+		})
+
+		if err != nil {
+			return
+		}
+
+		sort.Sort(epgProgrammesByStart{programmes, text})
+
+		guide.programmes[channelID] = programmes
+		guide.text[channelID] = text
+
+	}
+
+	epgGuideCache = guide
+
+	return
+}
+
+type epgProgrammesByStart struct {
+	programmes []EPGProgramme
+	text       []string
+}
+
+func (s epgProgrammesByStart) Len() int { return len(s.programmes) }
+func (s epgProgrammesByStart) Less(i, j int) bool {
+	return s.programmes[i].Start < s.programmes[j].Start
+}
+func (s epgProgrammesByStart) Swap(i, j int) {
+	s.programmes[i], s.programmes[j] = s.programmes[j], s.programmes[i]
+	s.text[i], s.text[j] = s.text[j], s.text[i]
+}
+
+// newEPGProgramme : Sendung für die API, false ohne gültige Zeiten
+func newEPGProgramme(program *Program) (p EPGProgramme, ok bool) {
+
+	start, err := parseXMLTVTime(program.Start)
+	if err != nil {
+		return
+	}
+
+	stop, err := parseXMLTVTime(program.Stop)
+	if err != nil {
+		return
+	}
+
+	p.Channel = program.Channel
+	p.Start = start.Unix()
+	p.Stop = stop.Unix()
+
+	if len(program.Title) > 0 {
+		p.Title = program.Title[0].Value
+	}
+
+	if len(program.SubTitle) > 0 {
+		p.SubTitle = program.SubTitle[0].Value
+	}
+
+	if len(program.Desc) > 0 && program.Desc[0] != nil {
+		p.Desc = program.Desc[0].Value
+	}
+
+	for _, category := range program.Category {
+		if category != nil && len(category.Value) > 0 {
+			p.Category = append(p.Category, category.Value)
+		}
+	}
+
+	if len(program.Poster) > 0 {
+		p.Icon = program.Poster[0].Src
+	}
+
+	for _, episode := range program.EpisodeNum {
+		if episode != nil && episode.System == "onscreen" {
+			p.EpisodeNum = episode.Value
+		}
+	}
+
+	return p, true
+}
+
+// selectChannels : Kanäle des EPG, alle wenn ids leer ist
+func (g *epgGuide) selectChannels(ids []string) (channels []EPGChannelGuide) {
+
+	if len(ids) == 0 {
+		return append([]EPGChannelGuide{}, g.channels...)
+	}
+
+	var wanted = make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	for _, channel := range g.channels {
+		if wanted[channel.Channel] {
+			channels = append(channels, channel)
+		}
+	}
+
+	return
+}
+
+// epgNowNext : Aktuelle und nächste Sendung der Kanäle
+func epgNowNext(ids []string, now time.Time) (channels []EPGChannelGuide, err error) {
+
+	guide, err := loadEPGGuide()
+	if err != nil {
+		return
+	}
+
+	channels = guide.selectChannels(ids)
+
+	for i := range channels {
+
+		var programmes = guide.programmes[channels[i].Channel]
+
+		// Erste Sendung, die noch nicht beendet ist
+		var n = sort.Search(len(programmes), func(j int) bool { return programmes[j].Stop > now.Unix() })
+
+		for ; n < len(programmes); n++ {
+
+			var p = programmes[n]
+
+			if p.Start <= now.Unix() && channels[i].Now == nil {
+				channels[i].Now = &p
+				continue
+			}
+
+			channels[i].Next = &p
+			break
+
+		}
+
+	}
+
+	return
+}
+
+// epgGrid : Sendungen der Kanäle zwischen start und stop
+func epgGrid(ids []string, start, stop time.Time) (channels []EPGChannelGuide, err error) {
+
+	if stop.Sub(start) > epgGridMax {
+		stop = start.Add(epgGridMax)
+	}
+
+	guide, err := loadEPGGuide()
+	if err != nil {
+		return
+	}
+
+	channels = guide.selectChannels(ids)
+
+	for i := range channels {
+
+		var programmes = guide.programmes[channels[i].Channel]
+		channels[i].Programmes = make([]EPGProgramme, 0)
+
+		for n := sort.Search(len(programmes), func(j int) bool { return programmes[j].Stop > start.Unix() }); n < len(programmes); n++ {
+
+			if programmes[n].Start >= stop.Unix() {
+				break
+			}
+
+			channels[i].Programmes = append(channels[i].Programmes, programmes[n])
+
+		}
+
+	}
+
+	return
+}
+
+// epgSearch : Sendungen, die nach start enden und alle Wörter der Abfrage im Titel, Untertitel,
+// in der Beschreibung oder den Kategorien enthalten. Nach Beginn sortiert, höchstens limit Ergebnisse.
+func epgSearch(query string, ids []string, start time.Time, limit int) (programmes []EPGProgramme, err error) {
+
+	var words = strings.Fields(strings.ToLower(query))
+	if len(words) == 0 {
+		err = errors.New(getErrMsg(1037))
+		return
+	}
+
+	if limit <= 0 {
+		limit = epgSearchDefault
+	}
+
+	guide, err := loadEPGGuide()
+	if err != nil {
+		return
+	}
+
+	programmes = make([]EPGProgramme, 0)
+
+	for _, channel := range guide.selectChannels(ids) {
+
+		var list = guide.programmes[channel.Channel]
+		var text = guide.text[channel.Channel]
+
+		for n := sort.Search(len(list), func(j int) bool { return list[j].Stop > start.Unix() }); n < len(list); n++ {
+
+			var match = true
+			for _, word := range words {
+				if !strings.Contains(text[n], word) {
+					match = false
+					break
+				}
+			}
+
+			if match {
+				programmes = append(programmes, list[n])
+			}
+
+		}
+
+	}
+
+	sort.SliceStable(programmes, func(i, j int) bool { return programmes[i].Start < programmes[j].Start })
+
+	if len(programmes) > limit {
+		programmes = programmes[:limit]
+	}
+
+	return
+}
+
+// parseEPGQueryTime : RFC 3339 oder Unix Zeit, def wenn der Wert leer ist
+func parseEPGQueryTime(value string, def time.Time) (t time.Time, err error) {
+
+	value = strings.TrimSpace(value)
+	if len(value) == 0 {
+		return def, nil
+	}
+
+	if unix, errInt := strconv.ParseInt(value, 10, 64); errInt == nil {
+		return time.Unix(unix, 0), nil
+	}
+
+	if t, err = time.Parse(time.RFC3339, value); err != nil {
+		err = errors.New(getErrMsg(1036))
+	}
+
+	return
+}
+
+// epgQuery : API und WebUI Befehle epg.nownext, epg.grid und epg.search
+func epgQuery(cmd string, query EPGQuery) (channels []EPGChannelGuide, programmes []EPGProgramme, err error) {
+
+	var now = time.Now()
+
+	start, err := parseEPGQueryTime(query.Start, now)
+	if err != nil {
+		return
+	}
+
+	switch cmd {
+
+	case "epg.nownext":
+		channels, err = epgNowNext(query.Channels, start)
+
+	case "epg.grid":
+		var stop time.Time
+		if stop, err = parseEPGQueryTime(query.Stop, start.Add(epgGridDefault)); err != nil {
+			return
+		}
+
+		if !stop.After(start) {
+			err = errors.New(getErrMsg(1036))
+			return
+		}
+
+		channels, err = epgGrid(query.Channels, start, stop)
+
+	case "epg.search":
+		programmes, err = epgSearch(query.Query, query.Channels, start, query.Limit)
+
+	}
+
+	return
+}
@@ -0,0 +1,166 @@
+package src
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EPG Zeitfenster: Sendungen, die vor mehr als epg.window.past Stunden geendet haben oder in mehr als
+// epg.window.future Tagen beginnen, werden entfernt. 0 behält alles.
+// Die Einstellungen epg.window.past / epg.window.future einer XMLTV Datei begrenzen eine einzelne Quelle zusätzlich,
+// die gleichnamigen Einstellungen begrenzen den zusammengeführten EPG jedes Kanals.
+
+// epgPruneStats : Anzahl der entfernten Sendungen
+type epgPruneStats struct {
+	Window     int
+	Overlap    int
+	ZeroLength int
+}
+
+func (s *epgPruneStats) add(other epgPruneStats) {
+	s.Window += other.Window
+	s.Overlap += other.Overlap
+	s.ZeroLength += other.ZeroLength
+}
+
+func (s epgPruneStats) total() int {
+	return s.Window + s.Overlap + s.ZeroLength
+}
+
+func (s epgPruneStats) String() string {
+	return fmt.Sprintf("%d outside the EPG window, %d overlapping, %d without duration", s.Window, s.Overlap, s.ZeroLength)
+}
+
+// xmltvFileID : Provider ID der lokalen XMLTV Datei (X....xml)
+func xmltvFileID(file string) string {
+	return strings.TrimSuffix(getFilenameFromPath(file), path.Ext(file))
+}
+
+// epgWindow : Zeitraum der Sendungen, Nullwerte sind unbegrenzt
+func epgWindow(now time.Time, pastHours, futureDays int) (from, to time.Time) {
+
+	if pastHours > 0 {
+		from = now.Add(-time.Duration(pastHours) * time.Hour)
+	}
+
+	if futureDays > 0 {
+		to = now.AddDate(0, 0, futureDays)
+	}
+
+	return
+}
+
+// sourceEPGWindow : EPG Zeitfenster der XMLTV Datei
+func sourceEPGWindow(now time.Time, file string) (from, to time.Time) {
+
+	var fileID = xmltvFileID(file)
+
+	past, _ := strconv.Atoi(getProviderParameter(fileID, "xmltv", "epg.window.past"))
+	future, _ := strconv.Atoi(getProviderParameter(fileID, "xmltv", "epg.window.future"))
+
+	return epgWindow(now, past, future)
+}
+
+// outsideEPGWindow : Sendung endet vor from oder beginnt nach to
+func outsideEPGWindow(program *Program, from, to time.Time) bool {
+
+	if !from.IsZero() {
+		if stop, err := parseXMLTVTime(program.Stop); err == nil && stop.Before(from) {
+			return true
+		}
+	}
+
+	if !to.IsZero() {
+		if start, err := parseXMLTVTime(program.Start); err == nil && start.After(to) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// filterEPGWindow : Sendungen innerhalb des Zeitfensters
+func filterEPGWindow(programs []*Program, from, to time.Time) (kept []*Program, dropped int) {
+
+	if from.IsZero() && to.IsZero() {
+		return programs, 0
+	}
+
+	kept = programs[:0]
+
+	for _, program := range programs {
+
+		if outsideEPGWindow(program, from, to) {
+			dropped++
+			continue
+		}
+
+		kept = append(kept, program)
+
+	}
+
+	return
+}
+
+// pruneProgramData : Wendet das globale EPG Zeitfenster an, entfernt Sendungen ohne Dauer und Sendungen,
+// die vor dem Ende der vorherigen Sendung beginnen
+func pruneProgramData(programs []*Program, now time.Time) (kept []*Program, stats epgPruneStats) {
+
+	var from, to = epgWindow(now, Settings.EPGWindowPast, Settings.EPGWindowFuture)
+	programs, stats.Window = filterEPGWindow(programs, from, to)
+
+	type timedProgram struct {
+		program     *Program
+		start, stop time.Time
+	}
+
+	var timed = make([]timedProgram, 0, len(programs))
+
+	for _, program := range programs {
+
+		start, errStart := parseXMLTVTime(program.Start)
+		stop, errStop := parseXMLTVTime(program.Stop)
+
+		// Ohne gültige Zeiten kann nichts geprüft werden, die Sendung bleibt wie bisher erhalten
+		if errStart != nil || errStop != nil {
+			timed = append(timed, timedProgram{program: program})
+			continue
+		}
+
+		if !stop.After(start) {
+			stats.ZeroLength++
+			continue
+		}
+
+		timed = append(timed, timedProgram{program: program, start: start, stop: stop})
+
+	}
+
+	sort.SliceStable(timed, func(i, j int) bool { return timed[i].start.Before(timed[j].start) })
+
+	var lastStop time.Time
+	kept = make([]*Program, 0, len(timed))
+
+	for _, t := range timed {
+
+		if !t.start.IsZero() {
+
+			if t.start.Before(lastStop) {
+				stats.Overlap++
+				continue
+			}
+
+			lastStop = t.stop
+
+		}
+
+		kept = append(kept, t.program)
+
+	}
+
+	return
+}
@@ -0,0 +1,207 @@
+package src
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"threadfin/src/internal/eventtime"
+)
+
+// Startzeit von Live Events (PPV Kanäle) aus dem Kanalnamen. Die erste Regel der Einstellungen (event.rules),
+// die zur Playlist und Gruppe des Kanals passt, bestimmt die Muster, die Zeitzone und die Dauer.
+// Kanäle ohne Regel verwenden die eingebauten Formate in der Serverzeit.
+
+var (
+	eventParserMutex sync.Mutex
+	eventParserCache = make(map[string]*eventtime.Parser)
+)
+
+// eventTimeRuleFor : Erste Regel für die Playlist und Gruppe des Kanals, eine leere Regel wenn keine passt
+func eventTimeRuleFor(rules []EventTimeRule, xepgChannel XEPGChannelStruct) EventTimeRule {
+
+	for _, rule := range rules {
+
+		if len(rule.Playlist) > 0 && rule.Playlist != xepgChannel.FileM3UID {
+			continue
+		}
+
+		if len(rule.Group) > 0 && !strings.EqualFold(rule.Group, xepgChannel.XGroupTitle) && !strings.EqualFold(rule.Group, xepgChannel.GroupTitle) {
+			continue
+		}
+
+		return rule
+	}
+
+	return EventTimeRule{}
+}
+
+// eventLocation : Zeitzone der Regel, Serverzeit wenn leer
+func eventLocation(timezone string) (location *time.Location, err error) {
+
+	if len(strings.TrimSpace(timezone)) == 0 {
+		return time.Local, nil
+	}
+
+	if location, ok := eventtime.Zone(timezone); ok {
+		return location, nil
+	}
+
+	return parseEPGTimezone(timezone)
+}
+
+// eventParser : Parser der Regel, wird einmal pro Regel erstellt
+func eventParser(rule EventTimeRule) (parser *eventtime.Parser, err error) {
+
+	var key = mapToJSON(rule)
+
+	eventParserMutex.Lock()
+	defer eventParserMutex.Unlock()
+
+	if parser, ok := eventParserCache[key]; ok {
+		return parser, nil
+	}
+
+	location, err := eventLocation(rule.Timezone)
+	if err != nil {
+		return
+	}
+
+	if parser, err = eventtime.New(rule.Patterns, location, rule.DayFirst); err != nil {
+		return
+	}
+
+	eventParserCache[key] = parser
+
+	return
+}
+
+// eventTime : Beginn und Ende des Events im Namen. Ohne Dauer dauert das Event bis zum Ende des Tages.
+func eventTime(rule EventTimeRule, name string, now time.Time) (start, stop time.Time, ok bool) {
+
+	parser, err := eventParser(rule)
+	if err != nil {
+		ShowError(err, 1041)
+		return
+	}
+
+	if start, ok = parser.Parse(name, now); !ok {
+		return
+	}
+
+	start = start.In(now.Location())
+
+	var duration = rule.Duration
+	if duration == 0 {
+		duration = Settings.EventDuration
+	}
+
+	if duration > 0 {
+		stop = start.Add(time.Duration(duration) * time.Minute)
+	} else {
+		stop = time.Date(start.Year(), start.Month(), start.Day(), 23, 59, 59, 0, start.Location())
+	}
+
+	return
+}
+
+// validateEventRules : Muster, Zeitzone und Dauer aller Regeln prüfen
+func validateEventRules(rules []EventTimeRule) (err error) {
+
+	for i, rule := range rules {
+
+		var ruleErr error
+
+		if _, ruleErr = eventLocation(rule.Timezone); ruleErr != nil {
+			ruleErr = fmt.Errorf("timezone: %s", ruleErr)
+		} else if _, ruleErr = eventtime.New(rule.Patterns, time.UTC, rule.DayFirst); ruleErr != nil {
+			ruleErr = fmt.Errorf("patterns: %s", ruleErr)
+		} else if rule.Duration < 0 || rule.Duration > 1440 {
+			ruleErr = fmt.Errorf("duration %d, use 1 - 1440 minutes or 0", rule.Duration)
+		}
+
+		if ruleErr != nil {
+			return fmt.Errorf("%s (%d: %s)", getErrMsg(1041), i+1, ruleErr)
+		}
+
+	}
+
+	return
+}
+
+// saveEventRules : Regeln ersetzen und die XMLTV Datei neu schreiben
+func saveEventRules(rules []EventTimeRule) (settings SettingsStruct, err error) {
+
+	if rules == nil {
+		rules = []EventTimeRule{}
+	}
+
+	if err = validateEventRules(rules); err != nil {
+		return
+	}
+
+	Settings.EventRules = rules
+
+	err = saveSettings(Settings)
+	if err != nil {
+		return
+	}
+
+	settings = Settings
+
+	queueXMLTVFile()
+
+	return
+}
+
+// parseEventName : Eventzeit eines Namens mit den Regeln (bei nil die gespeicherten Regeln), der Regel des Kanals xepgID oder
+// der ersten Regel ohne Playlist und Gruppe. Es wird nichts gespeichert.
+func parseEventName(xepgID, name string, rules []EventTimeRule) (result *EventTimeResult, err error) {
+
+	if rules == nil {
+		rules = Settings.EventRules
+	}
+
+	if err = validateEventRules(rules); err != nil {
+		return
+	}
+
+	var xepgChannel XEPGChannelStruct
+
+	if len(xepgID) > 0 {
+
+		xepgMutex.Lock()
+		dxc, ok := Data.XEPG.Channels[xepgID]
+		xepgMutex.Unlock()
+
+		if !ok {
+			err = errors.New(getErrMsg(1032))
+			return
+		}
+
+		if err = json.Unmarshal([]byte(mapToJSON(dxc)), &xepgChannel); err != nil {
+			return
+		}
+
+		if len(name) == 0 {
+			if name = xepgChannel.XName; len(name) == 0 {
+				name = xepgChannel.TvgName
+			}
+		}
+
+	}
+
+	result = &EventTimeResult{Name: name}
+
+	start, stop, ok := eventTime(eventTimeRuleFor(rules, xepgChannel), name, time.Now())
+	if ok {
+		result.Found = true
+		result.Start = start.Format(time.RFC3339)
+		result.Stop = stop.Format(time.RFC3339)
+	}
+
+	return
+}
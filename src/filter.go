@@ -0,0 +1,184 @@
+package src
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// previewFilter : Wendet geänderte Filter auf alle Streams an, ohne etwas zu speichern.
+// Die Änderungen werden wie in saveFilter mit den gespeicherten Filtern zusammengeführt (id -1 = neuer Filter, "delete" entfernt einen Filter).
+func previewFilter(candidates map[int64]interface{}) *FilterPreview {
+
+	var preview = &FilterPreview{Added: []FilterPreviewChannel{}, Removed: []FilterPreviewChannel{}, Filters: []FilterPreviewFilter{}}
+
+	var filters = mergeFilterSet(Settings.Filter, candidates)
+	list, invalid := compileFilters(filters)
+
+	for id, f := range filters {
+
+		var filter FilterStruct
+		json.Unmarshal([]byte(mapToJSON(f)), &filter)
+
+		var entry = FilterPreviewFilter{
+			ID:         id,
+			Name:       filter.Name,
+			Action:     filter.Action,
+			Priority:   filter.Priority,
+			Expression: filter.Expression,
+			Include:    []FilterPreviewChannel{},
+			Exclude:    []FilterPreviewChannel{},
+		}
+
+		if len(entry.Action) == 0 {
+			entry.Action = "include"
+		}
+
+		if len(entry.Expression) == 0 {
+			entry.Expression = legacyFilterExpression(filter)
+		}
+
+		if err, ok := invalid[id]; ok {
+			entry.Error = err.Error()
+		}
+
+		preview.Filters = append(preview.Filters, entry)
+
+	}
+
+	// Gleiche Reihenfolge, in der die Filter ausgewertet werden
+	sort.SliceStable(preview.Filters, func(i, j int) bool {
+
+		if preview.Filters[i].Priority != preview.Filters[j].Priority {
+			return preview.Filters[i].Priority > preview.Filters[j].Priority
+		}
+
+		return preview.Filters[i].ID < preview.Filters[j].ID
+	})
+
+	var position = make(map[int64]int)
+	for i, entry := range preview.Filters {
+		position[entry.ID] = i
+	}
+
+	var active = make(map[string]bool)
+	for _, s := range Data.Streams.Active {
+		if stream, ok := s.(map[string]string); ok {
+			active[filterStreamKey(stream)] = true
+		}
+	}
+
+	for _, s := range Data.Streams.All {
+
+		stream, ok := s.(map[string]string)
+		if !ok {
+			continue
+		}
+
+		var channel = FilterPreviewChannel{
+			Name:     stream["name"],
+			Group:    stream["group-title"],
+			TvgID:    stream["tvg-id"],
+			Playlist: stream["_file.m3u.name"],
+			Reason:   "no filter matched",
+		}
+
+		var status bool
+
+		for _, filter := range list {
+
+			matched, reason := filter.Expression.Explain(filterRecord(stream))
+			if !matched {
+				continue
+			}
+
+			channel.Filter = filter.Name
+			channel.Reason = reason
+
+			var entry = &preview.Filters[position[filter.ID]]
+
+			if filter.Action == "exclude" {
+				entry.Exclude = append(entry.Exclude, channel)
+			} else {
+				entry.Include = append(entry.Include, channel)
+				status = true
+			}
+
+			break
+		}
+
+		if Settings.IgnoreFilters {
+			status = true
+		}
+
+		var current = active[filterStreamKey(stream)]
+
+		switch {
+
+		case status && !current:
+			preview.Added = append(preview.Added, channel)
+
+		case !status && current:
+			preview.Removed = append(preview.Removed, channel)
+
+		}
+
+		if status {
+			preview.Active++
+		} else {
+			preview.Inactive++
+		}
+
+	}
+
+	return preview
+}
+
+// mergeFilterSet : Kopie der gespeicherten Filter mit den Änderungen
+func mergeFilterSet(saved, candidates map[int64]interface{}) (filters map[int64]interface{}) {
+
+	filters = make(map[int64]interface{})
+
+	for id, f := range saved {
+		filters[id] = jsonToMap(mapToJSON(f))
+	}
+
+	for id, c := range candidates {
+
+		data, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if _, ok := data["delete"]; ok {
+			delete(filters, id)
+			continue
+		}
+
+		// Neue Filter erhalten wie in saveFilter die kleinste freie ID (die ID entscheidet bei gleicher Priorität)
+		if id == -1 {
+			id = 0
+			for filters[id] != nil {
+				id++
+			}
+		}
+
+		filter, ok := filters[id].(map[string]interface{})
+		if !ok {
+			filter = map[string]interface{}{"active": true}
+		}
+
+		for key, value := range data {
+			filter[key] = value
+		}
+
+		filters[id] = filter
+
+	}
+
+	return
+}
+
+// filterStreamKey : Identifiziert einen Stream über zwei Filterdurchläufe
+func filterStreamKey(stream map[string]string) string {
+	return stream["_file.m3u.id"] + "|" + stream["url"]
+}
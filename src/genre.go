@@ -0,0 +1,157 @@
+package src
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"threadfin/src/internal/genre"
+)
+
+// Normalisierung der Kategorien der Sendungen auf die Standard Genres (Einstellung genre.normalize):
+//
+//	off      die Kategorien der Quelle werden unverändert übernommen
+//	add      die Genres werden zu den Kategorien der Quelle hinzugefügt
+//	replace  Kategorien mit einem Genre werden durch das Genre ersetzt, die anderen Kategorien bleiben erhalten
+//
+// genre.rules werden vor den eingebauten Regeln geprüft, genre.infer verwendet den Titel, wenn keine Kategorie ein Genre hat.
+// Kategorien mit einer Regel für das Genre None werden in beiden Modi entfernt.
+
+var (
+	genreMapperMutex sync.Mutex
+	genreMapperKey   string
+	genreMapperCache *genre.Mapper
+)
+
+// genreRules : Regeln der Einstellungen für das Paket genre
+func genreRules(rules []GenreRule) (list []genre.Rule) {
+
+	list = make([]genre.Rule, 0, len(rules))
+
+	for _, rule := range rules {
+		list = append(list, genre.Rule{Pattern: rule.Pattern, Genre: rule.Genre, Source: rule.Source, Title: rule.Title})
+	}
+
+	return
+}
+
+// genreMapper : Mapper der aktuellen Einstellungen, wird nach einer Änderung neu erstellt
+func genreMapper() (mapper *genre.Mapper, err error) {
+
+	var key = fmt.Sprintf("%t %s", Settings.GenreInfer, mapToJSON(Settings.GenreRules))
+
+	genreMapperMutex.Lock()
+	defer genreMapperMutex.Unlock()
+
+	if genreMapperCache != nil && genreMapperKey == key {
+		return genreMapperCache, nil
+	}
+
+	if mapper, err = genre.New(genreRules(Settings.GenreRules), Settings.GenreInfer); err != nil {
+		return
+	}
+
+	genreMapperKey, genreMapperCache = key, mapper
+
+	return
+}
+
+// normalizeGenres : Genres der Kategorien (und des Titels) der Sendung, siehe genre.normalize
+func normalizeGenres(program *Program, source EPGSource) {
+
+	var mode = Settings.GenreNormalize
+	if mode != "add" && mode != "replace" {
+		return
+	}
+
+	mapper, err := genreMapper()
+	if err != nil {
+		ShowError(err, 1044)
+		return
+	}
+
+	var sourceID = xmltvFileID(source.File)
+	var categories = make([]*Category, 0, len(program.Category)+1)
+	var present = make(map[string]bool)
+	var found bool
+
+	var add = func(category *Category) {
+		if key := strings.ToLower(category.Value); !present[key] {
+			present[key] = true
+			categories = append(categories, category)
+		}
+	}
+
+	var genres []string
+
+	for _, category := range program.Category {
+
+		if category == nil {
+			continue
+		}
+
+		g, ok := mapper.Category(sourceID, category.Value)
+		if g == genre.None {
+			continue
+		}
+
+		if ok {
+			found = true
+			genres = append(genres, g)
+		}
+
+		if mode == "replace" && ok {
+			add(&Category{Value: g, Lang: "en"})
+			continue
+		}
+
+		add(category)
+
+	}
+
+	if !found && Settings.GenreInfer && len(program.Title) > 0 {
+		if g, ok := mapper.Title(sourceID, program.Title[0].Value); ok {
+			genres = append(genres, g)
+		}
+	}
+
+	// Genres, die bereits eine Kategorie sind (x-category "sports"), werden nicht erneut hinzugefügt
+	for _, g := range genres {
+		add(&Category{Value: g, Lang: "en"})
+	}
+
+	program.Category = categories
+}
+
+// saveGenreRules : Regeln ersetzen und die XMLTV Datei neu schreiben
+func saveGenreRules(rules []GenreRule) (settings SettingsStruct, err error) {
+
+	if rules == nil {
+		rules = []GenreRule{}
+	}
+
+	// Genres werden in ihrer Standardschreibweise gespeichert
+	for i := range rules {
+		if g, ok := genre.Canonical(rules[i].Genre); ok {
+			rules[i].Genre = g
+		}
+	}
+
+	if _, err = genre.New(genreRules(rules), false); err != nil {
+		err = fmt.Errorf("%s (%s)", getErrMsg(1044), err)
+		return
+	}
+
+	Settings.GenreRules = rules
+
+	err = saveSettings(Settings)
+	if err != nil {
+		return
+	}
+
+	settings = Settings
+
+	queueXMLTVFile()
+
+	return
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
+	"path"
+	"sort"
 )
 
 func makeInteraceFromHDHR(content []byte, playlistName, id string) (channels []interface{}, err error) {
@@ -36,24 +38,29 @@ func makeInteraceFromHDHR(content []byte, playlistName, id string) (channels []i
 	return
 }
 
-func getCapability() (xmlContent []byte, err error) {
+func getCapability(device *VirtualDevice) (xmlContent []byte, err error) {
 
 	var capability Capability
 	var buffer bytes.Buffer
+	var deviceID, name, baseURL, _ = deviceInfo(device)
 
 	capability.Xmlns = "urn:schemas-upnp-org:device-1-0"
-	capability.URLBase = System.ServerProtocol.WEB + "://" + System.Domain
+	capability.URLBase = baseURL
 
 	capability.SpecVersion.Major = 1
 	capability.SpecVersion.Minor = 0
 
 	capability.Device.DeviceType = "urn:schemas-upnp-org:device:MediaServer:1"
-	capability.Device.FriendlyName = System.Name
+	capability.Device.FriendlyName = name
 	capability.Device.Manufacturer = "Silicondust"
 	capability.Device.ModelName = "HDHomeRun CONNECT"
 	capability.Device.ModelNumber = "HDHR4-2US"
-	capability.Device.SerialNumber = System.DeviceID
-	capability.Device.UDN = "uuid:" + System.DeviceID
+	capability.Device.SerialNumber = deviceID
+	capability.Device.UDN = "uuid:" + deviceID
+
+	if device == nil {
+		capability.Device.ServiceList = dlnaServices()
+	}
 
 	output, err := xml.MarshalIndent(capability, " ", "  ")
 	if err != nil {
@@ -67,21 +74,26 @@ func getCapability() (xmlContent []byte, err error) {
 	return
 }
 
-func getDiscover() (jsonContent []byte, err error) {
+func getDiscover(device *VirtualDevice) (jsonContent []byte, err error) {
 
 	var discover Discover
+	var deviceID, name, baseURL, tuner = deviceInfo(device)
 
-	discover.BaseURL = System.ServerProtocol.WEB + "://" + System.Domain
+	discover.BaseURL = baseURL
 	discover.DeviceAuth = System.AppName
-	discover.DeviceID = System.DeviceID
+	discover.DeviceID = deviceID
 	discover.FirmwareName = "bin_" + System.Version
 	discover.FirmwareVersion = System.Version
-	discover.FriendlyName = System.Name
+	discover.FriendlyName = name
 
 	discover.LineupURL = fmt.Sprintf("%s://%s/lineup.json", System.ServerProtocol.DVR, System.Domain)
+	if device != nil {
+		discover.LineupURL = baseURL + "/lineup.json"
+	}
+
 	discover.Manufacturer = "Silicondust"
 	discover.ModelNumber = "HDHR4-2US"
-	discover.TunerCount = Settings.Tuner
+	discover.TunerCount = tuner
 
 	jsonContent, err = json.MarshalIndent(discover, "", "  ")
 
@@ -93,7 +105,7 @@ func getLineupStatus() (jsonContent []byte, err error) {
 	var lineupStatus LineupStatus
 
 	lineupStatus.ScanInProgress = System.ScanInProgress
-	lineupStatus.ScanPossible = 0
+	lineupStatus.ScanPossible = 1
 	lineupStatus.Source = "Cable"
 	lineupStatus.SourceList = []string{"Cable"}
 
@@ -102,7 +114,8 @@ func getLineupStatus() (jsonContent []byte, err error) {
 	return
 }
 
-func getLineup() (jsonContent []byte, err error) {
+// Lineup des Geräts (nil = Hauptgerät), virtuelle Geräte enthalten nur die ausgewählten Kanäle
+func getLineup(device *VirtualDevice) (jsonContent []byte, err error) {
 
 	var lineup Lineup
 
@@ -118,6 +131,10 @@ func getLineup() (jsonContent []byte, err error) {
 				return
 			}
 
+			if !device.selects(XEPGChannelStruct{Name: m3uChannel.Name, GroupTitle: m3uChannel.GroupTitle, TvgID: m3uChannel.TvgID, URL: m3uChannel.URL, FileM3UName: m3uChannel.FileM3UName, Values: m3uChannel.Values}) {
+				continue
+			}
+
 			var stream LineupStream
 			stream.GuideName = m3uChannel.Name
 			switch len(m3uChannel.UUIDValue) {
@@ -154,7 +171,7 @@ func getLineup() (jsonContent []byte, err error) {
 				return
 			}
 
-			if xepgChannel.XActive == true && !xepgChannel.XHideChannel {
+			if xepgChannel.XActive == true && !xepgChannel.XHideChannel && device.selects(xepgChannel) {
 				var stream LineupStream
 				stream.GuideName = xepgChannel.XName
 				stream.GuideNumber = xepgChannel.XChannelID
@@ -234,3 +251,151 @@ func getGuideNumberPMS(channelName string) (pmsID string, err error) {
 
 	return
 }
+
+// Sendernummer (GuideNumber) im Lineup des Geräts suchen, für /auto/v<number> und /tuner<n>/v<number>
+func getLineupStream(device *VirtualDevice, guideNumber string) (urlID string, ok bool) {
+
+	content, err := getLineup(device)
+	if err != nil {
+		return
+	}
+
+	var lineup Lineup
+	if err = json.Unmarshal(content, &lineup); err != nil {
+		return
+	}
+
+	for _, stream := range lineup {
+		if stream.GuideNumber == guideNumber {
+			return path.Base(stream.URL), true
+		}
+	}
+
+	return
+}
+
+// Tuner Status /status.json, jeder Stream eines Kanals aus dem Lineup des Geräts belegt einen Tuner
+func getTunerStatus(device *VirtualDevice) (jsonContent []byte, err error) {
+
+	var _, _, _, tuner = deviceInfo(device)
+	var status = make([]TunerStatus, 0, tuner)
+
+	content, err := getLineup(device)
+	if err != nil {
+		return
+	}
+
+	var lineup Lineup
+	if err = json.Unmarshal(content, &lineup); err != nil {
+		return
+	}
+
+	var urlIDs = make(map[string]bool, len(lineup))
+	for _, stream := range lineup {
+		urlIDs[path.Base(stream.URL)] = true
+	}
+
+	// Streams anderer Geräte werden nicht aufgeführt
+	var numbers = make(map[string]string)
+	for urlID, streamInfo := range Data.Cache.StreamingURLS {
+		if urlIDs[urlID] {
+			numbers[streamInfo.URL] = streamInfo.ChannelNumber
+		}
+	}
+
+	BufferInformation.Range(func(key, value interface{}) bool {
+
+		playlist, ok := value.(Playlist)
+		if !ok {
+			return true
+		}
+
+		var ids = make([]int, 0, len(playlist.Streams))
+		for id := range playlist.Streams {
+			ids = append(ids, id)
+		}
+
+		sort.Ints(ids)
+
+		for _, id := range ids {
+
+			if client, ok := playlist.Clients[id]; !ok || client.Connection <= 0 {
+				continue
+			}
+
+			var stream = playlist.Streams[id]
+
+			number, ok := numbers[stream.URL]
+			if !ok {
+				continue
+			}
+
+			if len(status) >= tuner {
+				return false
+			}
+
+			status = append(status, TunerStatus{
+				VctNumber:             number,
+				VctName:               stream.ChannelName,
+				SignalStrengthPercent: 100,
+				SignalQualityPercent:  100,
+				SymbolQualityPercent:  100,
+				NetworkRate:           stream.NetworkBandwidth * 8,
+			})
+
+		}
+
+		return true
+	})
+
+	// Freie Tuner werden nur mit ihrem Namen aufgeführt
+	for len(status) < tuner {
+		status = append(status, TunerStatus{})
+	}
+
+	for i := range status {
+		status[i].Resource = fmt.Sprintf("tuner%d", i)
+	}
+
+	jsonContent, err = json.MarshalIndent(status, "", "  ")
+
+	return
+}
+
+// Sendersuchlauf (lineup.post?scan=start): Playlists aktualisieren und die DVR Datenbank neu erstellen
+func startLineupScan() {
+
+	startJob("scan", "lineup.scan", "database", "Channel scan", func(job *jobEntry) (err error) {
+
+		for _, fileType := range []string{"m3u", "hdhr"} {
+
+			if job.canceled() {
+				return
+			}
+
+			err = updateProviderData(job, fileType, "")
+			if err != nil {
+				return
+			}
+
+		}
+
+		if !job.canceled() {
+			triggerScheduleJob("xepg.rebuild")
+		}
+
+		return
+	})
+
+}
+
+// Sendersuchlauf abbrechen (lineup.post?scan=abort)
+func abortLineupScan() {
+
+	for _, job := range getJobs() {
+		if job.Key == "lineup.scan" && (job.Status == "queued" || job.Status == "running") {
+			cancelJob(job.ID)
+		}
+	}
+
+}
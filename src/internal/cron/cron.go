@@ -0,0 +1,297 @@
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule : Returns the next activation time after t
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+// SpecSchedule : Classic cron expression (minute hour day-of-month month day-of-week)
+type SpecSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+}
+
+// EverySchedule : Fixed interval (@every 6h)
+type EverySchedule struct {
+	Interval time.Duration
+}
+
+// AnySchedule : Combination of several schedules, the earliest activation wins
+type AnySchedule []Schedule
+
+type bounds struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minutes = bounds{0, 59, nil}
+	hours   = bounds{0, 23, nil}
+	doms    = bounds{1, 31, nil}
+	months  = bounds{1, 12, map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dows = bounds{0, 7, map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse : Parse a cron expression or an interval
+// Supported: "m h dom mon dow", "@every <duration>" and @hourly, @daily, @weekly, @monthly, @yearly
+func Parse(spec string) (schedule Schedule, err error) {
+
+	spec = strings.TrimSpace(spec)
+
+	if len(spec) == 0 {
+		err = errors.New("empty schedule")
+		return
+	}
+
+	if strings.HasPrefix(spec, "@every") {
+
+		interval, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid interval %q: %s", spec, err)
+		}
+
+		if interval < time.Minute {
+			return nil, fmt.Errorf("invalid interval %q: must be at least one minute", spec)
+		}
+
+		return EverySchedule{Interval: interval}, nil
+	}
+
+	if value, ok := descriptors[strings.ToLower(spec)]; ok {
+		spec = value
+	}
+
+	var fields = strings.Fields(spec)
+	if len(fields) != 5 {
+		err = fmt.Errorf("invalid schedule %q: expected 5 fields, found %d", spec, len(fields))
+		return
+	}
+
+	var s SpecSchedule
+
+	if s.minute, err = parseField(fields[0], minutes); err != nil {
+		return
+	}
+
+	if s.hour, err = parseField(fields[1], hours); err != nil {
+		return
+	}
+
+	if s.dom, err = parseField(fields[2], doms); err != nil {
+		return
+	}
+
+	if s.month, err = parseField(fields[3], months); err != nil {
+		return
+	}
+
+	if s.dow, err = parseField(fields[4], dows); err != nil {
+		return
+	}
+
+	// Sunday may also be written as 7
+	if s.dow&(1<<7) > 0 {
+		s.dow |= 1
+	}
+
+	s.domStar = fields[2] == "*" || fields[2] == "?"
+	s.dowStar = fields[4] == "*" || fields[4] == "?"
+
+	return s, nil
+}
+
+// ParseClock : Convert HHMM clock times (Settings.Update) into a schedule
+func ParseClock(times []string) (schedule Schedule, err error) {
+
+	var schedules AnySchedule
+
+	for _, value := range times {
+
+		t, err := time.Parse("1504", value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time %q", value)
+		}
+
+		s, err := Parse(fmt.Sprintf("%d %d * * *", t.Minute(), t.Hour()))
+		if err != nil {
+			return nil, err
+		}
+
+		schedules = append(schedules, s)
+
+	}
+
+	if len(schedules) == 0 {
+		err = errors.New("no update times")
+		return
+	}
+
+	return schedules, nil
+}
+
+func parseField(field string, b bounds) (bits uint64, err error) {
+
+	for _, part := range strings.Split(field, ",") {
+
+		var step = 1
+		var start, end int
+
+		var rangeAndStep = strings.SplitN(part, "/", 2)
+		if len(rangeAndStep) == 2 {
+			step, err = strconv.Atoi(rangeAndStep[1])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+		}
+
+		switch value := rangeAndStep[0]; {
+
+		case value == "*" || value == "?":
+			start, end = b.min, b.max
+
+		case strings.Contains(value, "-"):
+			var lowHigh = strings.SplitN(value, "-", 2)
+			if start, err = parseValue(lowHigh[0], b); err != nil {
+				return
+			}
+			if end, err = parseValue(lowHigh[1], b); err != nil {
+				return
+			}
+
+		default:
+			if start, err = parseValue(value, b); err != nil {
+				return
+			}
+			end = start
+			if len(rangeAndStep) == 2 {
+				end = b.max
+			}
+
+		}
+
+		if start > end {
+			return 0, fmt.Errorf("invalid range %q", part)
+		}
+
+		for i := start; i <= end; i += step {
+			bits |= 1 << uint(i)
+		}
+
+	}
+
+	return
+}
+
+func parseValue(value string, b bounds) (i int, err error) {
+
+	if n, ok := b.names[strings.ToLower(value)]; ok {
+		return n, nil
+	}
+
+	i, err = strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", value)
+	}
+
+	if i < b.min || i > b.max {
+		return 0, fmt.Errorf("value %d out of range [%d-%d]", i, b.min, b.max)
+	}
+
+	return
+}
+
+// Next : Next activation of the cron expression
+func (s SpecSchedule) Next(t time.Time) time.Time {
+
+	t = t.Add(time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+
+	// Give up after five years (e.g. 30 February)
+	var limit = t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (s SpecSchedule) dayMatches(t time.Time) bool {
+
+	var domMatch = s.dom&(1<<uint(t.Day())) > 0
+	var dowMatch = s.dow&(1<<uint(t.Weekday())) > 0
+
+	// Like Vixie cron: if both fields are restricted, either one matching is enough
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+
+	return domMatch || dowMatch
+}
+
+// Next : Next activation of the interval
+func (e EverySchedule) Next(t time.Time) time.Time {
+	return t.Add(e.Interval - time.Duration(t.Nanosecond()))
+}
+
+// Next : Earliest activation of all schedules
+func (a AnySchedule) Next(t time.Time) (next time.Time) {
+
+	for _, s := range a {
+
+		var n = s.Next(t)
+		if n.IsZero() {
+			continue
+		}
+
+		if next.IsZero() || n.Before(next) {
+			next = n
+		}
+
+	}
+
+	return
+}
@@ -0,0 +1,73 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+
+	var start = time.Date(2024, time.October, 18, 10, 7, 30, 0, time.UTC) // Friday
+
+	var tests = []struct {
+		spec string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2024, time.October, 18, 10, 15, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2024, time.October, 19, 3, 0, 0, 0, time.UTC)},
+		{"30 10 * * *", time.Date(2024, time.October, 18, 10, 30, 0, 0, time.UTC)},
+		{"0 0 * * mon", time.Date(2024, time.October, 21, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2024, time.October, 20, 12, 0, 0, 0, time.UTC)},
+		{"0 6 1 * *", time.Date(2024, time.November, 1, 6, 0, 0, 0, time.UTC)},
+		{"0 6-8/2 * * *", time.Date(2024, time.October, 19, 6, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, time.October, 18, 11, 0, 0, 0, time.UTC)},
+		{"@every 6h", time.Date(2024, time.October, 18, 16, 7, 30, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+
+		schedule, err := Parse(test.spec)
+		if err != nil {
+			t.Errorf("%s: %s", test.spec, err)
+			continue
+		}
+
+		if got := schedule.Next(start); !got.Equal(test.want) {
+			t.Errorf("%s: got %s, want %s", test.spec, got, test.want)
+		}
+
+	}
+
+}
+
+func TestParseErrors(t *testing.T) {
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "5-1 * * * *", "*/0 * * * *", "@every 10s", "@every soon"} {
+
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("%q: expected error", spec)
+		}
+
+	}
+
+}
+
+func TestParseClock(t *testing.T) {
+
+	schedule, err := ParseClock([]string{"2330", "0415"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var start = time.Date(2024, time.October, 18, 23, 45, 0, 0, time.UTC)
+	var want = time.Date(2024, time.October, 19, 4, 15, 0, 0, time.UTC)
+
+	if got := schedule.Next(start); !got.Equal(want) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	if _, err = ParseClock([]string{"2561"}); err == nil {
+		t.Error("expected error for invalid clock time")
+	}
+
+}
@@ -0,0 +1,239 @@
+// Package dlna contains the UPnP AV parts of a MediaServer: SOAP actions, DIDL-Lite results
+// and the service descriptions of the ContentDirectory and ConnectionManager.
+package dlna
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Service types
+const (
+	ContentDirectory  = "urn:schemas-upnp-org:service:ContentDirectory:1"
+	ConnectionManager = "urn:schemas-upnp-org:service:ConnectionManager:1"
+	MediaServer       = "urn:schemas-upnp-org:device:MediaServer:1"
+)
+
+// Classes
+const (
+	ClassFolder  = "object.container.storageFolder"
+	ClassChannel = "object.item.videoItem.videoBroadcast"
+)
+
+// ProtocolInfo : MPEG-TS over HTTP, no seeking
+const ProtocolInfo = "http-get:*:video/mpeg:DLNA.ORG_OP=00;DLNA.ORG_CI=0;DLNA.ORG_FLAGS=01700000000000000000000000000000"
+
+// UPnP error codes
+const (
+	ErrInvalidAction = 401
+	ErrInvalidArgs   = 402
+	ErrNoSuchObject  = 701
+)
+
+// Object : Container or item of the ContentDirectory
+type Object struct {
+	ID         string
+	ParentID   string
+	Title      string
+	Class      string
+	ChildCount int
+
+	// Items only
+	ChannelNumber string
+	Icon          string
+	URL           string
+}
+
+// Action : SOAP request
+type Action struct {
+	Service string
+	Name    string
+	Args    map[string]string
+}
+
+// Int : Numeric argument, def if it is missing or invalid
+func (a Action) Int(name string, def int) int {
+
+	if n, err := strconv.Atoi(strings.TrimSpace(a.Args[name])); err == nil && n >= 0 {
+		return n
+	}
+
+	return def
+}
+
+// ParseAction : Reads the action from the SOAP body
+func ParseAction(r io.Reader) (action Action, err error) {
+
+	var envelope struct {
+		Body struct {
+			Action struct {
+				XMLName xml.Name
+				Args    []struct {
+					XMLName xml.Name
+					Value   string `xml:",chardata"`
+				} `xml:",any"`
+			} `xml:",any"`
+		} `xml:"Body"`
+	}
+
+	if err = xml.NewDecoder(r).Decode(&envelope); err != nil {
+		return
+	}
+
+	var a = envelope.Body.Action
+	if len(a.XMLName.Local) == 0 {
+		err = errors.New("dlna: SOAP body without action")
+		return
+	}
+
+	action.Service = a.XMLName.Space
+	action.Name = a.XMLName.Local
+	action.Args = make(map[string]string, len(a.Args))
+
+	for _, arg := range a.Args {
+		action.Args[arg.XMLName.Local] = arg.Value
+	}
+
+	return
+}
+
+// Response : SOAP response of the action, the values are written in the given order (name, value, name, value, ...)
+func Response(service, action string, values ...string) []byte {
+
+	var b strings.Builder
+
+	b.WriteString(xml.Header)
+	b.WriteString(`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`)
+	fmt.Fprintf(&b, `<u:%sResponse xmlns:u="%s">`, action, service)
+
+	for i := 0; i+1 < len(values); i += 2 {
+		fmt.Fprintf(&b, "<%s>%s</%s>", values[i], escape(values[i+1]), values[i])
+	}
+
+	fmt.Fprintf(&b, `</u:%sResponse></s:Body></s:Envelope>`, action)
+
+	return []byte(b.String())
+}
+
+// Fault : SOAP fault with the UPnP error code, sent with HTTP status 500
+func Fault(code int, description string) []byte {
+
+	var b strings.Builder
+
+	b.WriteString(xml.Header)
+	b.WriteString(`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`)
+	b.WriteString(`<s:Fault><faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring><detail>`)
+	fmt.Fprintf(&b, `<UPnPError xmlns="urn:schemas-upnp-org:control-1-0"><errorCode>%d</errorCode><errorDescription>%s</errorDescription></UPnPError>`, code, escape(description))
+	b.WriteString(`</detail></s:Fault></s:Body></s:Envelope>`)
+
+	return []byte(b.String())
+}
+
+// DIDL : DIDL-Lite document of the objects (Result of Browse)
+func DIDL(objects []Object) string {
+
+	var b strings.Builder
+
+	b.WriteString(`<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/" xmlns:dlna="urn:schemas-dlna-org:metadata-1-0/">`)
+
+	for _, o := range objects {
+
+		if o.Class == ClassFolder {
+
+			fmt.Fprintf(&b, `<container id="%s" parentID="%s" childCount="%d" restricted="1" searchable="0">`, escape(o.ID), escape(o.ParentID), o.ChildCount)
+			fmt.Fprintf(&b, `<dc:title>%s</dc:title><upnp:class>%s</upnp:class>`, escape(o.Title), o.Class)
+			b.WriteString(`</container>`)
+
+			continue
+		}
+
+		fmt.Fprintf(&b, `<item id="%s" parentID="%s" restricted="1">`, escape(o.ID), escape(o.ParentID))
+		fmt.Fprintf(&b, `<dc:title>%s</dc:title><upnp:class>%s</upnp:class>`, escape(o.Title), o.Class)
+
+		if len(o.ChannelNumber) > 0 {
+			fmt.Fprintf(&b, `<upnp:channelNr>%s</upnp:channelNr>`, escape(o.ChannelNumber))
+		}
+
+		fmt.Fprintf(&b, `<upnp:channelName>%s</upnp:channelName>`, escape(o.Title))
+
+		if len(o.Icon) > 0 {
+			fmt.Fprintf(&b, `<upnp:albumArtURI>%s</upnp:albumArtURI><upnp:icon>%s</upnp:icon>`, escape(o.Icon), escape(o.Icon))
+		}
+
+		fmt.Fprintf(&b, `<res protocolInfo="%s">%s</res>`, ProtocolInfo, escape(o.URL))
+		b.WriteString(`</item>`)
+
+	}
+
+	b.WriteString(`</DIDL-Lite>`)
+
+	return b.String()
+}
+
+func escape(s string) string {
+
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+
+	return b.String()
+}
+
+// Error : UPnP error of an action
+type Error struct {
+	Code        int
+	Description string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("dlna: %d %s", e.Code, e.Description)
+}
+
+// Browse : BrowseMetadata returns the object itself, BrowseDirectChildren its children from start on
+// (count 0 means all). total is the number of all children.
+func Browse(objects []Object, id, flag string, start, count int) (list []Object, total int, err error) {
+
+	var found bool
+	for _, o := range objects {
+		if o.ID == id {
+			found = true
+			if flag == "BrowseMetadata" {
+				return []Object{o}, 1, nil
+			}
+			break
+		}
+	}
+
+	if !found {
+		return nil, 0, &Error{Code: ErrNoSuchObject, Description: "No such object"}
+	}
+
+	if flag != "BrowseDirectChildren" {
+		return nil, 0, &Error{Code: ErrInvalidArgs, Description: "Invalid BrowseFlag"}
+	}
+
+	var children []Object
+	for _, o := range objects {
+		if o.ParentID == id {
+			children = append(children, o)
+		}
+	}
+
+	total = len(children)
+
+	if start > total {
+		start = total
+	}
+
+	var end = total
+	if count > 0 && start+count < total {
+		end = start + count
+	}
+
+	list = children[start:end]
+
+	return
+}
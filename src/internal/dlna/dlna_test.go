@@ -0,0 +1,96 @@
+package dlna
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+var objects = []Object{
+	{ID: "0", ParentID: "-1", Title: "Threadfin", Class: ClassFolder, ChildCount: 2},
+	{ID: "g-news", ParentID: "0", Title: "News", Class: ClassFolder, ChildCount: 2},
+	{ID: "g-sports", ParentID: "0", Title: "Sports & More", Class: ClassFolder, ChildCount: 1},
+	{ID: "c-1", ParentID: "g-news", Title: "CNN", Class: ClassChannel, ChannelNumber: "1", URL: "http://host/stream/a"},
+	{ID: "c-2", ParentID: "g-news", Title: "BBC <World>", Class: ClassChannel, ChannelNumber: "2", URL: "http://host/stream/b", Icon: "http://host/images/b.png"},
+	{ID: "c-3", ParentID: "g-sports", Title: "ESPN", Class: ClassChannel, ChannelNumber: "3", URL: "http://host/stream/c"},
+}
+
+func TestParseAction(t *testing.T) {
+
+	var body = `<?xml version="1.0"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+  <s:Body>
+    <u:Browse xmlns:u="urn:schemas-upnp-org:service:ContentDirectory:1">
+      <ObjectID>g-news</ObjectID>
+      <BrowseFlag>BrowseDirectChildren</BrowseFlag>
+      <Filter>*</Filter>
+      <StartingIndex>1</StartingIndex>
+      <RequestedCount>10</RequestedCount>
+      <SortCriteria></SortCriteria>
+    </u:Browse>
+  </s:Body>
+</s:Envelope>`
+
+	action, err := ParseAction(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if action.Service != ContentDirectory || action.Name != "Browse" {
+		t.Errorf("action = %s#%s", action.Service, action.Name)
+	}
+
+	if action.Args["ObjectID"] != "g-news" || action.Int("StartingIndex", 0) != 1 || action.Int("RequestedCount", 0) != 10 {
+		t.Errorf("args = %v", action.Args)
+	}
+
+}
+
+func TestBrowse(t *testing.T) {
+
+	list, total, err := Browse(objects, "0", "BrowseDirectChildren", 0, 0)
+	if err != nil || total != 2 || len(list) != 2 || list[0].ID != "g-news" {
+		t.Errorf("root: %v, %d, %v", list, total, err)
+	}
+
+	list, total, err = Browse(objects, "g-news", "BrowseDirectChildren", 1, 1)
+	if err != nil || total != 2 || len(list) != 1 || list[0].ID != "c-2" {
+		t.Errorf("paged: %v, %d, %v", list, total, err)
+	}
+
+	list, total, err = Browse(objects, "c-3", "BrowseMetadata", 0, 0)
+	if err != nil || total != 1 || list[0].Title != "ESPN" {
+		t.Errorf("metadata: %v, %d, %v", list, total, err)
+	}
+
+	_, _, err = Browse(objects, "missing", "BrowseDirectChildren", 0, 0)
+
+	var upnpErr *Error
+	if !errors.As(err, &upnpErr) || upnpErr.Code != ErrNoSuchObject {
+		t.Errorf("missing object: err = %v", err)
+	}
+
+}
+
+func TestDIDL(t *testing.T) {
+
+	var didl = DIDL(objects[2:5])
+
+	for _, want := range []string{
+		`<container id="g-sports" parentID="0" childCount="1" restricted="1" searchable="0"><dc:title>Sports &amp; More</dc:title>`,
+		`<item id="c-2" parentID="g-news" restricted="1"><dc:title>BBC &lt;World&gt;</dc:title><upnp:class>object.item.videoItem.videoBroadcast</upnp:class><upnp:channelNr>2</upnp:channelNr>`,
+		`<upnp:albumArtURI>http://host/images/b.png</upnp:albumArtURI>`,
+		`<res protocolInfo="` + ProtocolInfo + `">http://host/stream/a</res>`,
+	} {
+		if !strings.Contains(didl, want) {
+			t.Errorf("DIDL does not contain %s\n%s", want, didl)
+		}
+	}
+
+	// The DIDL document is escaped once more inside the SOAP response
+	var response = string(Response(ContentDirectory, "Browse", "Result", didl, "NumberReturned", "3"))
+	if !strings.Contains(response, "&lt;DIDL-Lite") || !strings.Contains(response, "<NumberReturned>3</NumberReturned>") {
+		t.Errorf("response = %s", response)
+	}
+
+}
@@ -0,0 +1,395 @@
+// Package eventtime finds the start time of a live event in a channel name.
+//
+// Recognised formats (the first match in the name is used):
+//
+//	2024-10-18T19:00Z, 2024-10-18 19:00 +01:00     ISO 8601
+//	17:30, 7:30 PM, 7pm, 21h00                      24h and 12h clock (a bare number is not a time)
+//	10/18 8:00 PM, 18.10. 20:00, 18/10/2024 19:45   numeric dates, month first unless day first is set or the day is > 12
+//	Sat 19 Oct 20:00, Oct 19th 8pm                  English month names
+//
+// A zone after the time can be an abbreviation (ET, PT, UK, BST, CET, CEST, ...), an IANA name
+// (Europe/London) or an offset (UTC+2, GMT-5). Without a zone the time is read in the location of the parser.
+// Custom patterns are regular expressions with the named groups year, month, day, hour, minute, ampm and zone,
+// they are tried before the built-in formats.
+package eventtime
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// zone : IANA location of an abbreviation, offset (seconds) if the location can not be loaded
+type zone struct {
+	name   string
+	offset int
+}
+
+var zones = map[string]zone{
+	"UTC": {"UTC", 0}, "GMT": {"UTC", 0}, "Z": {"UTC", 0},
+	"UK": {"Europe/London", 0}, "BST": {"Europe/London", 3600},
+	"WET": {"Europe/Lisbon", 0}, "WEST": {"Europe/Lisbon", 3600},
+	"CET": {"Europe/Berlin", 3600}, "CEST": {"Europe/Berlin", 7200},
+	"MEZ": {"Europe/Berlin", 3600}, "MESZ": {"Europe/Berlin", 7200},
+	"EET": {"Europe/Athens", 7200}, "EEST": {"Europe/Athens", 10800},
+	"MSK": {"Europe/Moscow", 10800},
+	"ET":  {"America/New_York", -18000}, "EST": {"America/New_York", -18000}, "EDT": {"America/New_York", -14400},
+	"CT": {"America/Chicago", -21600}, "CST": {"America/Chicago", -21600}, "CDT": {"America/Chicago", -18000},
+	"MT": {"America/Denver", -25200}, "MST": {"America/Denver", -25200}, "MDT": {"America/Denver", -21600},
+	"PT": {"America/Los_Angeles", -28800}, "PST": {"America/Los_Angeles", -28800}, "PDT": {"America/Los_Angeles", -25200},
+	"AEST": {"Australia/Sydney", 36000}, "AEDT": {"Australia/Sydney", 39600},
+	"NZST": {"Pacific/Auckland", 43200}, "NZDT": {"Pacific/Auckland", 46800},
+	"JST": {"Asia/Tokyo", 32400},
+}
+
+var months = map[string]time.Month{
+	"jan": time.January, "feb": time.February, "mar": time.March, "apr": time.April, "may": time.May, "jun": time.June,
+	"jul": time.July, "aug": time.August, "sep": time.September, "oct": time.October, "nov": time.November, "dec": time.December,
+}
+
+var (
+	zonePattern    string
+	isoPattern     *regexp.Regexp
+	generalPattern *regexp.Regexp
+	offsetPattern  = regexp.MustCompile(`^(?:UTC|GMT)?\s*([+-])\s*(\d{1,2})(?::?(\d{2}))?$`)
+)
+
+func init() {
+
+	var abbreviations = make([]string, 0, len(zones))
+	for abbreviation := range zones {
+		if abbreviation != "Z" {
+			abbreviations = append(abbreviations, abbreviation)
+		}
+	}
+
+	// Longest first, CEST before CET
+	sort.Slice(abbreviations, func(i, j int) bool {
+		if len(abbreviations[i]) != len(abbreviations[j]) {
+			return len(abbreviations[i]) > len(abbreviations[j])
+		}
+		return abbreviations[i] < abbreviations[j]
+	})
+
+	// Abbreviations are case sensitive, "et" or "mt" in a name are not zones
+	zonePattern = `(?:\s*\(?\s*(?P<zone>[A-Za-z]+/[A-Za-z_]+(?:/[A-Za-z_]+)?|(?-i:(?:UTC|GMT)\s*[+-]\s*\d{1,2}(?::?\d{2})?|` + strings.Join(abbreviations, "|") + `))\b\)?)?`
+
+	isoPattern = regexp.MustCompile(`(?i)\b(?P<year>\d{4})-(?P<month>\d{2})-(?P<day>\d{2})[T ]?(?P<hour>\d{2}):(?P<minute>\d{2})(?::\d{2}(?:\.\d+)?)?\s*(?P<offset>Z|[+-]\d{2}:?\d{2})?` + zonePattern)
+
+	var monthNames = `(?:jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec)[a-z]*\.?`
+
+	var date = `(?:(?P<d1>\d{1,2})[./](?P<d2>\d{1,2})(?:\.?(?P<y1>\d{4})|/(?P<y1s>\d{4}|\d{2}))?\.?` +
+		`|(?P<day2>\d{1,2})(?:st|nd|rd|th)?\.?\s+(?P<mon2>` + monthNames + `)(?:\s+(?P<y2>\d{4}))?` +
+		`|(?P<mon3>` + monthNames + `)\s+(?P<day3>\d{1,2})(?:st|nd|rd|th)?(?:,?\s+(?P<y3>\d{4}))?)`
+
+	var clock = `(?P<hour>\d{1,2})(?:[:h](?P<minute>\d{2}))?\s*(?P<ampm>[ap]\.?m\b\.?)?`
+
+	generalPattern = regexp.MustCompile(`(?i)(?:\b` + date + `(?:\s*(?:-|@|,|\||at)?\s*))?\b` + clock + zonePattern)
+}
+
+// Parser : Parse patterns and default location
+type Parser struct {
+	patterns []*regexp.Regexp
+	location *time.Location
+	dayFirst bool
+}
+
+// New : Parser with custom patterns (tried before the built-in formats). Times without zone are read in location
+// (local time if nil), dayFirst reads numeric dates as day/month.
+func New(patterns []string, location *time.Location, dayFirst bool) (p *Parser, err error) {
+
+	if location == nil {
+		location = time.Local
+	}
+
+	p = &Parser{location: location, dayFirst: dayFirst}
+
+	for _, pattern := range patterns {
+
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		if re.SubexpIndex("hour") < 0 {
+			return nil, fmt.Errorf("pattern %q has no group (?P<hour>...)", pattern)
+		}
+
+		p.patterns = append(p.patterns, re)
+
+	}
+
+	return
+}
+
+// Zone : Location of a zone abbreviation, IANA name or offset (UTC+2)
+func Zone(name string) (location *time.Location, ok bool) {
+
+	name = strings.TrimSpace(name)
+
+	if z, found := zones[name]; found {
+
+		if location, err := time.LoadLocation(z.name); err == nil {
+			return location, true
+		}
+
+		return time.FixedZone(name, z.offset), true
+	}
+
+	if m := offsetPattern.FindStringSubmatch(name); m != nil {
+
+		var hours, _ = strconv.Atoi(m[2])
+		var minutes, _ = strconv.Atoi(m[3])
+
+		if hours > 14 || minutes > 59 {
+			return nil, false
+		}
+
+		var offset = hours*3600 + minutes*60
+		if m[1] == "-" {
+			offset = -offset
+		}
+
+		return time.FixedZone(name, offset), true
+	}
+
+	if strings.Contains(name, "/") {
+		if location, err := time.LoadLocation(name); err == nil {
+			return location, true
+		}
+	}
+
+	return nil, false
+}
+
+// Parse : Start time of the event in name, ok is false if the name contains no time.
+// Dates without a year are placed in the year closest to now, times without a date on the day of now.
+func (p *Parser) Parse(name string, now time.Time) (t time.Time, ok bool) {
+
+	for _, re := range p.patterns {
+		if t, ok = p.find(re, name, now); ok {
+			return
+		}
+	}
+
+	if t, ok = p.find(isoPattern, name, now); ok {
+		return
+	}
+
+	return p.find(generalPattern, name, now)
+}
+
+// find : First match of the pattern that is a valid time. After an invalid match the search
+// continues behind its first word, "Game 1 Oct 25th 8pm" fails at "1 Oct 25" and matches "Oct 25th 8pm".
+func (p *Parser) find(re *regexp.Regexp, name string, now time.Time) (t time.Time, ok bool) {
+
+	for offset := 0; offset < len(name); {
+
+		var match = re.FindStringSubmatchIndex(name[offset:])
+		if match == nil {
+			return
+		}
+
+		var groups = make(map[string]string)
+		for i, group := range re.SubexpNames() {
+			if len(group) > 0 && match[2*i] >= 0 && match[2*i+1] > match[2*i] {
+				groups[group] = name[offset+match[2*i] : offset+match[2*i+1]]
+			}
+		}
+
+		if t, ok = p.resolve(groups, now); ok {
+			return
+		}
+
+		var next = offset + match[0]
+		for next < len(name) && isWordChar(name[next]) {
+			next++
+		}
+
+		if next == offset+match[0] {
+			next++
+		}
+
+		offset = next
+
+	}
+
+	return
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// resolve : Time of the groups of a match
+func (p *Parser) resolve(groups map[string]string, now time.Time) (t time.Time, ok bool) {
+
+	var location = p.location
+
+	if offset, found := groups["offset"]; found {
+		if strings.EqualFold(offset, "Z") {
+			location = time.UTC
+		} else if location, ok = Zone(offset); !ok {
+			return
+		}
+	} else if name, found := groups["zone"]; found {
+		// Unknown zones (e.g. a word caught by a custom pattern) keep the default location
+		if l, found := Zone(name); found {
+			location = l
+		}
+	}
+
+	hour, minute, ok := clock(groups)
+	if !ok {
+		return
+	}
+
+	year, month, day, dated, ok := p.date(groups)
+	if !ok {
+		return
+	}
+
+	if !dated {
+		var today = now.In(location)
+		return time.Date(today.Year(), today.Month(), today.Day(), hour, minute, 0, 0, location), true
+	}
+
+	if year > 0 {
+		t = time.Date(year, month, day, hour, minute, 0, 0, location)
+		return t, t.Day() == day
+	}
+
+	// Closest year, an event on 1/2 seen on 12/31 is in the next year
+	ok = false
+
+	for _, y := range []int{now.Year(), now.Year() + 1, now.Year() - 1} {
+
+		var candidate = time.Date(y, month, day, hour, minute, 0, 0, location)
+		if candidate.Day() != day {
+			continue
+		}
+
+		if !ok || absDuration(candidate.Sub(now)) < absDuration(t.Sub(now)) {
+			t, ok = candidate, true
+		}
+
+	}
+
+	return
+}
+
+// clock : Hour and minute, a number without minutes or am/pm is not a time
+func clock(groups map[string]string) (hour, minute int, ok bool) {
+
+	hour, err := strconv.Atoi(groups["hour"])
+	if err != nil {
+		return
+	}
+
+	var ampm = strings.ToLower(strings.ReplaceAll(groups["ampm"], ".", ""))
+
+	if value, found := groups["minute"]; found {
+
+		if minute, err = strconv.Atoi(value); err != nil || minute > 59 {
+			return
+		}
+
+	} else if len(ampm) == 0 {
+		return
+	}
+
+	switch ampm {
+
+	case "":
+		if hour > 23 {
+			return
+		}
+
+	case "am", "pm":
+		if hour < 1 || hour > 12 {
+			return
+		}
+
+		hour = hour % 12
+		if ampm == "pm" {
+			hour += 12
+		}
+
+	default:
+		return
+
+	}
+
+	return hour, minute, true
+}
+
+// date : Year (0 if missing), month and day, dated is false if the match contains no date
+func (p *Parser) date(groups map[string]string) (year int, month time.Month, day int, dated, ok bool) {
+
+	var number = func(keys ...string) (n int) {
+		for _, key := range keys {
+			if value, found := groups[key]; found {
+				n, _ = strconv.Atoi(value)
+				return
+			}
+		}
+		return
+	}
+
+	var monthName = func(keys ...string) (m time.Month) {
+		for _, key := range keys {
+			if value, found := groups[key]; found {
+				if len(value) >= 3 {
+					return months[strings.ToLower(value[:3])]
+				}
+			}
+		}
+		return
+	}
+
+	year = number("year", "y1", "y1s", "y2", "y3")
+	if year > 0 && year < 100 {
+		year += 2000
+	}
+
+	switch {
+
+	case len(groups["d1"]) > 0:
+		var d1, d2 = number("d1"), number("d2")
+
+		day, month = d2, time.Month(d1)
+		if (p.dayFirst && d2 <= 12) || d1 > 12 {
+			day, month = d1, time.Month(d2)
+		}
+
+	case len(groups["day2"]) > 0:
+		day, month = number("day2"), monthName("mon2")
+
+	case len(groups["day3"]) > 0:
+		day, month = number("day3"), monthName("mon3")
+
+	case len(groups["month"]) > 0 || len(groups["day"]) > 0:
+		day = number("day")
+		if month = time.Month(number("month")); month == 0 {
+			month = monthName("month")
+		}
+
+	default:
+		return 0, 0, 0, false, year == 0
+
+	}
+
+	ok = month >= time.January && month <= time.December && day >= 1 && day <= 31
+
+	return year, month, day, true, ok
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,172 @@
+package eventtime
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func mustLocation(t *testing.T, name string) *time.Location {
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return location
+}
+
+// TestCorpus : Channel names of event channels as they appear in real playlists
+func TestCorpus(t *testing.T) {
+
+	var london = mustLocation(t, "Europe/London")
+	var berlin = mustLocation(t, "Europe/Berlin")
+	var newYork = mustLocation(t, "America/New_York")
+	var losAngeles = mustLocation(t, "America/Los_Angeles")
+
+	// Server in Berlin, Friday 18 October 2024 10:00
+	var now = time.Date(2024, 10, 18, 10, 0, 0, 0, berlin)
+
+	parser, err := New(nil, berlin, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name string
+		want time.Time
+	}{
+		{"Premier League: Arsenal v Chelsea 17:30 UK", time.Date(2024, 10, 18, 17, 30, 0, 0, london)},
+		{"EPL 01: Arsenal vs Chelsea @ 12:30 BST", time.Date(2024, 10, 18, 12, 30, 0, 0, london)},
+		{"2024-10-18T19:00Z", time.Date(2024, 10, 18, 19, 0, 0, 0, time.UTC)},
+		{"Event 05 | 2024-10-19 20:45 +02:00 Bayern vs Dortmund", time.Date(2024, 10, 19, 18, 45, 0, 0, time.UTC)},
+		{"NFL 04: Jets at Steelers 10/20 8:20 PM ET", time.Date(2024, 10, 20, 20, 20, 0, 0, newYork)},
+		{"NBA: Lakers vs Suns 7:30PM PT", time.Date(2024, 10, 18, 19, 30, 0, 0, losAngeles)},
+		{"UFC 308: Topuria vs Holloway 10/26 2PM ET", time.Date(2024, 10, 26, 14, 0, 0, 0, newYork)},
+		{"PPV 12: WWE Crown Jewel 11/2 11AM EST", time.Date(2024, 11, 2, 11, 0, 0, 0, newYork)},
+		{"Bundesliga: Leipzig - Freiburg 19.10. 15:30", time.Date(2024, 10, 19, 15, 30, 0, 0, berlin)},
+		{"DAZN 3: Sat 19 Oct 20:00 CEST El Clasico", time.Date(2024, 10, 19, 20, 0, 0, 0, berlin)},
+		{"Ligue 1: PSG vs Lens 21h00 CET", time.Date(2024, 10, 18, 21, 0, 0, 0, berlin)},
+		{"MLB: World Series Game 1 Oct 25th 8:08pm ET", time.Date(2024, 10, 25, 20, 8, 0, 0, newYork)},
+		{"F1 USA GP Race (Sun 20th October 2024 20:00 UK)", time.Date(2024, 10, 20, 20, 0, 0, 0, london)},
+		{"Serie A 18/10/2024 20:45 CEST Genoa vs Bologna", time.Date(2024, 10, 18, 20, 45, 0, 0, berlin)},
+		{"Boxing: Beterbiev v Bivol 12 Oct 10PM (Europe/London)", time.Date(2024, 10, 12, 22, 0, 0, 0, london)},
+		{"NHL 07: Rangers @ Red Wings 7:00 PM", time.Date(2024, 10, 18, 19, 0, 0, 0, berlin)},
+		{"Cricket: IND v NZ 04:00 UTC+5:30", time.Date(2024, 10, 17, 22, 30, 0, 0, time.UTC)},
+		{"NYE Special 12/31 11:59 PM ET", time.Date(2024, 12, 31, 23, 59, 0, 0, newYork)},
+		{"A-League 1 20:00 AEDT", time.Date(2024, 10, 18, 9, 0, 0, 0, time.UTC)},
+		{"Round 3 - 7:30 PM CT", time.Date(2024, 10, 19, 0, 30, 0, 0, time.UTC)},
+		{"NCAAF: Georgia at Texas 7:30 p.m. ET", time.Date(2024, 10, 18, 19, 30, 0, 0, newYork)},
+	}
+
+	for _, test := range tests {
+
+		got, ok := parser.Parse(test.name, now)
+		if !ok {
+			t.Errorf("%q: no time found", test.name)
+			continue
+		}
+
+		if !got.Equal(test.want) {
+			t.Errorf("%q: got %s, want %s", test.name, got, test.want)
+		}
+
+	}
+}
+
+func TestNoTime(t *testing.T) {
+
+	parser, _ := New(nil, time.UTC, false)
+
+	for _, name := range []string{
+		"Sky Sports Main Event",
+		"UFC 300",
+		"Channel 4+1",
+		"BBC One HD 1080p",
+		"Event 12 - No Event Scheduled",
+		"Premier League 23/24 Highlights",
+		"ET Canada",
+		"at 25:00",
+	} {
+		if got, ok := parser.Parse(name, time.Now()); ok {
+			t.Errorf("%q: unexpected time %s", name, got)
+		}
+	}
+}
+
+func TestYearRollover(t *testing.T) {
+
+	parser, _ := New(nil, time.UTC, false)
+
+	var now = time.Date(2024, 12, 31, 12, 0, 0, 0, time.UTC)
+
+	got, ok := parser.Parse("Winter Classic 1/1 1PM", now)
+	if !ok || !got.Equal(time.Date(2025, 1, 1, 13, 0, 0, 0, time.UTC)) {
+		t.Errorf("got %s %v, want 2025-01-01 13:00", got, ok)
+	}
+}
+
+func TestDayFirst(t *testing.T) {
+
+	var now = time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	monthFirst, _ := New(nil, time.UTC, false)
+	dayFirst, _ := New(nil, time.UTC, true)
+
+	if got, _ := monthFirst.Parse("Match 5/10 20:00", now); got.Month() != time.May {
+		t.Errorf("month first: got %s", got)
+	}
+
+	if got, _ := dayFirst.Parse("Match 5/10 20:00", now); got.Month() != time.October || got.Day() != 5 {
+		t.Errorf("day first: got %s", got)
+	}
+
+	// 18 can not be a month
+	if got, _ := monthFirst.Parse("Match 18/10 20:00", now); got.Month() != time.October || got.Day() != 18 {
+		t.Errorf("day > 12: got %s", got)
+	}
+}
+
+func TestPatterns(t *testing.T) {
+
+	parser, err := New([]string{`(?i)KO (?P<day>\d{1,2})-(?P<month>[a-z]{3}) (?P<hour>\d{2})(?P<minute>\d{2}) (?P<zone>\w+)`}, time.UTC, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var now = time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	got, ok := parser.Parse("Super Cup KO 19-Oct 1945 CEST", now)
+	if !ok || !got.Equal(time.Date(2024, 10, 19, 19, 45, 0, 0, mustLocation(t, "Europe/Berlin"))) {
+		t.Errorf("got %s %v", got, ok)
+	}
+
+	if _, err = New([]string{`(\d+):(\d+)`}, nil, false); err == nil {
+		t.Error("pattern without hour group accepted")
+	}
+
+	if _, err = New([]string{`(?P<hour>\d+`}, nil, false); err == nil {
+		t.Error("invalid pattern accepted")
+	}
+}
+
+func TestZone(t *testing.T) {
+
+	for name, offset := range map[string]int{"UTC+2": 7200, "GMT-5": -18000, "+0530": 19800, "JST": 32400} {
+
+		location, ok := Zone(name)
+		if !ok {
+			t.Errorf("%s: not found", name)
+			continue
+		}
+
+		if _, got := time.Date(2024, 1, 15, 12, 0, 0, 0, location).Zone(); got != offset {
+			t.Errorf("%s: offset %d, want %d", name, got, offset)
+		}
+
+	}
+
+	if _, ok := Zone("Mars/Olympus"); ok {
+		t.Error("unknown IANA zone accepted")
+	}
+}
@@ -0,0 +1,495 @@
+// Package filterexpr compiles the stream filter language.
+//
+//	group="UK Sports" AND NOT name~/\b(SD|Backup)\b/
+//	(tvg-id:bbc OR tvg-id:itv) AND resolution>=720
+//	attr[tvg-country]=GB sky
+//
+// Predicates are field op value. Fields: name, group, tvg-id, tvg-name, tvg-logo, tvg-chno,
+// url, source (playlist name), resolution (probe data), any (the complete #EXTINF line) and
+// attr[key] for every other M3U attribute. Operators: ":" contains, "=" equals, "!=" not equal,
+// "~" regular expression, "!~" no match, ">", ">=", "<", "<=" numeric comparison.
+// A value without a field is searched in the complete #EXTINF line, several terms without
+// an operator between them are combined with AND. Values with spaces or operator characters
+// must be quoted, regular expressions are written as /.../ (flag i for case insensitive).
+package filterexpr
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Options : Compile options
+type Options struct {
+	CaseSensitive bool
+}
+
+// Record : Values of a stream, the keys are the M3U attribute names (name, group-title, tvg-id, url, _values, ...)
+type Record interface {
+	Get(key string) string
+}
+
+// Map : Record from a stream map
+type Map map[string]string
+
+// Get : Value of the key
+func (m Map) Get(key string) string {
+	return m[key]
+}
+
+// Expr : Compiled filter expression
+type Expr struct {
+	source string
+	root   node
+}
+
+var fields = map[string]string{
+	"name":        "name",
+	"group":       "group-title",
+	"group-title": "group-title",
+	"tvg-id":      "tvg-id",
+	"tvg-name":    "tvg-name",
+	"tvg-logo":    "tvg-logo",
+	"logo":        "tvg-logo",
+	"tvg-chno":    "tvg-chno",
+	"chno":        "tvg-chno",
+	"url":         "url",
+	"source":      "_file.m3u.name",
+	"playlist":    "_file.m3u.name",
+	"resolution":  "resolution",
+	"any":         "_values",
+}
+
+// Compile : Parse the expression
+func Compile(source string, opts Options) (e *Expr, err error) {
+
+	tokens, err := lex(source)
+	if err != nil {
+		return
+	}
+
+	if len(tokens) == 0 {
+		return nil, errors.New("empty filter expression")
+	}
+
+	var p = &parser{tokens: tokens, opts: opts}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return
+	}
+
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.peek().text, p.peek().pos+1)
+	}
+
+	return &Expr{source: source, root: root}, nil
+}
+
+// Match : true if the stream matches the expression
+func (e *Expr) Match(r Record) bool {
+	return e.root.eval(r)
+}
+
+// Explain : Result and the part of the expression that decided it
+func (e *Expr) Explain(r Record) (bool, string) {
+	return e.root.explain(r)
+}
+
+// String : Source of the expression
+func (e *Expr) String() string {
+	return e.source
+}
+
+// Quote : Value as quoted string for an expression
+func Quote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// ---- Nodes ----
+
+type node interface {
+	eval(r Record) bool
+	explain(r Record) (bool, string)
+	String() string
+}
+
+type andNode []node
+
+func (n andNode) eval(r Record) bool {
+	for _, child := range n {
+		if !child.eval(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func (n andNode) explain(r Record) (bool, string) {
+
+	var reasons = make([]string, 0, len(n))
+	for _, child := range n {
+
+		ok, reason := child.explain(r)
+		if !ok {
+			return false, reason
+		}
+
+		reasons = append(reasons, reason)
+
+	}
+
+	return true, strings.Join(reasons, " AND ")
+}
+
+func (n andNode) String() string {
+	return join(n, " AND ")
+}
+
+type orNode []node
+
+func (n orNode) eval(r Record) bool {
+	for _, child := range n {
+		if child.eval(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n orNode) explain(r Record) (bool, string) {
+
+	for _, child := range n {
+		if ok, reason := child.explain(r); ok {
+			return true, reason
+		}
+	}
+
+	return false, "none of " + n.String()
+}
+
+func (n orNode) String() string {
+	return "(" + join(n, " OR ") + ")"
+}
+
+type notNode struct {
+	child node
+}
+
+func (n notNode) eval(r Record) bool {
+	return !n.child.eval(r)
+}
+
+func (n notNode) explain(r Record) (bool, string) {
+	ok, _ := n.child.explain(r)
+	return !ok, n.String()
+}
+
+func (n notNode) String() string {
+	return "NOT " + n.child.String()
+}
+
+func join(nodes []node, sep string) string {
+
+	var parts = make([]string, len(nodes))
+	for i, n := range nodes {
+		parts[i] = n.String()
+	}
+
+	return strings.Join(parts, sep)
+}
+
+type predicate struct {
+	field, key, op, value string
+	caseSensitive         bool
+	number                float64
+	re                    *regexp.Regexp
+}
+
+func (p predicate) eval(r Record) bool {
+
+	var value = r.Get(p.key)
+
+	switch p.op {
+
+	case "~":
+		return p.re.MatchString(value)
+
+	case "!~":
+		return !p.re.MatchString(value)
+
+	case ">", ">=", "<", "<=":
+		number, ok := leadingNumber(value)
+		if !ok {
+			return false
+		}
+
+		switch p.op {
+		case ">":
+			return number > p.number
+		case ">=":
+			return number >= p.number
+		case "<":
+			return number < p.number
+		default:
+			return number <= p.number
+		}
+
+	}
+
+	if !p.caseSensitive {
+		value = strings.ToLower(value)
+	}
+
+	switch p.op {
+
+	case "=":
+		return value == p.value
+
+	case "!=":
+		return value != p.value
+
+	default:
+		return strings.Contains(value, p.value)
+
+	}
+
+}
+
+func (p predicate) explain(r Record) (bool, string) {
+	return p.eval(r), p.String()
+}
+
+func (p predicate) String() string {
+
+	switch p.op {
+	case "~", "!~":
+		return p.field + p.op + "/" + p.re.String() + "/"
+	case ">", ">=", "<", "<=":
+		return p.field + p.op + strconv.FormatFloat(p.number, 'f', -1, 64)
+	}
+
+	return p.field + p.op + Quote(p.value)
+}
+
+// Number at the beginning of the value: "1080p" -> 1080
+func leadingNumber(value string) (float64, bool) {
+
+	value = strings.TrimSpace(value)
+
+	var end int
+	for end < len(value) && (value[end] >= '0' && value[end] <= '9' || value[end] == '.') {
+		end++
+	}
+
+	number, err := strconv.ParseFloat(value[:end], 64)
+	return number, err == nil
+}
+
+// ---- Parser ----
+
+type parser struct {
+	tokens []token
+	pos    int
+	opts   Options
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *parser) peek() token {
+	if p.done() {
+		return token{kind: tokenEOF}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	var t = p.peek()
+	p.pos++
+	return t
+}
+
+func (p *parser) parseOr() (node, error) {
+
+	var nodes []node
+
+	for {
+
+		n, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		nodes = append(nodes, n)
+
+		if p.peek().kind != tokenOr {
+			break
+		}
+
+		p.next()
+
+	}
+
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+
+	return orNode(nodes), nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+
+	var nodes []node
+
+	for {
+
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		nodes = append(nodes, n)
+
+		switch p.peek().kind {
+
+		case tokenAnd:
+			p.next()
+			continue
+
+		case tokenNot, tokenOpen, tokenWord, tokenString, tokenRegex:
+			// Implicit AND
+			continue
+
+		}
+
+		break
+	}
+
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+
+	return andNode(nodes), nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+
+	switch t := p.peek(); t.kind {
+
+	case tokenNot:
+		p.next()
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{n}, nil
+
+	case tokenOpen:
+		p.next()
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokenClose {
+			return nil, fmt.Errorf("missing ) for ( at position %d", t.pos+1)
+		}
+		return n, nil
+
+	case tokenWord:
+		if p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].kind == tokenOp {
+			p.next()
+			var op = p.next()
+			return p.predicate(t, op, p.next())
+		}
+
+	}
+
+	return p.term(p.next())
+}
+
+// Value without a field: search in the complete #EXTINF line
+func (p *parser) term(value token) (node, error) {
+
+	switch value.kind {
+	case tokenEOF:
+		return nil, errors.New("unexpected end of the filter expression")
+	case tokenWord, tokenString, tokenRegex:
+	default:
+		return nil, fmt.Errorf("unexpected %q at position %d", value.text, value.pos+1)
+	}
+
+	return p.predicate(token{kind: tokenWord, text: "any", pos: value.pos}, token{kind: tokenOp, text: ":", pos: value.pos}, value)
+}
+
+func (p *parser) predicate(field, op, value token) (node, error) {
+
+	var pred = predicate{field: strings.ToLower(field.text), op: op.text, caseSensitive: p.opts.CaseSensitive}
+
+	switch {
+
+	case strings.HasPrefix(pred.field, "attr[") && strings.HasSuffix(pred.field, "]"):
+		pred.field = field.text
+		pred.key = field.text[5 : len(field.text)-1]
+		if len(pred.key) == 0 {
+			return nil, fmt.Errorf("empty attribute name at position %d", field.pos+1)
+		}
+
+	default:
+		key, ok := fields[pred.field]
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q at position %d", field.text, field.pos+1)
+		}
+		pred.key = key
+
+	}
+
+	switch value.kind {
+	case tokenWord, tokenString, tokenRegex:
+	default:
+		return nil, fmt.Errorf("missing value for %s%s at position %d", field.text, op.text, op.pos+1)
+	}
+
+	switch op.text {
+
+	case "~", "!~":
+		var pattern = value.text
+		if !p.opts.CaseSensitive || strings.Contains(value.flags, "i") {
+			pattern = "(?i)" + pattern
+		}
+
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression at position %d: %s", value.pos+1, err)
+		}
+		pred.re = re
+
+	case ">", ">=", "<", "<=":
+		number, err := strconv.ParseFloat(strings.TrimSuffix(strings.ToLower(value.text), "p"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at position %d", value.text, value.pos+1)
+		}
+		pred.number = number
+
+	default:
+		if value.kind == tokenRegex {
+			// name:/.../ is the same as name~/.../
+			var regexOp = "~"
+			if op.text == "!=" {
+				regexOp = "!~"
+			}
+			return p.predicate(field, token{kind: tokenOp, text: regexOp, pos: op.pos}, value)
+		}
+
+		pred.value = value.text
+		if !p.opts.CaseSensitive {
+			pred.value = strings.ToLower(pred.value)
+		}
+
+	}
+
+	return pred, nil
+}
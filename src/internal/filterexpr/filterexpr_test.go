@@ -0,0 +1,121 @@
+package filterexpr
+
+import "testing"
+
+var stream = Map{
+	"name":           "UK: Sky Sports Main Event FHD",
+	"group-title":    "UK Sports",
+	"tvg-id":         "SkySpMainEvHD.uk",
+	"tvg-country":    "GB",
+	"url":            "http://provider.tv/live/user/pass/1234.ts",
+	"resolution":     "1080p",
+	"_file.m3u.name": "Provider A",
+	"_values":        `#EXTINF:-1 tvg-id="SkySpMainEvHD.uk" tvg-country="GB" group-title="UK Sports",UK: Sky Sports Main Event FHD`,
+}
+
+func TestMatch(t *testing.T) {
+
+	var tests = []struct {
+		expr string
+		want bool
+	}{
+		{`group="UK Sports"`, true},
+		{`group="uk sports"`, true},
+		{`group="UK"`, false},
+		{`group:uk`, true},
+		{`name~/main\s+event/`, true},
+		{`name!~/\bSD\b/`, true},
+		{`NOT name:/fhd/`, false},
+		{`group="UK Sports" AND NOT name:backup`, true},
+		{`group="UK Sports" && !name:event`, false},
+		{`group:news OR tvg-id:skysp`, true},
+		{`(group:news || group:movies) AND sky`, false},
+		{`sky "main event"`, true},
+		{`attr[tvg-country]=GB`, true},
+		{`attr[tvg-country]!=GB`, false},
+		{`resolution>=720`, true},
+		{`resolution<720p`, false},
+		{`source="Provider A" url:"/live/"`, true},
+		{`tvg-chno>0`, false},
+	}
+
+	for _, test := range tests {
+
+		e, err := Compile(test.expr, Options{})
+		if err != nil {
+			t.Errorf("%s: %s", test.expr, err)
+			continue
+		}
+
+		if got := e.Match(stream); got != test.want {
+			t.Errorf("%s: got %t, want %t", test.expr, got, test.want)
+		}
+
+	}
+
+}
+
+func TestCaseSensitive(t *testing.T) {
+
+	e, err := Compile(`group="uk sports"`, Options{CaseSensitive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Match(stream) {
+		t.Error("case sensitive filter matched a different case")
+	}
+
+	e, err = Compile(`name~/fhd/i`, Options{CaseSensitive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !e.Match(stream) {
+		t.Error("regular expression with flag i did not match")
+	}
+
+}
+
+func TestExplain(t *testing.T) {
+
+	e, err := Compile(`group="UK Sports" AND NOT name:FHD`, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, reason := e.Explain(stream)
+	if ok || reason != `NOT name:"fhd"` {
+		t.Errorf("got %t %q", ok, reason)
+	}
+
+	e, _ = Compile(`group:news OR tvg-id:skysp`, Options{})
+	ok, reason = e.Explain(stream)
+	if !ok || reason != `tvg-id:"skysp"` {
+		t.Errorf("got %t %q", ok, reason)
+	}
+
+}
+
+func TestCompileErrors(t *testing.T) {
+
+	for _, expr := range []string{``, `country=GB`, `name=`, `(group:uk`, `name~/[/`, `resolution>=hd`, `group:"uk`, `AND`, `group:uk)`} {
+		if _, err := Compile(expr, Options{}); err == nil {
+			t.Errorf("%q: expected error", expr)
+		}
+	}
+
+}
+
+func TestQuote(t *testing.T) {
+
+	e, err := Compile("name="+Quote(`Say "Hi" \ Bye`), Options{CaseSensitive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !e.Match(Map{"name": `Say "Hi" \ Bye`}) {
+		t.Error("quoted value did not match")
+	}
+
+}
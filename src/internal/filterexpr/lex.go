@@ -0,0 +1,174 @@
+package filterexpr
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenString
+	tokenRegex
+	tokenOp
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenOpen
+	tokenClose
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	flags string
+	pos   int
+}
+
+// Characters that end a word without quotes
+const delimiters = " \t\r\n()\":=!~<>"
+
+func lex(source string) (tokens []token, err error) {
+
+	var runes = []rune(source)
+	var i int
+
+	for i < len(runes) {
+
+		var r = runes[i]
+		var start = i
+
+		switch {
+
+		case r == ' ' || r == '\t' || r == '\r' || r == '\n':
+			i++
+
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenOpen, text: "(", pos: start})
+			i++
+
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenClose, text: ")", pos: start})
+			i++
+
+		case r == '&' && i+1 < len(runes) && runes[i+1] == '&':
+			tokens = append(tokens, token{kind: tokenAnd, text: "&&", pos: start})
+			i += 2
+
+		case r == '|' && i+1 < len(runes) && runes[i+1] == '|':
+			tokens = append(tokens, token{kind: tokenOr, text: "||", pos: start})
+			i += 2
+
+		case r == '!' || r == ':' || r == '=' || r == '~' || r == '<' || r == '>':
+			var op = string(r)
+			if i+1 < len(runes) && (runes[i+1] == '=' || (r == '!' && runes[i+1] == '~')) && r != ':' && r != '=' && r != '~' {
+				op += string(runes[i+1])
+			}
+
+			if op == "!" {
+				tokens = append(tokens, token{kind: tokenNot, text: "!", pos: start})
+			} else {
+				tokens = append(tokens, token{kind: tokenOp, text: op, pos: start})
+			}
+
+			i += len([]rune(op))
+
+		case r == '"':
+			var b strings.Builder
+			i++
+
+			for {
+
+				if i >= len(runes) {
+					return nil, fmt.Errorf("missing closing quote for string at position %d", start+1)
+				}
+
+				if runes[i] == '\\' && i+1 < len(runes) {
+					b.WriteRune(runes[i+1])
+					i += 2
+					continue
+				}
+
+				if runes[i] == '"' {
+					i++
+					break
+				}
+
+				b.WriteRune(runes[i])
+				i++
+
+			}
+
+			tokens = append(tokens, token{kind: tokenString, text: b.String(), pos: start})
+
+		case r == '/':
+			var b strings.Builder
+			i++
+
+			for {
+
+				if i >= len(runes) {
+					return nil, fmt.Errorf("missing closing / for regular expression at position %d", start+1)
+				}
+
+				if runes[i] == '\\' && i+1 < len(runes) && runes[i+1] == '/' {
+					b.WriteRune('/')
+					i += 2
+					continue
+				}
+
+				if runes[i] == '/' {
+					i++
+					break
+				}
+
+				b.WriteRune(runes[i])
+				i++
+
+			}
+
+			var flags strings.Builder
+			for i < len(runes) && runes[i] == 'i' {
+				flags.WriteRune(runes[i])
+				i++
+			}
+
+			tokens = append(tokens, token{kind: tokenRegex, text: b.String(), flags: flags.String(), pos: start})
+
+		default:
+			for i < len(runes) && !strings.ContainsRune(delimiters, runes[i]) {
+
+				// attr[...] may contain any character except ]
+				if runes[i] == '[' {
+					for i < len(runes) && runes[i] != ']' {
+						i++
+					}
+				}
+
+				if i < len(runes) {
+					i++
+				}
+
+			}
+
+			var word = string(runes[start:i])
+
+			switch word {
+			case "AND":
+				tokens = append(tokens, token{kind: tokenAnd, text: word, pos: start})
+			case "OR":
+				tokens = append(tokens, token{kind: tokenOr, text: word, pos: start})
+			case "NOT":
+				tokens = append(tokens, token{kind: tokenNot, text: word, pos: start})
+			default:
+				tokens = append(tokens, token{kind: tokenWord, text: word, pos: start})
+			}
+
+		}
+
+	}
+
+	return
+}
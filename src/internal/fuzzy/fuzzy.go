@@ -0,0 +1,157 @@
+package fuzzy
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	// Country / provider prefixes: "UK: ", "UK | ", "US| ", "[DE] "
+	prefixPattern = regexp.MustCompile(`^\s*(\[[^\]]{1,6}\]|\([^)]{1,6}\)|[A-Za-z]{2,4}\s*[:|])\s*`)
+
+	// Everything in brackets: "[FHD]", "(Backup)"
+	bracketPattern = regexp.MustCompile(`[\[(][^\])]*[\])]`)
+
+	// Quality and codec tags
+	qualityTags = map[string]bool{
+		"sd": true, "hd": true, "fhd": true, "uhd": true, "qhd": true, "4k": true, "8k": true,
+		"hq": true, "lq": true, "hevc": true, "h264": true, "h265": true, "x264": true, "x265": true,
+		"raw": true, "50fps": true, "60fps": true, "1080p": true, "1080i": true, "720p": true, "2160p": true,
+	}
+)
+
+// Normalize : Channel name without provider prefix, quality tags, brackets and punctuation (lower case)
+func Normalize(name string) string {
+
+	// Superscript and full width characters (ᴿᴬᵂ, ＨＤ) are mapped to their base letters
+	name = norm.NFKC.String(name)
+	name = strings.ToLower(name)
+
+	name = prefixPattern.ReplaceAllString(name, "")
+	name = bracketPattern.ReplaceAllString(name, " ")
+
+	var b strings.Builder
+	for _, r := range name {
+
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		case r == '+' || r == '&':
+			b.WriteRune(r)
+		default:
+			b.WriteRune(' ')
+		}
+
+	}
+
+	var tokens = make([]string, 0)
+	for _, token := range strings.Fields(b.String()) {
+		if !qualityTags[token] {
+			tokens = append(tokens, token)
+		}
+	}
+
+	return strings.Join(tokens, " ")
+}
+
+// Similarity : Similarity of two channel names between 0 and 1 (Sørensen–Dice coefficient of the character bigrams)
+func Similarity(a, b string) float64 {
+	return NewName(a).Similarity(NewName(b))
+}
+
+// Name : Normalised channel name with its bigrams, for comparing one name with many others
+type Name struct {
+	Normalized string
+	bigrams    map[string]int
+	count      int
+}
+
+// NewName : Normalises the name and counts its bigrams
+func NewName(name string) (n Name) {
+
+	n.Normalized = Normalize(name)
+	n.bigrams = make(map[string]int)
+
+	for _, bigram := range bigrams(n.Normalized) {
+		n.bigrams[bigram]++
+		n.count++
+	}
+
+	return
+}
+
+// Similarity : Similarity of the two names between 0 and 1
+func (n Name) Similarity(other Name) float64 {
+
+	if n.Normalized == other.Normalized {
+		if len(n.Normalized) == 0 {
+			return 0
+		}
+		return 1
+	}
+
+	if n.count == 0 || other.count == 0 {
+		return 0
+	}
+
+	var matches int
+	for bigram, count := range n.bigrams {
+		if c := other.bigrams[bigram]; c < count {
+			matches += c
+		} else {
+			matches += count
+		}
+	}
+
+	return float64(2*matches) / float64(n.count+other.count)
+}
+
+func bigrams(s string) (list []string) {
+
+	for _, word := range strings.Fields(s) {
+
+		var runes = []rune(word)
+		if len(runes) == 1 {
+			list = append(list, word)
+			continue
+		}
+
+		for i := 0; i < len(runes)-1; i++ {
+			list = append(list, string(runes[i:i+2]))
+		}
+
+	}
+
+	return
+}
+
+// StreamID : Provider stream id from a streaming URL.
+// Xtream Codes style URLs (http://host/live/user/pass/12345.ts) return "12345", the id stays the same if the channel is renamed.
+func StreamID(streamURL string) string {
+
+	u, err := url.Parse(strings.TrimSpace(streamURL))
+	if err != nil || len(u.Path) == 0 {
+		return ""
+	}
+
+	var segment = path.Base(u.Path)
+	segment = strings.TrimSuffix(segment, path.Ext(segment))
+
+	if len(segment) == 0 || segment == "/" || segment == "." {
+		return ""
+	}
+
+	for _, r := range segment {
+		if !unicode.IsDigit(r) {
+			// Only numeric ids are stable, names in the path change together with the channel name
+			return ""
+		}
+	}
+
+	return u.Host + "/" + segment
+}
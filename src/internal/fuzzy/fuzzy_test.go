@@ -0,0 +1,74 @@
+package fuzzy
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+
+	var tests = map[string]string{
+		"UK: Sky Sports 1 HD":        "sky sports 1",
+		"UK | Sky Sports 1 FHD":      "sky sports 1",
+		"US| CNN [FHD]":              "cnn",
+		"[DE] Das Erste HD (Backup)": "das erste",
+		"BBC One ᴿᴬᵂ":                "bbc one",
+		"Channel 4+1":                "channel 4+1",
+	}
+
+	for input, want := range tests {
+		if got := Normalize(input); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", input, got, want)
+		}
+	}
+
+}
+
+func TestSimilarity(t *testing.T) {
+
+	if s := Similarity("UK: Sky Sports 1 HD", "UK | Sky Sports 1 FHD"); s != 1 {
+		t.Errorf("renamed channel: got %f, want 1", s)
+	}
+
+	var close = Similarity("Sky Sports Main Event", "Sky Sport Main Event")
+	var far = Similarity("Sky Sports Main Event", "BBC News")
+
+	if close < 0.85 {
+		t.Errorf("similar names: got %f", close)
+	}
+
+	if far > 0.3 {
+		t.Errorf("different names: got %f", far)
+	}
+
+	if s := Similarity("", ""); s != 0 {
+		t.Errorf("empty names: got %f, want 0", s)
+	}
+
+}
+
+func TestNameSimilarity(t *testing.T) {
+
+	var name = NewName("Sky Sports Main Event")
+
+	for _, other := range []string{"Sky Sport Main Event", "BBC News", "sky sports main event hd", ""} {
+		if got, want := name.Similarity(NewName(other)), Similarity("Sky Sports Main Event", other); got != want {
+			t.Errorf("%q: got %f, want %f", other, got, want)
+		}
+	}
+
+}
+
+func TestStreamID(t *testing.T) {
+
+	var tests = map[string]string{
+		"http://provider.tv:8080/live/user/pass/12345.ts": "provider.tv:8080/12345",
+		"http://provider.tv/user/pass/987":                "provider.tv/987",
+		"http://provider.tv/hls/sky-sports/index.m3u8":    "",
+		"not a url": "",
+	}
+
+	for input, want := range tests {
+		if got := StreamID(input); got != want {
+			t.Errorf("StreamID(%q) = %q, want %q", input, got, want)
+		}
+	}
+
+}
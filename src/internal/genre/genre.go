@@ -0,0 +1,237 @@
+// Package genre maps free-form programme categories (in any language) onto the genres Plex and Emby
+// understand: Sports, Movie, News, Kids and Series.
+//
+// Rules are tried in this order, the first match wins:
+//
+//	rules of the source (XMLTV file) of the programme
+//	rules without source
+//	built-in keywords (English, German, French, Spanish, Italian, Portuguese, Dutch, Russian)
+//
+// A rule maps a regular expression (case insensitive) onto a genre, the genre None drops the category.
+// Title rules and the built-in title patterns (Arsenal v Chelsea, Premier League, "Heat (1995)")
+// are only used if none of the categories has a genre.
+package genre
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Standard genres
+const (
+	Sports = "Sports"
+	Movie  = "Movie"
+	News   = "News"
+	Kids   = "Kids"
+	Series = "Series"
+
+	// None : The category has no genre, later rules are not tried
+	None = "None"
+)
+
+// Standard : Genres in the order of the built-in rules
+var Standard = []string{Kids, Sports, Movie, News, Series}
+
+// Rule : Mapping of categories (or titles) matching Pattern onto Genre
+type Rule struct {
+	Pattern string
+	Genre   string
+	Source  string // XMLTV file, empty for all sources
+	Title   bool   // Pattern is matched against the title
+}
+
+type rule struct {
+	re     *regexp.Regexp
+	genre  string
+	source string
+	title  bool
+	folded bool // re is matched against the folded text (built-in rules)
+}
+
+// Keywords are matched at the beginning of a word, "sport" matches "Sports", "Sportif" and "Motorsport" does not
+var keywords = map[string][]string{
+	Kids: {"kids", "kinder", "children", "childrens", "enfant", "jeunesse", "infantil", "bambin", "ragazzi",
+		"cartoon", "zeichentrick", "dessin anime", "детск", "мульт"},
+	Sports: {"sport", "esporte", "deporte", "football", "fussball", "fußball", "futbol", "futebol", "calcio", "soccer",
+		"voetbal", "tennis", "basketball", "baloncesto", "hockey", "motorsport", "formula 1", "formel 1", "golf",
+		"boxing", "boxen", "boxe", "mma", "rugby", "cricket", "baseball", "cycling", "radsport", "ciclismo",
+		"wrestling", "athletics", "leichtathletik", "olympi", "спорт", "футбол", "хоккей"},
+	Movie: {"movie", "film", "spielfilm", "kinofilm", "fernsehfilm", "telefilm", "pelicula", "cine", "kino",
+		"фильм", "кино"},
+	News: {"news", "nachrichten", "actualite", "informations", "noticia", "notizie", "telegiornale", "nieuws",
+		"journal", "informativo", "weather", "wetter", "meteo", "новости"},
+	Series: {"series", "serie", "sitcom", "soap", "telenovela", "сериал"},
+}
+
+// Title patterns for the inference (folded title)
+var titlePatterns = map[string][]string{
+	Sports: {
+		`\S\s+(?:vs?\.?|@)\s+\S`,
+		`(?:^|[^\pL\pN])(?:premier league|bundesliga|la liga|serie a|ligue 1|eredivisie|champions league|europa league|` +
+			`nfl|nba|nhl|mlb|mls|ufc|wwe|formula 1|f1|grand prix|motogp|world cup|olympics)(?:$|[^\pL\pN])`,
+	},
+	Movie: {`\((?:19|20)\d{2}\)\s*$`},
+}
+
+var builtin []rule
+
+func init() {
+
+	for _, genre := range Standard {
+
+		var stems = make([]string, 0, len(keywords[genre]))
+		for _, stem := range keywords[genre] {
+			stems = append(stems, regexp.QuoteMeta(Fold(stem)))
+		}
+
+		builtin = append(builtin, rule{
+			re:     regexp.MustCompile(`(?:^|[^\pL\pN])(?:` + strings.Join(stems, "|") + `)`),
+			genre:  genre,
+			folded: true,
+		})
+
+	}
+
+	for _, genre := range Standard {
+		for _, pattern := range titlePatterns[genre] {
+			builtin = append(builtin, rule{re: regexp.MustCompile(pattern), genre: genre, title: true, folded: true})
+		}
+	}
+
+}
+
+// Fold : Lower case text without diacritics (Actualités -> actualites)
+func Fold(text string) string {
+
+	var b strings.Builder
+	for _, r := range norm.NFD.String(text) {
+		if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+
+	return b.String()
+}
+
+// Canonical : Standard spelling of a genre (sports -> Sports), ok is false for unknown genres
+func Canonical(genre string) (canonical string, ok bool) {
+
+	for _, g := range append(Standard, None) {
+		if strings.EqualFold(strings.TrimSpace(genre), g) {
+			return g, true
+		}
+	}
+
+	return "", false
+}
+
+// Mapper : Compiled rules
+type Mapper struct {
+	rules []rule
+	infer bool
+}
+
+// New : Mapper with the rules before the built-in rules, infer enables the title inference
+func New(rules []Rule, infer bool) (m *Mapper, err error) {
+
+	m = &Mapper{infer: infer}
+
+	// Rules of a source before the rules for all sources
+	for _, perSource := range []bool{true, false} {
+
+		for i, r := range rules {
+
+			if (len(r.Source) > 0) != perSource {
+				continue
+			}
+
+			genre, ok := Canonical(r.Genre)
+			if !ok {
+				return nil, fmt.Errorf("rule %d: unknown genre %q, use %s or %s", i+1, r.Genre, strings.Join(Standard, ", "), None)
+			}
+
+			if len(strings.TrimSpace(r.Pattern)) == 0 {
+				return nil, fmt.Errorf("rule %d: empty pattern", i+1)
+			}
+
+			re, err := regexp.Compile("(?i)" + r.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: %s", i+1, err)
+			}
+
+			m.rules = append(m.rules, rule{re: re, genre: genre, source: r.Source, title: r.Title})
+
+		}
+
+	}
+
+	m.rules = append(m.rules, builtin...)
+
+	return
+}
+
+// match : Genre of the first category (or title) rule matching the text
+func (m *Mapper) match(source, text string, title bool) (genre string, ok bool) {
+
+	var folded = Fold(text)
+
+	for _, r := range m.rules {
+
+		if r.title != title || (len(r.source) > 0 && r.source != source) {
+			continue
+		}
+
+		if r.folded && r.re.MatchString(folded) || !r.folded && r.re.MatchString(text) {
+			return r.genre, true
+		}
+
+	}
+
+	return
+}
+
+// Category : Genre of a category, ok is false if no rule matches or the genre is None.
+// For None the genre is returned anyway, the category is dropped.
+func (m *Mapper) Category(source, category string) (genre string, ok bool) {
+
+	if genre, ok = m.match(source, category, false); genre == None {
+		return None, false
+	}
+
+	return
+}
+
+// Title : Genre inferred from the title
+func (m *Mapper) Title(source, title string) (genre string, ok bool) {
+
+	if genre, ok = m.match(source, title, true); genre == None {
+		return "", false
+	}
+
+	return
+}
+
+// Normalize : Genres of the categories without duplicates. Without a genre the title is used if the inference is enabled.
+func (m *Mapper) Normalize(source string, categories []string, title string) (genres []string) {
+
+	var seen = make(map[string]bool)
+
+	for _, category := range categories {
+		if genre, ok := m.Category(source, category); ok && !seen[genre] {
+			seen[genre] = true
+			genres = append(genres, genre)
+		}
+	}
+
+	if len(genres) == 0 && m.infer && len(title) > 0 {
+		if genre, ok := m.Title(source, title); ok {
+			genres = append(genres, genre)
+		}
+	}
+
+	return
+}
@@ -0,0 +1,139 @@
+package genre
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuiltin(t *testing.T) {
+
+	m, err := New(nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = map[string]string{
+		"Sports":                 Sports,
+		"Fußball":                Sports,
+		"Sport > Football":       Sports,
+		"Deportes":               Sports,
+		"Calcio":                 Sports,
+		"Formula 1":              Sports,
+		"Спорт":                  Sports,
+		"Spielfilm":              Movie,
+		"Movie / Drama":          Movie,
+		"Película":               Movie,
+		"Téléfilm":               Movie,
+		"Nachrichten":            News,
+		"Actualités":             News,
+		"Noticias":               News,
+		"Kinder":                 Kids,
+		"Children's":             Kids,
+		"Jeunesse":               Kids,
+		"Dessin animé":           Kids,
+		"Serie":                  Series,
+		"Séries":                 Series,
+		"Sitcom":                 Series,
+		"Сериал":                 Series,
+		"Kinderfilm":             Kids,
+		"Sportnachrichten":       Sports,
+		"Documentary":            "",
+		"Talk":                   "",
+		"Motorsportmagazin Golf": Sports,
+		"Transport":              "",
+	}
+
+	for category, want := range tests {
+		if got, _ := m.Category("", category); got != want {
+			t.Errorf("%q: got %q, want %q", category, got, want)
+		}
+	}
+}
+
+func TestRules(t *testing.T) {
+
+	m, err := New([]Rule{
+		{Pattern: `^doku`, Genre: "series"},
+		{Pattern: `^sport`, Genre: "none", Source: "tvguide"},
+		{Pattern: `^magazin$`, Genre: "News", Source: "tvguide"},
+		{Pattern: `^Live: `, Genre: "sports", Title: true},
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := m.Category("other", "Doku-Soap"); got != Series {
+		t.Errorf("global rule: got %q", got)
+	}
+
+	// The rule of the source comes before the built-in rule
+	if got, ok := m.Category("tvguide", "Sportschau"); ok || got != None {
+		t.Errorf("source rule None: got %q, %t", got, ok)
+	}
+
+	if got, _ := m.Category("other", "Sportschau"); got != Sports {
+		t.Errorf("other source: got %q", got)
+	}
+
+	if got, _ := m.Category("other", "Magazin"); got != "" {
+		t.Errorf("rule of another source used: got %q", got)
+	}
+
+	if got := m.Normalize("x", []string{"Talk"}, "Live: Darts"); !reflect.DeepEqual(got, []string{Sports}) {
+		t.Errorf("title rule: got %v", got)
+	}
+
+	for _, rules := range [][]Rule{
+		{{Pattern: `x`, Genre: "Documentary"}},
+		{{Pattern: `(x`, Genre: "News"}},
+		{{Pattern: ` `, Genre: "News"}},
+	} {
+		if _, err := New(rules, false); err == nil {
+			t.Errorf("%v: no error", rules)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+
+	inferring, _ := New(nil, true)
+	plain, _ := New(nil, false)
+
+	var tests = []struct {
+		categories []string
+		title      string
+		want       []string
+	}{
+		{[]string{"Sport", "Fußball", "Live"}, "Bayern - Dortmund", []string{Sports}},
+		{[]string{"Spielfilm", "Kinder"}, "", []string{Movie, Kids}},
+		{[]string{"Entertainment"}, "Arsenal v Chelsea", []string{Sports}},
+		{nil, "Premier League: Highlights", []string{Sports}},
+		{nil, "Heat (1995)", []string{Movie}},
+		{[]string{"Talk"}, "Late Night", nil},
+		// Categories win over the title
+		{[]string{"News"}, "Arsenal v Chelsea", []string{News}},
+	}
+
+	for _, test := range tests {
+		if got := inferring.Normalize("", test.categories, test.title); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v %q: got %v, want %v", test.categories, test.title, got, test.want)
+		}
+	}
+
+	if got := plain.Normalize("", nil, "Arsenal v Chelsea"); got != nil {
+		t.Errorf("inference disabled: got %v", got)
+	}
+}
+
+func TestCanonical(t *testing.T) {
+
+	for input, want := range map[string]string{"sports": Sports, " MOVIE ": Movie, "none": None} {
+		if got, ok := Canonical(input); !ok || got != want {
+			t.Errorf("%q: got %q", input, got)
+		}
+	}
+
+	if _, ok := Canonical("Documentary"); ok {
+		t.Error("unknown genre accepted")
+	}
+}
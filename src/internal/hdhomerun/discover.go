@@ -0,0 +1,255 @@
+package hdhomerun
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Port : UDP port of the discovery
+const Port = 65001
+
+// Device : Tuner as announced in the discovery reply
+type Device struct {
+	ID         uint32
+	TunerCount int
+	BaseURL    string
+	LineupURL  string
+	DeviceAuth string
+
+	// Sender of the reply, only set by Discover
+	IP net.IP
+}
+
+var checksumTable = [16]uint32{0xA, 0x5, 0xF, 0x6, 0x7, 0xC, 0x1, 0xB, 0x9, 0x2, 0x8, 0xD, 0x4, 0x3, 0xE, 0x0}
+
+// checksum : XOR of all digits, every second digit through the table. The checksum of a valid ID is 0.
+func checksum(id uint32) (sum uint32) {
+
+	for shift := 28; shift > 0; shift -= 8 {
+		sum ^= checksumTable[id>>uint(shift)&0x0F]
+		sum ^= id >> uint(shift-4) & 0x0F
+	}
+
+	return
+}
+
+// ValidDeviceID : HDHomeRun device IDs contain a checksum in the last hex digit
+func ValidDeviceID(id uint32) bool {
+	return checksum(id) == 0
+}
+
+// DeviceID : Valid device ID for an ID string. 8 hex digits with a valid checksum are used as they are,
+// everything else is hashed, so the same string always gets the same ID.
+func DeviceID(s string) uint32 {
+
+	if len(s) == 8 {
+		if id, err := strconv.ParseUint(s, 16, 32); err == nil && ValidDeviceID(uint32(id)) {
+			return uint32(id)
+		}
+	}
+
+	var id = crc32.ChecksumIEEE([]byte(s)) &^ 0x0F
+	if id == DeviceIDWildcard&^0x0F {
+		id = 0
+	}
+
+	return id | checksum(id)
+}
+
+// FormatDeviceID : Device ID as used in discover.json
+func FormatDeviceID(id uint32) string {
+	return fmt.Sprintf("%08X", id)
+}
+
+// Request : Discovery request for tuners with the device ID (DeviceIDWildcard for all)
+func Request(id uint32) []byte {
+
+	var p = Packet{Type: TypeDiscoverRequest}
+	p.AddUint32(TagDeviceType, DeviceTypeTuner)
+	p.AddUint32(TagDeviceID, id)
+
+	return p.Marshal()
+}
+
+// Reply : Discovery reply of the device
+func (d Device) Reply() []byte {
+
+	var p = Packet{Type: TypeDiscoverReply}
+	p.AddUint32(TagDeviceType, DeviceTypeTuner)
+	p.AddUint32(TagDeviceID, d.ID)
+	p.Tags = append(p.Tags, Tag{Tag: TagTunerCount, Value: []byte{byte(d.TunerCount)}})
+	p.AddString(TagBaseURL, d.BaseURL)
+	p.AddString(TagLineupURL, d.LineupURL)
+	p.AddString(TagDeviceAuth, d.DeviceAuth)
+
+	return p.Marshal()
+}
+
+// Respond : Replies of all devices matching the discovery request
+func Respond(request []byte, devices []Device) (replies [][]byte, err error) {
+
+	p, err := Unmarshal(request)
+	if err != nil {
+		return
+	}
+
+	if p.Type != TypeDiscoverRequest || !requestsTuner(p) {
+		return
+	}
+
+	var id = DeviceIDWildcard
+	if value, ok := p.Uint32(TagDeviceID); ok {
+		id = value
+	}
+
+	for _, d := range devices {
+		if id == DeviceIDWildcard || id == d.ID {
+			replies = append(replies, d.Reply())
+		}
+	}
+
+	return
+}
+
+func requestsTuner(p Packet) bool {
+
+	var found bool
+
+	for _, tag := range p.Tags {
+
+		switch tag.Tag {
+
+		case TagDeviceType:
+			found = true
+			if len(tag.Value) == 4 && matchesTuner(binary.BigEndian.Uint32(tag.Value)) {
+				return true
+			}
+
+		case TagMultiType:
+			found = true
+			for v := tag.Value; len(v) >= 4; v = v[4:] {
+				if matchesTuner(binary.BigEndian.Uint32(v)) {
+					return true
+				}
+			}
+
+		}
+
+	}
+
+	// Requests without a device type ask for all devices
+	return !found
+}
+
+func matchesTuner(deviceType uint32) bool {
+	return deviceType == DeviceTypeTuner || deviceType == DeviceTypeWildcard
+}
+
+// ParseReply : Device of a discovery reply
+func ParseReply(reply []byte) (d Device, err error) {
+
+	p, err := Unmarshal(reply)
+	if err != nil {
+		return
+	}
+
+	if p.Type != TypeDiscoverReply {
+		err = fmt.Errorf("hdhomerun: unexpected packet type 0x%04X", p.Type)
+		return
+	}
+
+	var ok bool
+	if d.ID, ok = p.Uint32(TagDeviceID); !ok {
+		err = fmt.Errorf("hdhomerun: reply without device ID")
+		return
+	}
+
+	if value, ok := p.Get(TagTunerCount); ok && len(value) == 1 {
+		d.TunerCount = int(value[0])
+	}
+
+	d.BaseURL = tagString(p, TagBaseURL)
+	d.LineupURL = tagString(p, TagLineupURL)
+	d.DeviceAuth = tagString(p, TagDeviceAuth)
+
+	return
+}
+
+func tagString(p Packet, tag uint8) string {
+
+	value, _ := p.Get(tag)
+
+	return strings.TrimRight(string(value), "\x00")
+}
+
+// Discover : Sends a discovery request to the addresses (host:port, default is the broadcast address)
+// and collects the replies until the timeout. Every device is returned once.
+func Discover(addresses []string, timeout time.Duration) (devices []Device, err error) {
+
+	if len(addresses) == 0 {
+		addresses = []string{net.JoinHostPort("255.255.255.255", strconv.Itoa(Port))}
+	}
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var request = Request(DeviceIDWildcard)
+	var sent bool
+
+	for _, address := range addresses {
+
+		addr, errAddr := net.ResolveUDPAddr("udp4", address)
+		if errAddr != nil {
+			err = errAddr
+			continue
+		}
+
+		if _, errWrite := conn.WriteToUDP(request, addr); errWrite != nil {
+			err = errWrite
+			continue
+		}
+
+		sent = true
+
+	}
+
+	if !sent {
+		return
+	}
+
+	err = nil
+
+	var seen = make(map[uint32]bool)
+	var buffer = make([]byte, 1500)
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+
+	for {
+
+		n, addr, errRead := conn.ReadFromUDP(buffer)
+		if errRead != nil {
+			// Timeout, all replies are read
+			break
+		}
+
+		d, errReply := ParseReply(buffer[:n])
+		if errReply != nil || seen[d.ID] {
+			continue
+		}
+
+		d.IP = addr.IP
+		seen[d.ID] = true
+		devices = append(devices, d)
+
+	}
+
+	return
+}
@@ -0,0 +1,230 @@
+package hdhomerun
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPacket(t *testing.T) {
+
+	var long = bytes.Repeat([]byte("x"), 300)
+
+	var p = Packet{Type: TypeDiscoverReply}
+	p.AddUint32(TagDeviceID, 0x1234567A)
+	p.Tags = append(p.Tags, Tag{Tag: TagBaseURL, Value: long})
+
+	got, err := Unmarshal(p.Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id, _ := got.Uint32(TagDeviceID); id != 0x1234567A {
+		t.Errorf("device ID = %08X", id)
+	}
+
+	if value, _ := got.Get(TagBaseURL); !bytes.Equal(value, long) {
+		t.Errorf("base URL has %d bytes, want %d", len(value), len(long))
+	}
+
+	var b = p.Marshal()
+	b[5] ^= 0xFF
+	if _, err := Unmarshal(b); err != errCRC {
+		t.Errorf("corrupted packet: err = %v, want %v", err, errCRC)
+	}
+
+	if _, err := Unmarshal([]byte("discover")); err == nil {
+		t.Error("text request was accepted as packet")
+	}
+
+}
+
+// Discovery request as sent by hdhomerun_config discover
+func TestRequestBytes(t *testing.T) {
+
+	var want = []byte{
+		0x00, 0x02, 0x00, 0x0C,
+		0x01, 0x04, 0x00, 0x00, 0x00, 0x01,
+		0x02, 0x04, 0xFF, 0xFF, 0xFF, 0xFF,
+	}
+
+	var got = Request(DeviceIDWildcard)
+
+	if !bytes.Equal(got[:len(want)], want) || len(got) != len(want)+4 {
+		t.Errorf("Request() = % X", got)
+	}
+
+	if _, err := Unmarshal(got); err != nil {
+		t.Error(err)
+	}
+
+}
+
+func TestDeviceID(t *testing.T) {
+
+	// 5^0^5^0^A^F^9 = C
+	if !ValidDeviceID(0x10100F8C) {
+		t.Error("10100F8C is not valid")
+	}
+
+	if ValidDeviceID(0x10100F8B) {
+		t.Error("10100F8B is valid")
+	}
+
+	if id := DeviceID("10100F8C"); id != 0x10100F8C {
+		t.Errorf("DeviceID(10100F8C) = %08X", id)
+	}
+
+	var id = DeviceID("2026-10-ab12-cd34ef")
+	if !ValidDeviceID(id) || id != DeviceID("2026-10-ab12-cd34ef") {
+		t.Errorf("DeviceID = %08X, not valid or not stable", id)
+	}
+
+}
+
+func TestDiscover(t *testing.T) {
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+
+	var devices = []Device{
+		{ID: DeviceID("main"), TunerCount: 2, BaseURL: "http://127.0.0.1:34400", LineupURL: "http://127.0.0.1:34400/lineup.json"},
+		{ID: DeviceID("sports"), TunerCount: 4, BaseURL: "http://127.0.0.1:34400/devices/sports"},
+	}
+
+	go func() {
+
+		var buffer = make([]byte, 1500)
+
+		for {
+
+			n, addr, err := conn.ReadFromUDP(buffer)
+			if err != nil {
+				return
+			}
+
+			replies, _ := Respond(buffer[:n], devices)
+			for _, reply := range replies {
+				conn.WriteToUDP(reply, addr)
+			}
+
+		}
+
+	}()
+
+	found, err := Discover([]string{conn.LocalAddr().String()}, 300*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(found) != len(devices) {
+		t.Fatalf("found %d devices, want %d", len(found), len(devices))
+	}
+
+	for i, d := range found {
+
+		if d.IP == nil || !d.IP.IsLoopback() {
+			t.Errorf("device %d: IP = %v", i, d.IP)
+		}
+
+		d.IP = nil
+		if !reflect.DeepEqual(d, devices[i]) {
+			t.Errorf("device %d = %+v, want %+v", i, d, devices[i])
+		}
+
+	}
+
+	// Request for one device ID
+	var p = Packet{Type: TypeDiscoverRequest}
+	p.AddUint32(TagDeviceType, DeviceTypeWildcard)
+	p.AddUint32(TagDeviceID, devices[1].ID)
+
+	replies, err := Respond(p.Marshal(), devices)
+	if err != nil || len(replies) != 1 {
+		t.Fatalf("Respond() = %d replies, %v", len(replies), err)
+	}
+
+	// Storage devices are not answered
+	p = Packet{Type: TypeDiscoverRequest}
+	p.AddUint32(TagDeviceType, 0x00000005)
+
+	if replies, _ := Respond(p.Marshal(), devices); len(replies) != 0 {
+		t.Errorf("storage request got %d replies", len(replies))
+	}
+
+}
+
+// Fake tuner: discovery on UDP, discover.json on HTTP
+func TestFetchInfo(t *testing.T) {
+
+	var id = DeviceID("fake tuner")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.URL.Path != "/discover.json" {
+			http.NotFound(w, r)
+			return
+		}
+
+		fmt.Fprintf(w, `{"DeviceID":"%s","FriendlyName":"HDHomeRun PRIME","ModelNumber":"HDHR3-CC","TunerCount":3}`, FormatDeviceID(id))
+	}))
+	defer server.Close()
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+
+	go func() {
+
+		var buffer = make([]byte, 1500)
+
+		for {
+
+			n, addr, err := conn.ReadFromUDP(buffer)
+			if err != nil {
+				return
+			}
+
+			replies, _ := Respond(buffer[:n], []Device{{ID: id, TunerCount: 3, BaseURL: server.URL}})
+			for _, reply := range replies {
+				conn.WriteToUDP(reply, addr)
+			}
+
+		}
+
+	}()
+
+	found, err := Discover([]string{conn.LocalAddr().String()}, 300*time.Millisecond)
+	if err != nil || len(found) != 1 {
+		t.Fatalf("Discover() = %v, %v", found, err)
+	}
+
+	info, err := FetchInfo(server.Client(), found[0].BaseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want = Info{
+		DeviceID:     FormatDeviceID(id),
+		FriendlyName: "HDHomeRun PRIME",
+		ModelNumber:  "HDHR3-CC",
+		TunerCount:   3,
+		BaseURL:      server.URL,
+		LineupURL:    server.URL + "/lineup.json",
+	}
+
+	if info != want {
+		t.Errorf("FetchInfo() = %+v, want %+v", info, want)
+	}
+
+}
@@ -0,0 +1,53 @@
+package hdhomerun
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Info : discover.json of a device
+type Info struct {
+	DeviceID        string `json:"DeviceID"`
+	FriendlyName    string `json:"FriendlyName"`
+	ModelNumber     string `json:"ModelNumber"`
+	FirmwareVersion string `json:"FirmwareVersion"`
+	TunerCount      int    `json:"TunerCount"`
+	BaseURL         string `json:"BaseURL"`
+	LineupURL       string `json:"LineupURL"`
+}
+
+// FetchInfo : Loads discover.json from the base URL of the device
+func FetchInfo(client *http.Client, baseURL string) (info Info, err error) {
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Get(strings.TrimSuffix(baseURL, "/") + "/discover.json")
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("hdhomerun: %s/discover.json: %s", baseURL, resp.Status)
+		return
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&info)
+	if err != nil {
+		return
+	}
+
+	if len(info.BaseURL) == 0 {
+		info.BaseURL = baseURL
+	}
+
+	if len(info.LineupURL) == 0 {
+		info.LineupURL = strings.TrimSuffix(info.BaseURL, "/") + "/lineup.json"
+	}
+
+	return
+}
@@ -0,0 +1,176 @@
+// Package hdhomerun implements the binary HDHomeRun discovery protocol (UDP port 65001).
+//
+// A packet is
+//
+//	type (uint16, big endian) | length of the payload (uint16, big endian) | payload | CRC32 (IEEE, little endian)
+//
+// The payload is a list of tags: tag (uint8) | length (1 or 2 bytes) | value.
+// Lengths above 127 use two bytes, the low 7 bits with the high bit set followed by the remaining bits.
+package hdhomerun
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+)
+
+// Packet types
+const (
+	TypeDiscoverRequest uint16 = 0x0002
+	TypeDiscoverReply   uint16 = 0x0003
+)
+
+// Tags
+const (
+	TagDeviceType uint8 = 0x01
+	TagDeviceID   uint8 = 0x02
+	TagTunerCount uint8 = 0x10
+	TagLineupURL  uint8 = 0x27
+	TagBaseURL    uint8 = 0x2A
+	TagDeviceAuth uint8 = 0x2B
+	TagMultiType  uint8 = 0x2D
+)
+
+// Device types
+const (
+	DeviceTypeWildcard uint32 = 0xFFFFFFFF
+	DeviceTypeTuner    uint32 = 0x00000001
+	DeviceIDWildcard   uint32 = 0xFFFFFFFF
+)
+
+// Tag : Tag of a packet
+type Tag struct {
+	Tag   uint8
+	Value []byte
+}
+
+// Packet : Discovery packet
+type Packet struct {
+	Type uint16
+	Tags []Tag
+}
+
+var (
+	errShort  = errors.New("hdhomerun: packet too short")
+	errCRC    = errors.New("hdhomerun: invalid CRC")
+	errLength = errors.New("hdhomerun: invalid length")
+)
+
+// Marshal : Packet with header and CRC
+func (p Packet) Marshal() []byte {
+
+	var payload []byte
+
+	for _, tag := range p.Tags {
+
+		payload = append(payload, tag.Tag)
+
+		if len(tag.Value) <= 127 {
+			payload = append(payload, byte(len(tag.Value)))
+		} else {
+			payload = append(payload, byte(len(tag.Value)&0x7F)|0x80, byte(len(tag.Value)>>7))
+		}
+
+		payload = append(payload, tag.Value...)
+
+	}
+
+	var b = make([]byte, 4, 4+len(payload)+4)
+	binary.BigEndian.PutUint16(b[0:], p.Type)
+	binary.BigEndian.PutUint16(b[2:], uint16(len(payload)))
+	b = append(b, payload...)
+
+	return binary.LittleEndian.AppendUint32(b, crc32.ChecksumIEEE(b))
+}
+
+// Unmarshal : Checks the CRC and splits the payload into tags
+func Unmarshal(b []byte) (p Packet, err error) {
+
+	if len(b) < 8 {
+		return p, errShort
+	}
+
+	var length = int(binary.BigEndian.Uint16(b[2:]))
+	if len(b) < 4+length+4 {
+		return p, errLength
+	}
+
+	b = b[:4+length+4]
+
+	if crc32.ChecksumIEEE(b[:4+length]) != binary.LittleEndian.Uint32(b[4+length:]) {
+		return p, errCRC
+	}
+
+	p.Type = binary.BigEndian.Uint16(b)
+
+	var payload = b[4 : 4+length]
+
+	for len(payload) > 0 {
+
+		if len(payload) < 2 {
+			return p, errLength
+		}
+
+		var tag = payload[0]
+		var size = int(payload[1])
+		payload = payload[2:]
+
+		if size&0x80 != 0 {
+
+			if len(payload) < 1 {
+				return p, errLength
+			}
+
+			size = size&0x7F | int(payload[0])<<7
+			payload = payload[1:]
+
+		}
+
+		if len(payload) < size {
+			return p, errLength
+		}
+
+		p.Tags = append(p.Tags, Tag{Tag: tag, Value: payload[:size]})
+		payload = payload[size:]
+
+	}
+
+	return
+}
+
+// Get : Value of the first tag
+func (p Packet) Get(tag uint8) ([]byte, bool) {
+
+	for _, t := range p.Tags {
+		if t.Tag == tag {
+			return t.Value, true
+		}
+	}
+
+	return nil, false
+}
+
+// Uint32 : Value of a 4 byte tag
+func (p Packet) Uint32(tag uint8) (uint32, bool) {
+
+	value, ok := p.Get(tag)
+	if !ok || len(value) != 4 {
+		return 0, false
+	}
+
+	return binary.BigEndian.Uint32(value), true
+}
+
+// AddUint32 : Adds a 4 byte tag
+func (p *Packet) AddUint32(tag uint8, value uint32) {
+	p.Tags = append(p.Tags, Tag{Tag: tag, Value: binary.BigEndian.AppendUint32(nil, value)})
+}
+
+// AddString : Adds a string tag, empty strings are skipped
+func (p *Packet) AddString(tag uint8, value string) {
+
+	if len(value) > 0 {
+		p.Tags = append(p.Tags, Tag{Tag: tag, Value: []byte(value)})
+	}
+
+}
@@ -1,6 +1,7 @@
 package imgcache
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -24,9 +25,10 @@ type Cache struct {
 }
 
 type imageFunc struct {
-	GetURL  func(string, string, string, bool, int, string) string
-	Caching func()
-	Remove  func()
+	GetURL         func(string, string, string, bool, int, string) string
+	Caching        func()
+	CachingContext func(context.Context, func(done, total int))
+	Remove         func()
 }
 
 // New : New cahce
@@ -98,13 +100,26 @@ func New(path, cacheURL string, caching bool) (c *Cache, err error) {
 	}
 
 	c.Image.Caching = func() {
+		c.Image.CachingContext(context.Background(), nil)
+	}
+
+	// CachingContext : Caching with cancellation and progress (done / total images)
+	c.Image.CachingContext = func(ctx context.Context, progress func(done, total int)) {
 
 		c.Lock()
 		defer c.Unlock()
 
 		var filename string
 
-		for _, src := range c.Queue {
+		for i, src := range c.Queue {
+
+			if ctx.Err() != nil {
+				break
+			}
+
+			if progress != nil {
+				progress(i, len(c.Queue))
+			}
 
 			resp, err := http.Get(src)
 			if err != nil {
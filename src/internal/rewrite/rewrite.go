@@ -0,0 +1,258 @@
+// Package rewrite applies an ordered set of rules to the channel name, group, tvg-id and logo.
+//
+// Rule types:
+//
+//	replace       regular expression Pattern in Field is replaced with Value ($1 for groups)
+//	strip-prefix  Pattern is removed from the beginning of Field (repeated)
+//	strip-suffix  Pattern is removed from the end of Field (repeated)
+//	lower, upper, title
+//	              case transform of Field, optional Pattern limits the rule to matching values
+//	normalize     Unicode compatibility form (ᴿᴬᵂ -> RAW, ＨＤ -> HD)
+//	set-group     group is set to Value if Pattern matches Field
+//	set-logo      logo is set to Value if Pattern matches Field
+//
+// Field is one of name, group, tvg-id or logo, the default is name.
+package rewrite
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Rule : Rewrite rule
+type Rule struct {
+	Type          string
+	Field         string
+	Pattern       string
+	Value         string
+	CaseSensitive bool
+}
+
+// Channel : Values that can be rewritten
+type Channel struct {
+	Name  string
+	Group string
+	TvgID string
+	Logo  string
+}
+
+// Set : Compiled rules
+type Set struct {
+	rules []compiled
+}
+
+type compiled struct {
+	Rule
+	re *regexp.Regexp
+}
+
+var spaces = regexp.MustCompile(`\s{2,}`)
+
+// Compile : Check and compile the rules, the error contains the position of the invalid rule
+func Compile(rules []Rule) (s *Set, err error) {
+
+	s = &Set{}
+
+	for i, rule := range rules {
+
+		var c = compiled{Rule: rule}
+
+		if len(c.Field) == 0 {
+			c.Field = "name"
+		}
+
+		switch c.Field {
+		case "name", "group", "tvg-id", "logo":
+		default:
+			return nil, fmt.Errorf("rule %d: unknown field %q", i+1, rule.Field)
+		}
+
+		switch c.Type {
+
+		case "replace", "set-group", "set-logo":
+			if len(c.Pattern) == 0 {
+				return nil, fmt.Errorf("rule %d: %s needs a pattern", i+1, c.Type)
+			}
+			fallthrough
+
+		case "lower", "upper", "title":
+			if len(c.Pattern) > 0 {
+
+				var pattern = c.Pattern
+				if !c.CaseSensitive {
+					pattern = "(?i)" + pattern
+				}
+
+				c.re, err = regexp.Compile(pattern)
+				if err != nil {
+					return nil, fmt.Errorf("rule %d: %s", i+1, err)
+				}
+
+			}
+
+		case "strip-prefix", "strip-suffix":
+			if len(c.Pattern) == 0 {
+				return nil, fmt.Errorf("rule %d: %s needs a pattern", i+1, c.Type)
+			}
+
+		case "normalize":
+
+		default:
+			return nil, fmt.Errorf("rule %d: unknown type %q", i+1, rule.Type)
+
+		}
+
+		s.rules = append(s.rules, c)
+
+	}
+
+	return
+}
+
+// Len : Number of rules
+func (s *Set) Len() int {
+
+	if s == nil {
+		return 0
+	}
+
+	return len(s.rules)
+}
+
+// Apply : Apply all rules in order
+func (s *Set) Apply(channel Channel) Channel {
+
+	if s == nil {
+		return channel
+	}
+
+	for _, rule := range s.rules {
+
+		var value = channel.get(rule.Field)
+
+		switch rule.Type {
+
+		case "replace":
+			channel.set(rule.Field, rule.re.ReplaceAllString(value, rule.Value))
+
+		case "strip-prefix":
+			for hasPrefix(value, rule.Pattern, rule.CaseSensitive) {
+				value = strings.TrimLeftFunc(value[len(rule.Pattern):], unicode.IsSpace)
+			}
+			channel.set(rule.Field, value)
+
+		case "strip-suffix":
+			for hasSuffix(value, rule.Pattern, rule.CaseSensitive) {
+				value = strings.TrimRightFunc(value[:len(value)-len(rule.Pattern)], unicode.IsSpace)
+			}
+			channel.set(rule.Field, value)
+
+		case "lower", "upper", "title":
+			if rule.re != nil && !rule.re.MatchString(value) {
+				continue
+			}
+			channel.set(rule.Field, changeCase(rule.Type, value))
+
+		case "normalize":
+			channel.set(rule.Field, norm.NFKC.String(value))
+
+		case "set-group":
+			if rule.re.MatchString(value) {
+				channel.Group = rule.Value
+			}
+
+		case "set-logo":
+			if rule.re.MatchString(value) {
+				channel.Logo = rule.Value
+			}
+
+		}
+
+	}
+
+	return channel
+}
+
+func (c *Channel) get(field string) string {
+
+	switch field {
+	case "group":
+		return c.Group
+	case "tvg-id":
+		return c.TvgID
+	case "logo":
+		return c.Logo
+	}
+
+	return c.Name
+}
+
+func (c *Channel) set(field, value string) {
+
+	// Removed parts leave double spaces behind
+	value = strings.TrimSpace(spaces.ReplaceAllString(value, " "))
+
+	switch field {
+	case "group":
+		c.Group = value
+	case "tvg-id":
+		c.TvgID = value
+	case "logo":
+		c.Logo = value
+	default:
+		c.Name = value
+	}
+
+}
+
+func hasPrefix(value, prefix string, caseSensitive bool) bool {
+
+	if len(value) < len(prefix) {
+		return false
+	}
+
+	if caseSensitive {
+		return strings.HasPrefix(value, prefix)
+	}
+
+	return strings.EqualFold(value[:len(prefix)], prefix)
+}
+
+func hasSuffix(value, suffix string, caseSensitive bool) bool {
+
+	if len(value) < len(suffix) {
+		return false
+	}
+
+	if caseSensitive {
+		return strings.HasSuffix(value, suffix)
+	}
+
+	return strings.EqualFold(value[len(value)-len(suffix):], suffix)
+}
+
+func changeCase(kind, value string) string {
+
+	switch kind {
+
+	case "lower":
+		return strings.ToLower(value)
+
+	case "upper":
+		return strings.ToUpper(value)
+
+	}
+
+	var words = strings.Fields(strings.ToLower(value))
+	for i, word := range words {
+		var runes = []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		words[i] = string(runes)
+	}
+
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,71 @@
+package rewrite
+
+import "testing"
+
+func TestApply(t *testing.T) {
+
+	set, err := Compile([]Rule{
+		{Type: "normalize"},
+		{Type: "replace", Pattern: `^[A-Z]{2}\s*\|\s*`},
+		{Type: "replace", Pattern: `\s*\[(FHD|HD|SD)\]`},
+		{Type: "strip-suffix", Pattern: "RAW"},
+		{Type: "set-group", Pattern: `^sky sports`, Value: "Sports"},
+		{Type: "set-logo", Field: "tvg-id", Pattern: `^bbc1\.uk$`, Value: "http://logos/bbc1.png"},
+		{Type: "lower", Field: "tvg-id"},
+		{Type: "title", Field: "group", Pattern: `^news`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		in, want Channel
+	}{
+		{Channel{Name: "US| CNN [FHD]", Group: "NEWS", TvgID: "CNN.us"}, Channel{Name: "CNN", Group: "News", TvgID: "cnn.us"}},
+		{Channel{Name: "UK | Sky Sports Main Event ᴿᴬᵂ", Group: "UK"}, Channel{Name: "Sky Sports Main Event", Group: "Sports"}},
+		{Channel{Name: "BBC One", TvgID: "bbc1.uk", Logo: "old.png"}, Channel{Name: "BBC One", TvgID: "bbc1.uk", Logo: "http://logos/bbc1.png"}},
+	}
+
+	for _, test := range tests {
+		if got := set.Apply(test.in); got != test.want {
+			t.Errorf("Apply(%+v) = %+v, want %+v", test.in, got, test.want)
+		}
+	}
+
+}
+
+func TestStripPrefix(t *testing.T) {
+
+	set, err := Compile([]Rule{{Type: "strip-prefix", Pattern: "uk:"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := set.Apply(Channel{Name: "UK: UK: ITV"}).Name; got != "ITV" {
+		t.Errorf("got %q, want %q", got, "ITV")
+	}
+
+	set, _ = Compile([]Rule{{Type: "strip-prefix", Pattern: "uk:", CaseSensitive: true}})
+	if got := set.Apply(Channel{Name: "UK: ITV"}).Name; got != "UK: ITV" {
+		t.Errorf("case sensitive: got %q", got)
+	}
+
+}
+
+func TestCompileErrors(t *testing.T) {
+
+	var invalid = [][]Rule{
+		{{Type: "replace"}},
+		{{Type: "replace", Pattern: "("}},
+		{{Type: "unknown"}},
+		{{Type: "lower", Field: "url"}},
+		{{Type: "strip-prefix"}},
+	}
+
+	for _, rules := range invalid {
+		if _, err := Compile(rules); err == nil {
+			t.Errorf("Compile(%+v): expected an error", rules)
+		}
+	}
+
+}
@@ -0,0 +1,495 @@
+// Package xmltvindex indexes XMLTV files without decoding them.
+//
+// The file is read once as a stream of tokens, for every <channel> and <programme> element
+// below the root only the position in the file is kept. Single elements are read again
+// from the file when they are needed, so the memory does not depend on the size of the guide.
+// The index can be written next to the file and is reused as long as the file is unchanged.
+package xmltvindex
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// version of the index file, changes if the format changes
+const version = 1
+
+// Span : Position of an element in the file
+type Span struct {
+	Offset int64
+	Length int64
+}
+
+// Index : Elements of a XMLTV file
+type Index struct {
+	Version int
+	Size    int64
+	ModTime time.Time
+
+	// Channels : <channel> elements in the order of the file
+	Channels []Span
+
+	// Programmes : <programme> elements per channel ID in the order of the file
+	Programmes map[string][]Span
+}
+
+// Count : Number of indexed programmes
+func (ix *Index) Count() (n int) {
+
+	for _, spans := range ix.Programmes {
+		n += len(spans)
+	}
+
+	return
+}
+
+// Build : Index of the XMLTV data
+func Build(r io.Reader) (ix *Index, err error) {
+
+	var s = &scanner{r: bufio.NewReaderSize(r, 64*1024)}
+
+	ix = &Index{Version: version, Programmes: make(map[string][]Span)}
+
+	var depth int
+	var open string // <channel> or <programme> below the root
+	var openStart int64
+	var openChannel string
+
+	var closeElement = func(end int64) {
+
+		var span = Span{Offset: openStart, Length: end - openStart}
+
+		switch open {
+		case "channel":
+			ix.Channels = append(ix.Channels, span)
+		case "programme":
+			ix.Programmes[openChannel] = append(ix.Programmes[openChannel], span)
+		}
+
+		open = ""
+
+	}
+
+	for {
+
+		start, err := s.next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		tag, err := s.tag()
+		if err != nil {
+			return nil, err
+		}
+
+		switch tag.kind {
+
+		case tagEnd:
+			depth--
+			if depth == 1 && len(open) > 0 {
+				closeElement(s.off)
+			}
+
+		case tagStart:
+			if depth == 1 && (tag.name == "channel" || tag.name == "programme") {
+				open = tag.name
+				openStart = start
+				openChannel = tag.channel
+			}
+
+			if tag.selfClosing {
+				if depth == 1 && len(open) > 0 {
+					closeElement(s.off)
+				}
+				continue
+			}
+
+			depth++
+
+		}
+
+	}
+
+	if depth != 0 {
+		return nil, errors.New("xmltvindex: unexpected end of file")
+	}
+
+	return ix, nil
+}
+
+// BuildFile : Index of the XMLTV file
+func BuildFile(file string) (ix *Index, err error) {
+
+	f, err := os.Open(file)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return
+	}
+
+	ix, err = Build(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(file), err)
+	}
+
+	ix.Size = info.Size()
+	ix.ModTime = info.ModTime()
+
+	return
+}
+
+// Open : Index of the XMLTV file. If indexFile is set, a valid index is read from there,
+// otherwise the index is built and written to indexFile.
+func Open(file, indexFile string) (ix *Index, err error) {
+
+	if len(indexFile) > 0 {
+
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+
+		if ix, err = readIndex(indexFile); err == nil && ix.Version == version && ix.Size == info.Size() && ix.ModTime.Equal(info.ModTime()) {
+			return ix, nil
+		}
+
+	}
+
+	ix, err = BuildFile(file)
+	if err != nil || len(indexFile) == 0 {
+		return
+	}
+
+	err = writeIndex(indexFile, ix)
+
+	return
+}
+
+// Read : Calls fn with the raw XML of every span. The slice is only valid until fn returns.
+func Read(file string, spans []Span, fn func(element []byte) error) (err error) {
+
+	f, err := os.Open(file)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	var buffer []byte
+
+	for _, span := range spans {
+
+		if int64(cap(buffer)) < span.Length {
+			buffer = make([]byte, span.Length)
+		}
+
+		buffer = buffer[:span.Length]
+
+		if _, err = f.ReadAt(buffer, span.Offset); err != nil {
+			return
+		}
+
+		if err = fn(buffer); err != nil {
+			return
+		}
+
+	}
+
+	return
+}
+
+func readIndex(indexFile string) (ix *Index, err error) {
+
+	f, err := os.Open(indexFile)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	ix = &Index{}
+	err = gob.NewDecoder(bufio.NewReader(f)).Decode(ix)
+
+	return
+}
+
+func writeIndex(indexFile string, ix *Index) (err error) {
+
+	if err = os.MkdirAll(filepath.Dir(indexFile), 0755); err != nil {
+		return
+	}
+
+	var tmp = indexFile + ".tmp"
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return
+	}
+
+	var w = bufio.NewWriter(f)
+
+	if err = gob.NewEncoder(w).Encode(ix); err == nil {
+		err = w.Flush()
+	}
+
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+
+	if err != nil {
+		os.Remove(tmp)
+		return
+	}
+
+	return os.Rename(tmp, indexFile)
+}
+
+// Tokenizer
+
+const (
+	tagStart = iota
+	tagEnd
+	tagOther // comment, CDATA, processing instruction, doctype
+)
+
+type tag struct {
+	kind        int
+	name        string
+	channel     string // channel attribute of <programme>
+	selfClosing bool
+}
+
+type scanner struct {
+	r   *bufio.Reader
+	off int64
+	buf []byte
+}
+
+// next : Skips the text up to the next '<', returns the offset of the '<'
+func (s *scanner) next() (start int64, err error) {
+
+	for {
+
+		line, err := s.r.ReadSlice('<')
+		s.off += int64(len(line))
+
+		if err == nil {
+			return s.off - 1, nil
+		}
+
+		if err != bufio.ErrBufferFull {
+			return 0, err
+		}
+
+	}
+
+}
+
+func (s *scanner) readByte() (b byte, err error) {
+
+	b, err = s.r.ReadByte()
+	if err == nil {
+		s.off++
+	} else if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return
+}
+
+// skipUntil : Skips everything up to and including the terminator
+func (s *scanner) skipUntil(terminator string) (err error) {
+
+	var matched int
+
+	for matched < len(terminator) {
+
+		b, err := s.readByte()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case b == terminator[matched]:
+			matched++
+		case b == terminator[0]:
+			matched = 1
+		default:
+			matched = 0
+		}
+
+	}
+
+	return
+}
+
+// tag : Reads the tag after '<'
+func (s *scanner) tag() (t tag, err error) {
+
+	b, err := s.readByte()
+	if err != nil {
+		return
+	}
+
+	switch b {
+
+	case '?':
+		t.kind = tagOther
+		err = s.skipUntil("?>")
+		return
+
+	case '!':
+		t.kind = tagOther
+		err = s.skipDeclaration()
+		return
+
+	case '/':
+		t.kind = tagEnd
+		err = s.skipUntil(">")
+		return
+
+	}
+
+	// Start tag: read up to '>' outside of quoted attribute values
+	t.kind = tagStart
+	s.buf = append(s.buf[:0], b)
+
+	var quote byte
+
+	for {
+
+		b, err = s.readByte()
+		if err != nil {
+			return
+		}
+
+		if quote != 0 {
+			if b == quote {
+				quote = 0
+			}
+		} else if b == '"' || b == '\'' {
+			quote = b
+		} else if b == '>' {
+			break
+		}
+
+		s.buf = append(s.buf, b)
+
+	}
+
+	if n := len(s.buf); n > 0 && s.buf[n-1] == '/' {
+		t.selfClosing = true
+		s.buf = s.buf[:n-1]
+	}
+
+	var i int
+	for i < len(s.buf) && !isSpace(s.buf[i]) {
+		i++
+	}
+
+	t.name = string(s.buf[:i])
+
+	if t.name == "programme" {
+		t.channel = attribute(s.buf[i:], "channel")
+	}
+
+	return
+}
+
+// skipDeclaration : Comment, CDATA section or declaration (<!DOCTYPE ...> with internal subset)
+func (s *scanner) skipDeclaration() (err error) {
+
+	peek, _ := s.r.Peek(7)
+
+	switch {
+	case len(peek) >= 2 && string(peek[:2]) == "--":
+		return s.skipUntil("-->")
+	case len(peek) >= 7 && string(peek) == "[CDATA[":
+		return s.skipUntil("]]>")
+	}
+
+	var brackets int
+
+	for {
+
+		b, err := s.readByte()
+		if err != nil {
+			return err
+		}
+
+		switch b {
+		case '[':
+			brackets++
+		case ']':
+			brackets--
+		case '>':
+			if brackets <= 0 {
+				return nil
+			}
+		}
+
+	}
+
+}
+
+// attribute : Value of the attribute in the attribute list of a start tag
+func attribute(attrs []byte, name string) string {
+
+	var i int
+
+	for i < len(attrs) {
+
+		for i < len(attrs) && isSpace(attrs[i]) {
+			i++
+		}
+
+		var start = i
+		for i < len(attrs) && attrs[i] != '=' && !isSpace(attrs[i]) {
+			i++
+		}
+
+		var key = string(attrs[start:i])
+
+		for i < len(attrs) && (isSpace(attrs[i]) || attrs[i] == '=') {
+			i++
+		}
+
+		if i >= len(attrs) || (attrs[i] != '"' && attrs[i] != '\'') {
+			return ""
+		}
+
+		var quote = attrs[i]
+		i++
+
+		start = i
+		for i < len(attrs) && attrs[i] != quote {
+			i++
+		}
+
+		if key == name {
+			return html.UnescapeString(string(attrs[start:i]))
+		}
+
+		i++
+
+	}
+
+	return ""
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
@@ -0,0 +1,242 @@
+package xmltvindex
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const guide = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE tv SYSTEM "xmltv.dtd" [ <!ENTITY x "y"> ]>
+<tv generator-info-name="test">
+  <!-- <programme channel="comment"></programme> -->
+  <channel id="one.de"><display-name>One</display-name><icon src="http://x/one.png"/></channel>
+  <channel id="a&amp;b"><display-name>A &amp; B</display-name></channel>
+  <programme start="20240101100000 +0000" stop="20240101110000 +0000" channel="one.de">
+    <title lang="de">News</title>
+    <desc><![CDATA[</programme> inside <b>CDATA</b>]]></desc>
+  </programme>
+  <programme channel='a&amp;b' start="20240101100000 +0000" stop="20240101103000 +0000"><title>Show &gt; 1</title></programme>
+  <programme start="20240101110000 +0000" stop="20240101120000 +0000" channel="one.de"/>
+</tv>
+`
+
+type programme struct {
+	Channel string `xml:"channel,attr"`
+	Start   string `xml:"start,attr"`
+	Title   string `xml:"title"`
+	Desc    string `xml:"desc"`
+}
+
+func TestBuild(t *testing.T) {
+
+	ix, err := Build(strings.NewReader(guide))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ix.Channels) != 2 {
+		t.Fatalf("channels = %d, want 2", len(ix.Channels))
+	}
+
+	if ix.Count() != 3 || len(ix.Programmes["one.de"]) != 2 || len(ix.Programmes["a&b"]) != 1 {
+		t.Fatalf("programmes = %v", ix.Programmes)
+	}
+
+	var decode = func(span Span) (p programme) {
+
+		if err := xml.Unmarshal([]byte(guide[span.Offset:span.Offset+span.Length]), &p); err != nil {
+			t.Fatalf("%q: %s", guide[span.Offset:span.Offset+span.Length], err)
+		}
+
+		return
+	}
+
+	if p := decode(ix.Programmes["one.de"][0]); p.Title != "News" || p.Desc != "</programme> inside <b>CDATA</b>" {
+		t.Errorf("first programme = %+v", p)
+	}
+
+	if p := decode(ix.Programmes["a&b"][0]); p.Channel != "a&b" || p.Title != "Show > 1" {
+		t.Errorf("second programme = %+v", p)
+	}
+
+	if p := decode(ix.Programmes["one.de"][1]); p.Start != "20240101110000 +0000" {
+		t.Errorf("self closing programme = %+v", p)
+	}
+
+	var channel = guide[ix.Channels[0].Offset : ix.Channels[0].Offset+ix.Channels[0].Length]
+	if !strings.HasPrefix(channel, `<channel id="one.de">`) || !strings.HasSuffix(channel, "</channel>") {
+		t.Errorf("channel = %q", channel)
+	}
+
+}
+
+func TestBuildInvalid(t *testing.T) {
+
+	for _, data := range []string{
+		`<tv><programme channel="x">`,
+		`<tv><programme channel="x`,
+		`<tv><!-- open`,
+	} {
+
+		if _, err := Build(strings.NewReader(data)); err == nil {
+			t.Errorf("%q: no error", data)
+		}
+
+	}
+
+}
+
+func TestOpen(t *testing.T) {
+
+	var dir = t.TempDir()
+	var file = filepath.Join(dir, "guide.xml")
+	var indexFile = filepath.Join(dir, "index", "guide.idx")
+
+	if err := os.WriteFile(file, []byte(guide), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ix, err := Open(file, indexFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(indexFile); err != nil {
+		t.Fatalf("index not written: %s", err)
+	}
+
+	cached, err := Open(file, indexFile)
+	if err != nil || cached.Count() != ix.Count() {
+		t.Fatalf("cached index: %v, %v", cached, err)
+	}
+
+	var titles []string
+	err = Read(file, cached.Programmes["one.de"], func(element []byte) error {
+		var p programme
+		err := xml.Unmarshal(element, &p)
+		titles = append(titles, p.Title)
+		return err
+	})
+
+	if err != nil || len(titles) != 2 || titles[0] != "News" {
+		t.Fatalf("read: %v, %v", titles, err)
+	}
+
+	// The file changes, the index is built again
+	var changed = strings.Replace(guide, "</tv>", `<programme channel="new" start="" stop=""/></tv>`, 1)
+	if err := os.WriteFile(file, []byte(changed), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Chtimes(file, time.Now().Add(time.Hour), time.Now().Add(time.Hour))
+
+	ix, err = Open(file, indexFile)
+	if err != nil || len(ix.Programmes["new"]) != 1 {
+		t.Fatalf("changed file: %v, %v", ix, err)
+	}
+
+}
+
+// syntheticGuide : Guide with channels × programmes programmes
+func syntheticGuide(channels, programmes int) []byte {
+
+	var b bytes.Buffer
+
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n<tv>\n")
+
+	for c := 0; c < channels; c++ {
+		fmt.Fprintf(&b, `  <channel id="ch%d.example"><display-name>Channel %d</display-name><icon src="http://example.com/%d.png"/></channel>`+"\n", c, c, c)
+	}
+
+	var start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for p := 0; p < programmes; p++ {
+
+		var t = start.Add(time.Duration(p) * 30 * time.Minute)
+
+		for c := 0; c < channels; c++ {
+			fmt.Fprintf(&b, `  <programme start="%s +0000" stop="%s +0000" channel="ch%d.example">`, t.Format("20060102150405"), t.Add(30*time.Minute).Format("20060102150405"), c)
+			fmt.Fprintf(&b, `<title lang="en">Programme %d</title><desc lang="en">Description of programme %d on channel %d with some more text to get a realistic size.</desc>`, p, p, c)
+			b.WriteString(`<category lang="en">Series</category><episode-num system="xmltv_ns">1.2.</episode-num></programme>` + "\n")
+		}
+
+	}
+
+	b.WriteString("</tv>\n")
+
+	return b.Bytes()
+}
+
+func BenchmarkBuild(b *testing.B) {
+
+	var data = syntheticGuide(200, 500)
+
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := Build(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+}
+
+// BenchmarkUnmarshal : Decoding the whole guide, as without the index
+func BenchmarkUnmarshal(b *testing.B) {
+
+	var data = syntheticGuide(200, 500)
+
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+
+		var tv struct {
+			Programmes []programme `xml:"programme"`
+		}
+
+		if err := xml.Unmarshal(data, &tv); err != nil {
+			b.Fatal(err)
+		}
+
+	}
+
+}
+
+func BenchmarkReadChannel(b *testing.B) {
+
+	var file = filepath.Join(b.TempDir(), "guide.xml")
+	if err := os.WriteFile(file, syntheticGuide(200, 500), 0644); err != nil {
+		b.Fatal(err)
+	}
+
+	ix, err := BuildFile(file)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+
+		var spans = ix.Programmes[fmt.Sprintf("ch%d.example", i%200)]
+
+		err := Read(file, spans, func(element []byte) error {
+			var p programme
+			return xml.Unmarshal(element, &p)
+		})
+
+		if err != nil {
+			b.Fatal(err)
+		}
+
+	}
+
+}
@@ -0,0 +1,333 @@
+package src
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// jobEntry : Laufzeitstatus eines Hintergrund Jobs
+type jobEntry struct {
+	JobStruct
+
+	queue  string
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+	run    func(job *jobEntry) error
+}
+
+const (
+	jobHistoryLimit = 50
+	jobLogLimit     = 200
+)
+
+var (
+	jobMutex   sync.Mutex
+	jobList    []*jobEntry
+	jobPending = make(map[string][]*jobEntry)
+	jobWorkers = make(map[string]bool)
+	jobEvents  = make(chan JobStruct, 100)
+	jobOnce    sync.Once
+)
+
+// Job starten. Jobs der gleichen Warteschlange laufen nacheinander, ein noch wartender Job mit dem
+// gleichen Schlüssel wird wiederverwendet, anstatt einen zweiten hinzuzufügen.
+func startJob(jobType, key, queue, description string, run func(job *jobEntry) error) (job *jobEntry) {
+
+	jobOnce.Do(func() {
+		go broadcastJobEvents()
+	})
+
+	jobMutex.Lock()
+	defer jobMutex.Unlock()
+
+	for _, pending := range jobPending[queue] {
+		if pending.Key == key {
+			pending.log("Request coalesced")
+			return pending
+		}
+	}
+
+	job = &jobEntry{
+		JobStruct: JobStruct{
+			ID:          randomString(12),
+			Type:        jobType,
+			Key:         key,
+			Description: description,
+			Status:      "queued",
+			Log:         []string{},
+			Created:     time.Now().Format("2006-01-02 15:04:05"),
+		},
+		queue: queue,
+		done:  make(chan struct{}),
+		run:   run,
+	}
+
+	job.ctx, job.cancel = context.WithCancel(context.Background())
+
+	jobList = append(jobList, job)
+	jobPending[queue] = append(jobPending[queue], job)
+	removeFinishedJobs()
+
+	if !jobWorkers[queue] {
+		jobWorkers[queue] = true
+		go runJobQueue(queue)
+	}
+
+	job.publish()
+
+	return
+}
+
+// Jobs einer Warteschlange ausführen, bis sie leer ist
+func runJobQueue(queue string) {
+
+	for {
+
+		jobMutex.Lock()
+		if len(jobPending[queue]) == 0 {
+			delete(jobPending, queue)
+			jobWorkers[queue] = false
+			jobMutex.Unlock()
+			return
+		}
+
+		var job = jobPending[queue][0]
+		jobPending[queue] = jobPending[queue][1:]
+
+		job.Status = "running"
+		job.Started = time.Now().Format("2006-01-02 15:04:05")
+		job.publish()
+		jobMutex.Unlock()
+
+		var err = job.run(job)
+
+		jobMutex.Lock()
+		job.finish(err)
+		jobMutex.Unlock()
+
+	}
+
+}
+
+// finish : jobMutex muss gesperrt sein
+func (job *jobEntry) finish(err error) {
+
+	job.Finished = time.Now().Format("2006-01-02 15:04:05")
+
+	switch {
+
+	case job.ctx.Err() != nil:
+		job.Status = "canceled"
+		job.err = errors.New(getErrMsg(1018))
+		job.Error = job.err.Error()
+
+	case err != nil:
+		job.Status = "failed"
+		job.err = err
+		job.Error = err.Error()
+
+	default:
+		job.Status = "done"
+		job.Progress = 100
+
+	}
+
+	job.cancel()
+	close(job.done)
+	job.publish()
+
+}
+
+// Die letzten beendeten Jobs behalten, laufende und wartende Jobs werden nie entfernt
+func removeFinishedJobs() {
+
+	var finished int
+	for _, job := range jobList {
+		if job.Finished != "" {
+			finished++
+		}
+	}
+
+	if finished <= jobHistoryLimit {
+		return
+	}
+
+	var list = make([]*jobEntry, 0, len(jobList))
+	for _, job := range jobList {
+
+		if job.Finished != "" && finished > jobHistoryLimit {
+			finished--
+			continue
+		}
+
+		list = append(list, job)
+
+	}
+
+	jobList = list
+
+}
+
+// Warten, bis der Job beendet ist, und seinen Fehler zurückgeben
+func (job *jobEntry) wait() error {
+
+	<-job.done
+
+	jobMutex.Lock()
+	defer jobMutex.Unlock()
+
+	return job.err
+}
+
+// Der Job soll so schnell wie möglich beendet werden
+func (job *jobEntry) canceled() bool {
+	return job.ctx.Err() != nil
+}
+
+func (job *jobEntry) setProgress(progress int) {
+
+	if progress < 0 {
+		progress = 0
+	} else if progress > 100 {
+		progress = 100
+	}
+
+	jobMutex.Lock()
+	defer jobMutex.Unlock()
+
+	if job.Progress != progress {
+		job.Progress = progress
+		job.publish()
+	}
+
+}
+
+func (job *jobEntry) setResult(result string) {
+
+	jobMutex.Lock()
+	job.Result = result
+	jobMutex.Unlock()
+
+}
+
+func (job *jobEntry) addLog(format string, a ...interface{}) {
+
+	jobMutex.Lock()
+	job.log(format, a...)
+	jobMutex.Unlock()
+
+}
+
+// log : jobMutex muss gesperrt sein
+func (job *jobEntry) log(format string, a ...interface{}) {
+
+	var line = fmt.Sprintf("[%s] %s", time.Now().Format("15:04:05"), fmt.Sprintf(format, a...))
+
+	job.Log = append(job.Log, line)
+	if len(job.Log) > jobLogLimit {
+		job.Log = job.Log[len(job.Log)-jobLogLimit:]
+	}
+
+	job.publish()
+
+}
+
+// publish : Kopie des Jobs an die WebSocket Clients senden, jobMutex muss gesperrt sein
+func (job *jobEntry) publish() {
+
+	select {
+	case jobEvents <- job.snapshot():
+	default:
+		// Der Job wird nicht blockiert, wenn die Clients zu langsam sind
+	}
+
+}
+
+func (job *jobEntry) snapshot() (s JobStruct) {
+
+	s = job.JobStruct
+	s.Log = append([]string{}, job.Log...)
+
+	return
+}
+
+func broadcastJobEvents() {
+
+	for job := range jobEvents {
+		broadcastWebSocket(JobMessage{Cmd: "job", Job: job})
+	}
+
+}
+
+// Status aller Jobs für die API (älteste zuerst)
+func getJobs() (jobs []JobStruct) {
+
+	jobMutex.Lock()
+	defer jobMutex.Unlock()
+
+	jobs = make([]JobStruct, 0, len(jobList))
+	for _, job := range jobList {
+		jobs = append(jobs, job.snapshot())
+	}
+
+	return
+}
+
+func getJob(id string) (job JobStruct, err error) {
+
+	jobMutex.Lock()
+	defer jobMutex.Unlock()
+
+	for _, j := range jobList {
+		if j.ID == id {
+			return j.snapshot(), nil
+		}
+	}
+
+	err = errors.New(getErrMsg(1017))
+	return
+}
+
+// Wartenden oder laufenden Job abbrechen
+func cancelJob(id string) (err error) {
+
+	jobMutex.Lock()
+	defer jobMutex.Unlock()
+
+	for _, job := range jobList {
+
+		if job.ID != id {
+			continue
+		}
+
+		switch job.Status {
+
+		case "queued":
+			var pending = jobPending[job.queue]
+			for i, p := range pending {
+				if p == job {
+					jobPending[job.queue] = append(pending[:i:i], pending[i+1:]...)
+					break
+				}
+			}
+
+			job.cancel()
+			job.finish(nil)
+
+		case "running":
+			job.cancel()
+			job.log("Cancel requested")
+
+		}
+
+		return
+	}
+
+	err = errors.New(getErrMsg(1017))
+	return
+}
@@ -2,6 +2,7 @@ package src
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"net/url"
@@ -11,7 +12,9 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"threadfin/src/internal/filterexpr"
 	m3u "threadfin/src/internal/m3u-parser"
 )
 
@@ -36,150 +39,151 @@ func parsePlaylist(filename, fileType string) (channels []interface{}, err error
 	return
 }
 
-// Streams filtern
+// Streams filtern. Der erste passende Filter (Priorität) entscheidet, ob der Stream aktiv ist
 func filterThisStream(s interface{}) (status bool, liveEvent bool) {
 
-	status = false
-	var stream = s.(map[string]string)
-	var regexpYES = `[{]+[^.]+[}]`
-	var regexpNO = `!+[{]+[^.]+[}]`
-
-	liveEvent = false
+	var stream = filterRecord(s.(map[string]string))
 
 	for _, filter := range Data.Filter {
 
-		if filter.Rule == "" {
-			continue
-		}
-
-		liveEvent = filter.LiveEvent
+		if filter.Expression.Match(stream) {
 
-		var group, name, search string
-		var exclude, include string
-		var match = false
-
-		var streamValues = strings.Replace(stream["_values"], "\r", "", -1)
+			if filter.Action == "exclude" {
+				return false, false
+			}
 
-		if v, ok := stream["group-title"]; ok {
-			group = v
+			return true, filter.LiveEvent
 		}
 
-		if v, ok := stream["name"]; ok {
-			name = v
-		}
+	}
 
-		// Unerwünschte Streams !{DEU}
-		r := regexp.MustCompile(regexpNO)
-		val := r.FindStringSubmatch(filter.Rule)
+	return false, false
+}
 
-		if len(val) == 1 {
+// filterRecord : Stream Werte für die Filter Ausdrücke
+type filterRecord map[string]string
 
-			exclude = val[0][2 : len(val[0])-1]
-			filter.Rule = strings.Replace(filter.Rule, " "+val[0], "", -1)
-			filter.Rule = strings.Replace(filter.Rule, val[0], "", -1)
+// Get : Wert eines Feldes, resolution stammt aus den ffprobe Daten
+func (r filterRecord) Get(key string) string {
 
-		}
+	switch key {
 
-		// Muss zusätzlich erfüllt sein {DEU}
-		r = regexp.MustCompile(regexpYES)
-		val = r.FindStringSubmatch(filter.Rule)
+	case "_values":
+		return strings.Replace(r[key], "\r", "", -1)
 
-		if len(val) == 1 {
+	case "resolution":
+		return getProbeInfo(r["url"]).Resolution
 
-			include = val[0][1 : len(val[0])-1]
-			filter.Rule = strings.Replace(filter.Rule, " "+val[0], "", -1)
-			filter.Rule = strings.Replace(filter.Rule, val[0], "", -1)
+	}
 
-		}
+	return r[key]
+}
 
-		switch filter.CaseSensitive {
+// Filter kompilieren
+func compileFilter(id int64, filter FilterStruct) (dataFilter Filter, err error) {
 
-		case false:
+	var expression = filter.Expression
+	if len(expression) == 0 {
+		expression = legacyFilterExpression(filter)
+	}
 
-			streamValues = strings.ToLower(streamValues)
-			filter.Rule = strings.ToLower(filter.Rule)
-			exclude = strings.ToLower(exclude)
-			include = strings.ToLower(include)
-			group = strings.ToLower(group)
-			name = strings.ToLower(name)
+	dataFilter.Action = filter.Action
+	dataFilter.CaseSensitive = filter.CaseSensitive
+	dataFilter.ID = id
+	dataFilter.LiveEvent = filter.LiveEvent
+	dataFilter.Name = filter.Name
+	dataFilter.Numbering = filter.Numbering
+	dataFilter.NumberAuto = filter.NumberingAuto
+	dataFilter.NumberBlock = filter.NumberingBlock
+	dataFilter.Priority = filter.Priority
+	dataFilter.StartNumber, _ = strconv.ParseFloat(strings.TrimSpace(filter.StartingNumber), 64)
+	dataFilter.Rule = expression
+	dataFilter.Type = filter.Type
+
+	if len(dataFilter.Action) == 0 {
+		dataFilter.Action = "include"
+	}
 
-		}
+	if len(expression) == 0 {
+		err = errors.New(getErrMsg(1014))
+		return
+	}
 
-		switch filter.Type {
+	dataFilter.Expression, err = filterexpr.Compile(expression, filterexpr.Options{CaseSensitive: filter.CaseSensitive})
+	if err != nil {
+		err = fmt.Errorf("%s (%s): %s", getErrMsg(1014), filter.Name, err)
+	}
 
-		case "group-title":
-			search = name
+	return
+}
 
-			if group == filter.Rule {
-				match = true
-			}
+// Alte Filterregeln (group-title / custom-filter mit {include} und !{exclude}) als Ausdruck
+func legacyFilterExpression(filter FilterStruct) (expression string) {
 
-		case "custom-filter":
-			search = streamValues
-			if strings.Contains(search, filter.Rule) {
-				match = true
-			}
-		}
+	var rule, include, exclude, field string
 
-		if match == true {
+	switch filter.Type {
 
-			if len(exclude) > 0 {
-				var status = checkConditions(search, exclude, "exclude")
-				if status == false {
-					return false, liveEvent
-				}
-			}
+	case "group-title":
+		if len(filter.Filter) == 0 {
+			return
+		}
 
-			if len(include) > 0 {
-				var status = checkConditions(search, include, "include")
-				if status == false {
-					return false, liveEvent
-				}
-			}
+		rule = "group=" + filterexpr.Quote(filter.Filter)
+		include = filter.Include
+		exclude = filter.Exclude
+		field = "name"
 
-			return true, liveEvent
+	case "custom-filter":
+		var text = filter.Filter
 
+		// Unerwünschte Streams !{DEU}
+		if val := regexp.MustCompile(`!+[{]+[^.]+[}]`).FindString(text); len(val) > 0 {
+			exclude = val[2 : len(val)-1]
+			text = strings.Replace(text, " "+val, "", -1)
+			text = strings.Replace(text, val, "", -1)
 		}
 
-	}
-
-	return false, liveEvent
-}
-
-// Bedingungen für den Filter
-func checkConditions(streamValues, conditions, coType string) (status bool) {
+		// Muss zusätzlich erfüllt sein {DEU}
+		if val := regexp.MustCompile(`[{]+[^.]+[}]`).FindString(text); len(val) > 0 {
+			include = val[1 : len(val)-1]
+			text = strings.Replace(text, " "+val, "", -1)
+			text = strings.Replace(text, val, "", -1)
+		}
 
-	switch coType {
+		if len(text) == 0 {
+			return
+		}
 
-	case "exclude":
-		status = true
+		rule = "any:" + filterexpr.Quote(text)
+		field = "any"
 
-	case "include":
-		status = false
+	default:
+		return
 
 	}
 
-	conditions = strings.Replace(conditions, ", ", ",", -1)
-	conditions = strings.Replace(conditions, " ,", ",", -1)
-
-	var keys = strings.Split(conditions, ",")
-
-	for _, key := range keys {
+	var conditions = func(list string) string {
 
-		if strings.Contains(streamValues, key) {
+		list = strings.Replace(list, ", ", ",", -1)
+		list = strings.Replace(list, " ,", ",", -1)
 
-			switch coType {
-
-			case "exclude":
-				return false
+		var keys = strings.Split(list, ",")
+		for i, key := range keys {
+			keys[i] = field + ":" + filterexpr.Quote(key)
+		}
 
-			case "include":
-				return true
+		return "(" + strings.Join(keys, " OR ") + ")"
+	}
 
-			}
+	expression = rule
 
-		}
+	if len(exclude) > 0 {
+		expression += " AND NOT " + conditions(exclude)
+	}
 
+	if len(include) > 0 {
+		expression += " AND " + conditions(include)
 	}
 
 	return
@@ -329,9 +333,55 @@ func probeChannel(request RequestStruct) (string, string, string, error) {
 		}
 	}
 
+	saveProbeInfo(request.ProbeURL, ProbeInfoStruct{Resolution: resolution, FrameRate: frameRate, AudioChannel: audioChannels})
+
 	return resolution, frameRate, audioChannels, nil
 }
 
+// ffprobe Ergebnis speichern (probe.json), wird vom Filter Feld resolution verwendet
+func saveProbeInfo(url string, info ProbeInfoStruct) {
+
+	probeMutex.Lock()
+	defer probeMutex.Unlock()
+
+	loadProbeCache()
+
+	info.Time = time.Now().Format("2006-01-02 15:04:05")
+	Data.Cache.Probe[url] = info
+
+	err := saveMapToJSONFile(System.File.Probe, Data.Cache.Probe)
+	if err != nil {
+		ShowError(err, 0)
+	}
+
+}
+
+// ffprobe Ergebnis aus dem Cache
+func getProbeInfo(url string) ProbeInfoStruct {
+
+	probeMutex.Lock()
+	defer probeMutex.Unlock()
+
+	loadProbeCache()
+
+	return Data.Cache.Probe[url]
+}
+
+func loadProbeCache() {
+
+	if Data.Cache.Probe != nil {
+		return
+	}
+
+	Data.Cache.Probe = make(map[string]ProbeInfoStruct)
+
+	tmpMap, err := loadJSONFileToMap(System.File.Probe)
+	if err == nil {
+		json.Unmarshal([]byte(mapToJSON(tmpMap)), &Data.Cache.Probe)
+	}
+
+}
+
 func parseFrameRate(parts []string) int {
 	numerator, denom := 1, 1
 	fmt.Sscanf(parts[0], "%d", &numerator)
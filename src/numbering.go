@@ -0,0 +1,476 @@
+package src
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Nummerierung eines Filters (numbering):
+//
+//	sequential  die Kanäle behalten ihre Reihenfolge, Lücken werden geschlossen
+//	name        die Kanäle werden nach Namen sortiert
+//	tvg-chno    die Nummer des Providers (tvg-chno) wird verwendet, sofern sie frei ist
+//
+// numbering.block reserviert die Nummern ab der Startnummer für die Kanäle des Filters,
+// numbering.auto nummeriert die Kanäle des Filters bei jeder XEPG Aktualisierung neu.
+var numberingPolicies = map[string]bool{"sequential": true, "name": true, "tvg-chno": true}
+
+var tvgChnoPattern = regexp.MustCompile(`tvg-chno="([^"]*)"`)
+
+type numberedChannel struct {
+	id      string
+	channel XEPGChannelStruct
+	number  float64
+	valid   bool
+	filter  int
+}
+
+// renumberChannels : Neue Kanalnummern für einen Filter oder alle Filter mit Nummerierung planen (und übernehmen)
+func renumberChannels(filterID, policy string, apply bool) (plan *RenumberPlan, err error) {
+
+	if len(policy) > 0 && !numberingPolicies[policy] {
+		err = errors.New(getErrMsg(1026))
+		return
+	}
+
+	var scopes = make(map[int64]string)
+
+	if len(filterID) == 0 {
+
+		for _, filter := range Data.Filter {
+			if len(filter.Numbering) > 0 {
+				scopes[filter.ID] = firstNonEmpty(policy, filter.Numbering)
+			}
+		}
+
+	} else {
+
+		id, errID := strconv.ParseInt(filterID, 10, 64)
+
+		for _, filter := range Data.Filter {
+			if errID == nil && filter.ID == id {
+				scopes[filter.ID] = firstNonEmpty(policy, filter.Numbering, "sequential")
+			}
+		}
+
+		if len(scopes) == 0 {
+			err = errors.New(getErrMsg(1027))
+			return
+		}
+
+	}
+
+	xepgMutex.Lock()
+
+	var result = planRenumber(Data.XEPG.Channels, Data.Filter, scopes)
+
+	if apply && len(result.Changes) > 0 {
+
+		applyRenumberPlan(result)
+
+		err = saveMapToJSONFile(System.File.XEPG, Data.XEPG.Channels)
+		result.Applied = err == nil
+
+	}
+
+	xepgMutex.Unlock()
+
+	if result.Applied {
+		showInfo(fmt.Sprintf("XEPG:Renumbered %d channels", len(result.Changes)))
+		buildXEPG(false)
+	}
+
+	plan = &result
+
+	return
+}
+
+// autoRenumber : Filter mit numbering.auto, der Aufrufer hält xepgMutex
+func autoRenumber() {
+
+	var scopes = make(map[int64]string)
+	for _, filter := range Data.Filter {
+		if filter.NumberAuto && len(filter.Numbering) > 0 {
+			scopes[filter.ID] = filter.Numbering
+		}
+	}
+
+	if len(scopes) == 0 {
+		return
+	}
+
+	var plan = planRenumber(Data.XEPG.Channels, Data.Filter, scopes)
+	applyRenumberPlan(plan)
+
+	if len(plan.Changes) > 0 {
+		showInfo(fmt.Sprintf("XEPG:Renumbered %d channels", len(plan.Changes)))
+	}
+
+	for _, conflict := range plan.Conflicts {
+		showInfo(fmt.Sprintf("XEPG:Channel number %s (%s): %s", conflict.Number, strings.Join(conflict.Channels, ", "), conflict.Reason))
+	}
+
+}
+
+func applyRenumberPlan(plan RenumberPlan) {
+
+	for _, change := range plan.Changes {
+
+		var channel XEPGChannelStruct
+		if err := json.Unmarshal([]byte(mapToJSON(Data.XEPG.Channels[change.XEPG])), &channel); err != nil {
+			continue
+		}
+
+		channel.XChannelID = change.New
+		channel.TvgChno = change.New
+
+		Data.XEPG.Channels[change.XEPG] = channel
+
+	}
+
+}
+
+// planRenumber : Neue Nummern für die Kanäle der Filter in scopes (Filter ID -> Nummerierung).
+// Die Kanäle gehören zum ersten passenden Filter, die Nummern aller anderen Kanäle werden nicht geändert.
+func planRenumber(xepgChannels map[string]interface{}, filters []Filter, scopes map[int64]string) (plan RenumberPlan) {
+
+	plan.Changes = []RenumberChange{}
+	plan.Conflicts = []RenumberConflict{}
+
+	var list = make([]*numberedChannel, 0, len(xepgChannels))
+
+	for id, dxc := range xepgChannels {
+
+		var channel XEPGChannelStruct
+		if err := json.Unmarshal([]byte(mapToJSON(dxc)), &channel); err != nil {
+			continue
+		}
+
+		var n = &numberedChannel{id: id, channel: channel, filter: -1}
+
+		number, err := strconv.ParseFloat(strings.TrimSpace(channel.XChannelID), 64)
+		n.number, n.valid = number, err == nil
+
+		if channel.XActive {
+			n.filter = numberingFilter(channel, filters)
+		}
+
+		list = append(list, n)
+
+	}
+
+	sortByNumber(list)
+
+	var used = make(map[float64]bool)
+	var members = make(map[int][]*numberedChannel)
+
+	for _, n := range list {
+
+		if n.filter >= 0 {
+			if _, ok := scopes[filters[n.filter].ID]; ok {
+				members[n.filter] = append(members[n.filter], n)
+				continue
+			}
+		}
+
+		if n.valid {
+			used[n.number] = true
+		}
+
+	}
+
+	for i, filter := range filters {
+
+		var group = members[i]
+		if len(group) == 0 {
+			continue
+		}
+
+		var policy = scopes[filter.ID]
+		sortForPolicy(group, policy)
+
+		var start = filter.StartNumber
+		if start <= 0 {
+			start = lowestNumber(group)
+		}
+
+		var end = start + float64(filter.NumberBlock)
+		var inBlock = func(number float64) bool {
+			return filter.NumberBlock <= 0 || number >= start && number < end
+		}
+
+		var next = start
+
+		for _, n := range group {
+
+			var number float64 = -1
+
+			if policy == "tvg-chno" {
+				if chno, ok := providerChannelNumber(n.channel); ok && !used[chno] && inBlock(chno) {
+					number = chno
+				}
+			}
+
+			if number < 0 {
+
+				for used[next] {
+					next++
+				}
+
+				number = next
+
+			}
+
+			if !inBlock(number) {
+
+				plan.Conflicts = append(plan.Conflicts, RenumberConflict{
+					Number:   n.channel.XChannelID,
+					Reason:   fmt.Sprintf("the block of filter %s is full (%d numbers)", filter.Name, filter.NumberBlock),
+					Channels: []string{n.channel.XName},
+				})
+
+				if n.valid {
+					used[n.number] = true
+				}
+
+				continue
+			}
+
+			used[number] = true
+
+			if !n.valid || number != n.number {
+
+				plan.Changes = append(plan.Changes, RenumberChange{
+					XEPG:   n.id,
+					Name:   n.channel.XName,
+					Group:  n.channel.XGroupTitle,
+					Filter: filter.Name,
+					Old:    n.channel.XChannelID,
+					New:    formatChannelNumber(number),
+				})
+
+				n.number, n.valid = number, true
+
+			}
+
+		}
+
+	}
+
+	plan.Conflicts = append(plan.Conflicts, findNumberConflicts(list, filters)...)
+
+	sort.SliceStable(plan.Changes, func(i, j int) bool {
+		a, _ := strconv.ParseFloat(plan.Changes[i].New, 64)
+		b, _ := strconv.ParseFloat(plan.Changes[j].New, 64)
+		return a < b
+	})
+
+	return
+}
+
+// findNumberConflicts : Nummern mehrerer aktiver Kanäle, überlappende Blöcke und Kanäle im Block eines anderen Filters
+func findNumberConflicts(list []*numberedChannel, filters []Filter) (conflicts []RenumberConflict) {
+
+	var byNumber = make(map[float64][]string)
+	var numbers []float64
+
+	for _, n := range list {
+
+		if !n.channel.XActive || !n.valid {
+			continue
+		}
+
+		if _, ok := byNumber[n.number]; !ok {
+			numbers = append(numbers, n.number)
+		}
+
+		byNumber[n.number] = append(byNumber[n.number], n.channel.XName)
+
+	}
+
+	sort.Float64s(numbers)
+
+	for _, number := range numbers {
+		if names := byNumber[number]; len(names) > 1 {
+			conflicts = append(conflicts, RenumberConflict{Number: formatChannelNumber(number), Reason: "the number is used by several channels", Channels: names})
+		}
+	}
+
+	for i, a := range filters {
+
+		if a.NumberBlock <= 0 || a.StartNumber <= 0 {
+			continue
+		}
+
+		var aEnd = a.StartNumber + float64(a.NumberBlock)
+
+		for _, b := range filters[i+1:] {
+
+			if b.NumberBlock <= 0 || b.StartNumber <= 0 {
+				continue
+			}
+
+			if a.StartNumber < b.StartNumber+float64(b.NumberBlock) && b.StartNumber < aEnd {
+				conflicts = append(conflicts, RenumberConflict{
+					Number:   formatChannelNumber(maxFloat(a.StartNumber, b.StartNumber)),
+					Reason:   fmt.Sprintf("the blocks of the filters %s and %s overlap", a.Name, b.Name),
+					Channels: []string{},
+				})
+			}
+
+		}
+
+		for _, n := range list {
+
+			if !n.channel.XActive || !n.valid || (n.filter >= 0 && filters[n.filter].ID == a.ID) {
+				continue
+			}
+
+			if n.number >= a.StartNumber && n.number < aEnd {
+				conflicts = append(conflicts, RenumberConflict{
+					Number:   formatChannelNumber(n.number),
+					Reason:   fmt.Sprintf("the number is inside the block of filter %s", a.Name),
+					Channels: []string{n.channel.XName},
+				})
+			}
+
+		}
+
+	}
+
+	return
+}
+
+// numberingFilter : Index des ersten Filters, der den Kanal einschließt, -1 wenn kein Filter oder ein Ausschlussfilter passt
+func numberingFilter(channel XEPGChannelStruct, filters []Filter) int {
+
+	var record = filterRecord{
+		"name":           channel.Name,
+		"group-title":    channel.GroupTitle,
+		"tvg-id":         channel.TvgID,
+		"tvg-name":       channel.TvgName,
+		"tvg-logo":       channel.TvgLogo,
+		"url":            channel.URL,
+		"_file.m3u.name": channel.FileM3UName,
+		"_values":        channel.Values,
+	}
+
+	for i, filter := range filters {
+
+		if filter.Expression == nil || !filter.Expression.Match(record) {
+			continue
+		}
+
+		if filter.Action == "exclude" {
+			return -1
+		}
+
+		return i
+	}
+
+	return -1
+}
+
+func sortByNumber(list []*numberedChannel) {
+
+	sort.SliceStable(list, func(i, j int) bool {
+
+		if list[i].valid != list[j].valid {
+			return list[i].valid
+		}
+
+		if list[i].number != list[j].number {
+			return list[i].number < list[j].number
+		}
+
+		return list[i].id < list[j].id
+	})
+
+}
+
+func sortForPolicy(group []*numberedChannel, policy string) {
+
+	switch policy {
+
+	case "name":
+		sort.SliceStable(group, func(i, j int) bool {
+			return strings.ToLower(group[i].channel.XName) < strings.ToLower(group[j].channel.XName)
+		})
+
+	case "tvg-chno":
+		sort.SliceStable(group, func(i, j int) bool {
+
+			a, okA := providerChannelNumber(group[i].channel)
+			b, okB := providerChannelNumber(group[j].channel)
+
+			if okA != okB {
+				return okA
+			}
+
+			return okA && a < b
+		})
+
+	}
+
+}
+
+// providerChannelNumber : tvg-chno der M3U Zeile
+func providerChannelNumber(channel XEPGChannelStruct) (float64, bool) {
+
+	var match = tvgChnoPattern.FindStringSubmatch(channel.Values)
+	if len(match) < 2 {
+		return 0, false
+	}
+
+	number, err := strconv.ParseFloat(strings.TrimSpace(match[1]), 64)
+	if err != nil || number <= 0 {
+		return 0, false
+	}
+
+	return number, true
+}
+
+func lowestNumber(group []*numberedChannel) float64 {
+
+	var lowest float64 = -1
+	for _, n := range group {
+		if n.valid && (lowest < 0 || n.number < lowest) {
+			lowest = n.number
+		}
+	}
+
+	if lowest <= 0 {
+		return Settings.MappingFirstChannel
+	}
+
+	return lowest
+}
+
+func formatChannelNumber(number float64) string {
+	return fmt.Sprintf("%g", number)
+}
+
+func maxFloat(a, b float64) float64 {
+
+	if a > b {
+		return a
+	}
+
+	return b
+}
+
+func firstNonEmpty(values ...string) string {
+
+	for _, value := range values {
+		if len(value) > 0 {
+			return value
+		}
+	}
+
+	return ""
+}
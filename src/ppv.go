@@ -0,0 +1,169 @@
+package src
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Automatische Aktivierung der Event Kanäle (x-mapping PPV, Einstellung ppv.auto). Der Job ppv.activation des Zeitplans
+// aktiviert einen Kanal ppv.lead Minuten vor der Eventzeit seines Namens (siehe event.rules) und deaktiviert ihn
+// ppv.lag Minuten nach dem Ende des Events (nach dem Beginn, wenn keine Dauer eingestellt ist).
+// Kanäle ohne erkannte Zeit bleiben inaktiv, vom Provider entfernte Kanäle löscht cleanupXEPG.
+
+// ppvWindow : Aktivierungsfenster des Events im Namen, die Eventzeit wird relativ zu ref gelesen
+func ppvWindow(rule EventTimeRule, name string, ref time.Time) (from, to time.Time, ok bool) {
+
+	start, stop, ok := eventTime(rule, name, ref)
+	if !ok {
+		return
+	}
+
+	var end = start
+	if rule.Duration > 0 || Settings.EventDuration > 0 {
+		end = stop
+	}
+
+	from = start.Add(-time.Duration(Settings.PPVLead) * time.Minute)
+	to = end.Add(time.Duration(Settings.PPVLag) * time.Minute)
+
+	return
+}
+
+// ppvChannelStatus : Fenster und Aktivierung eines Event Kanals zum Zeitpunkt now.
+// Zeiten ohne Datum werden am Tag von now, von now + lead und von now - lag versucht, ein Event
+// um 00:30 wird vor Mitternacht aktiviert und ein Event um 23:00 bleibt nach Mitternacht aktiv.
+func ppvChannelStatus(xepgChannel XEPGChannelStruct, now time.Time) (status PPVChannel) {
+
+	status = PPVChannel{XEPG: xepgChannel.XEPG, Name: xepgChannel.XName}
+	if len(status.Name) == 0 {
+		status.Name = xepgChannel.TvgName
+	}
+
+	var rule = eventTimeRuleFor(Settings.EventRules, xepgChannel)
+	var references = []time.Time{
+		now,
+		now.Add(time.Duration(Settings.PPVLead) * time.Minute),
+		now.Add(-time.Duration(Settings.PPVLag) * time.Minute),
+	}
+
+	for _, ref := range references {
+
+		from, to, ok := ppvWindow(rule, status.Name, ref)
+		if !ok {
+			continue
+		}
+
+		var active = !now.Before(from) && now.Before(to)
+
+		if !status.Found || active {
+			status.Found = true
+			status.From = from.Format(time.RFC3339)
+			status.To = to.Format(time.RFC3339)
+			status.Active = active
+		}
+
+		if active {
+			break
+		}
+
+	}
+
+	return
+}
+
+// getPPVChannels : Aktivierungsfenster aller Event Kanäle, nach Namen sortiert
+func getPPVChannels(now time.Time) (channels []PPVChannel) {
+
+	channels = make([]PPVChannel, 0)
+
+	xepgMutex.Lock()
+	defer xepgMutex.Unlock()
+
+	for _, dxc := range Data.XEPG.Channels {
+
+		var xepgChannel XEPGChannelStruct
+		if err := json.Unmarshal([]byte(mapToJSON(dxc)), &xepgChannel); err != nil || xepgChannel.XMapping != "PPV" {
+			continue
+		}
+
+		var status = ppvChannelStatus(xepgChannel, now)
+
+		// Ohne automatische Aktivierung wird der aktuelle Status des Kanals angezeigt
+		if !Settings.PPVAuto {
+			status.Active = xepgChannel.XActive
+		}
+
+		channels = append(channels, status)
+
+	}
+
+	sort.Slice(channels, func(i, j int) bool { return channels[i].Name < channels[j].Name })
+
+	return
+}
+
+// queuePPVActivation : Aktivierung in der Warteschlange der Datenbank, nie gleichzeitig mit einem XEPG Build
+func queuePPVActivation() *jobEntry {
+
+	return startJob("ppv", "ppv.activation", "database", "Activate event channels", func(job *jobEntry) error {
+		return updatePPVActivation(time.Now())
+	})
+
+}
+
+// updatePPVActivation : Aktiviert und deaktiviert die Event Kanäle, die XEPG Dateien werden nur nach einer Änderung neu erstellt.
+// Läuft als Job in der Warteschlange der Datenbank (queuePPVActivation).
+func updatePPVActivation(now time.Time) (err error) {
+
+	var activated, deactivated int
+
+	xepgMutex.Lock()
+
+	for id, dxc := range Data.XEPG.Channels {
+
+		var xepgChannel XEPGChannelStruct
+		if err := json.Unmarshal([]byte(mapToJSON(dxc)), &xepgChannel); err != nil || xepgChannel.XMapping != "PPV" {
+			continue
+		}
+
+		var active = ppvChannelStatus(xepgChannel, now).Active
+		if active == xepgChannel.XActive {
+			continue
+		}
+
+		// Umbenannte Kanäle (rematch.go) sind als XEPGChannelStruct gespeichert, geändert wird nur x-active in der Map
+		channel, ok := dxc.(map[string]interface{})
+		if !ok {
+			channel = jsonToMap(mapToJSON(dxc))
+		}
+
+		channel["x-active"] = active
+		Data.XEPG.Channels[id] = channel
+
+		if active {
+			activated++
+		} else {
+			deactivated++
+		}
+
+	}
+
+	if activated+deactivated > 0 {
+		err = saveMapToJSONFile(System.File.XEPG, Data.XEPG.Channels)
+	}
+
+	xepgMutex.Unlock()
+
+	if err != nil || activated+deactivated == 0 {
+		return
+	}
+
+	showInfo(fmt.Sprintf("PPV:%d event channels activated, %d deactivated", activated, deactivated))
+
+	// Der Build wartet hinter diesem Job, ein Warten darauf würde die Warteschlange blockieren
+	buildXEPG(true)
+
+	return
+}
@@ -0,0 +1,348 @@
+package src
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Manuelle Sendungen eines XEPG Kanals (programs.json), z.B. für lokale Event Kanäle.
+// Sie werden in mergeProgramData über die Sendungen der EPG Quellen oder des Dummys gelegt,
+// Sendungen der Quellen, die sich mit einer manuellen Sendung überschneiden, werden entfernt.
+//
+// Wiederholung: daily, weekdays, weekends, weekly (Wochentag des Beginns) oder weekly:mon,wed,fri.
+// Die Zeiten sind RFC 3339, mit einer Zeitzone (Europe/Berlin) werden sie als lokale Zeiten der Zeitzone gelesen.
+// Wiederholte Sendungen werden ab dem Beginn bis "until" (oder ohne Ende) erstellt,
+// aber nur für das EPG Zeitfenster (manualProgramDays, wenn kein Zeitfenster in die Zukunft eingestellt ist).
+
+const manualProgramDays = 14
+
+var (
+	manualProgramsMutex sync.Mutex
+	manualPrograms      map[string][]ManualProgram
+)
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// loadManualPrograms : Manuelle Sendungen aller Kanäle, werden einmal aus programs.json gelesen
+func loadManualPrograms() (programs map[string][]ManualProgram, err error) {
+
+	manualProgramsMutex.Lock()
+	defer manualProgramsMutex.Unlock()
+
+	if manualPrograms == nil {
+
+		tmpMap, err := loadJSONFileToMap(System.File.Programs)
+		if err != nil {
+			return nil, err
+		}
+
+		manualPrograms = make(map[string][]ManualProgram)
+		if err = json.Unmarshal([]byte(mapToJSON(tmpMap)), &manualPrograms); err != nil {
+			manualPrograms = nil
+			return nil, err
+		}
+
+	}
+
+	return manualPrograms, nil
+}
+
+// getManualPrograms : Manuelle Sendungen eines Kanals, alle Kanäle wenn xepgID leer ist
+func getManualPrograms(xepgID string) (programs map[string][]ManualProgram, err error) {
+
+	all, err := loadManualPrograms()
+	if err != nil {
+		return
+	}
+
+	manualProgramsMutex.Lock()
+	defer manualProgramsMutex.Unlock()
+
+	programs = make(map[string][]ManualProgram)
+
+	for id, list := range all {
+		if len(xepgID) == 0 || id == xepgID {
+			programs[id] = append([]ManualProgram{}, list...)
+		}
+	}
+
+	return
+}
+
+// channelManualPrograms : Manuelle Sendungen eines Kanals (leer, wenn programs.json nicht gelesen werden kann)
+func channelManualPrograms(xepgID string) []ManualProgram {
+
+	programs, err := getManualPrograms(xepgID)
+	if err != nil {
+		ShowError(err, 0)
+		return nil
+	}
+
+	return programs[xepgID]
+}
+
+// saveManualPrograms : Manuelle Sendungen des Kanals ersetzen und die XMLTV Datei neu schreiben
+func saveManualPrograms(xepgID string, programs []ManualProgram) (saved map[string][]ManualProgram, err error) {
+
+	xepgMutex.Lock()
+	_, ok := Data.XEPG.Channels[xepgID]
+	xepgMutex.Unlock()
+
+	if !ok {
+		err = errors.New(getErrMsg(1032))
+		return
+	}
+
+	for i := range programs {
+
+		if err = programs[i].validate(); err != nil {
+			err = fmt.Errorf("%s (%s)", getErrMsg(1039), err)
+			return
+		}
+
+		if len(programs[i].ID) == 0 {
+			programs[i].ID = randomString(12)
+		}
+
+	}
+
+	all, err := loadManualPrograms()
+	if err != nil {
+		return
+	}
+
+	manualProgramsMutex.Lock()
+
+	if len(programs) == 0 {
+		delete(all, xepgID)
+	} else {
+		all[xepgID] = programs
+	}
+
+	err = saveMapToJSONFile(System.File.Programs, all)
+	manualProgramsMutex.Unlock()
+
+	if err != nil {
+		return
+	}
+
+	queueXMLTVFile()
+
+	return getManualPrograms(xepgID)
+}
+
+// validate : Titel, Zeiten und Wiederholung der Sendung prüfen
+func (p ManualProgram) validate() (err error) {
+
+	if len(strings.TrimSpace(p.Title)) == 0 {
+		return errors.New("title is empty")
+	}
+
+	start, stop, err := p.times()
+	if err != nil {
+		return
+	}
+
+	if !stop.After(start) {
+		return errors.New("stop is not after start")
+	}
+
+	if len(p.Until) > 0 {
+		if _, err = time.Parse(time.RFC3339, p.Until); err != nil {
+			return fmt.Errorf("until: %s", err)
+		}
+	}
+
+	_, err = parseRecurrence(p.Recurrence, start.Weekday())
+
+	return
+}
+
+// times : Beginn und Ende, mit einer Zeitzone sind die Zeiten lokale Zeiten der Zeitzone (Sommerzeit)
+func (p ManualProgram) times() (start, stop time.Time, err error) {
+
+	if start, err = time.Parse(time.RFC3339, p.Start); err != nil {
+		err = fmt.Errorf("start: %s", err)
+		return
+	}
+
+	if stop, err = time.Parse(time.RFC3339, p.Stop); err != nil {
+		err = fmt.Errorf("stop: %s", err)
+		return
+	}
+
+	if len(p.Timezone) > 0 {
+
+		location, errTZ := parseEPGTimezone(p.Timezone)
+		if errTZ != nil {
+			err = fmt.Errorf("timezone: %s", errTZ)
+			return
+		}
+
+		var local = func(t time.Time) time.Time {
+			return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, location)
+		}
+
+		start, stop = local(start), local(stop)
+
+	}
+
+	return
+}
+
+// parseRecurrence : Wochentage der Wiederholung, nil für eine einzelne Sendung
+func parseRecurrence(recurrence string, startDay time.Weekday) (days map[time.Weekday]bool, err error) {
+
+	recurrence = strings.ToLower(strings.TrimSpace(recurrence))
+
+	var set = func(list ...time.Weekday) map[time.Weekday]bool {
+		var days = make(map[time.Weekday]bool)
+		for _, day := range list {
+			days[day] = true
+		}
+		return days
+	}
+
+	switch recurrence {
+
+	case "":
+		return nil, nil
+
+	case "daily":
+		return set(time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday), nil
+
+	case "weekdays":
+		return set(time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday), nil
+
+	case "weekends":
+		return set(time.Saturday, time.Sunday), nil
+
+	case "weekly":
+		return set(startDay), nil
+
+	}
+
+	if list, ok := strings.CutPrefix(recurrence, "weekly:"); ok {
+
+		days = make(map[time.Weekday]bool)
+
+		for _, name := range strings.Split(list, ",") {
+
+			day, ok := weekdayNames[strings.TrimSpace(name)]
+			if !ok {
+				return nil, fmt.Errorf("unknown weekday %q", name)
+			}
+
+			days[day] = true
+
+		}
+
+		return days, nil
+	}
+
+	return nil, fmt.Errorf("unknown recurrence %q", recurrence)
+}
+
+// occurrences : Beginn und Ende jeder Wiederholung zwischen from und to
+func (p ManualProgram) occurrences(from, to time.Time) (list [][2]time.Time) {
+
+	start, stop, err := p.times()
+	if err != nil {
+		return
+	}
+
+	days, err := parseRecurrence(p.Recurrence, start.Weekday())
+	if err != nil {
+		return
+	}
+
+	if days == nil {
+
+		if stop.After(from) && start.Before(to) {
+			list = append(list, [2]time.Time{start, stop})
+		}
+
+		return
+	}
+
+	var until = to
+	if t, err := time.Parse(time.RFC3339, p.Until); err == nil && t.Before(until) {
+		until = t
+	}
+
+	var duration = stop.Sub(start)
+
+	// Mit einer Zeitzone behält AddDate die lokale Zeit des Beginns bei der Zeitumstellung bei
+	for day := 0; ; day++ {
+
+		var s = start.AddDate(0, 0, day)
+		if s.After(until) {
+			break
+		}
+
+		if !days[s.Weekday()] || !s.Add(duration).After(from) {
+			continue
+		}
+
+		list = append(list, [2]time.Time{s, s.Add(duration)})
+
+	}
+
+	return
+}
+
+// manualProgramData : Sendungen der manuellen Einträge des Kanals im EPG Zeitfenster
+func manualProgramData(xepgChannel XEPGChannelStruct, now time.Time) (programs []*Program) {
+
+	var entries = channelManualPrograms(xepgChannel.XEPG)
+	if len(entries) == 0 {
+		return
+	}
+
+	var from, to = epgWindow(now, Settings.EPGWindowPast, Settings.EPGWindowFuture)
+
+	if from.IsZero() {
+		from = now.AddDate(0, 0, -1)
+	}
+
+	if to.IsZero() {
+		to = now.AddDate(0, 0, manualProgramDays)
+	}
+
+	for _, entry := range entries {
+
+		for _, o := range entry.occurrences(from, to) {
+
+			var program = &Program{
+				Channel: xepgChannel.XChannelID,
+				Start:   o[0].Format("20060102150405 -0700"),
+				Stop:    o[1].Format("20060102150405 -0700"),
+				Title:   []*Title{{Value: entry.Title, Lang: "en"}},
+			}
+
+			if len(entry.Description) > 0 {
+				program.Desc = []*Desc{{Value: entry.Description, Lang: "en"}}
+			}
+
+			if len(entry.Category) > 0 {
+				program.Category = []*Category{{Value: entry.Category, Lang: "en"}}
+			}
+
+			programs = append(programs, program)
+
+		}
+
+	}
+
+	sort.SliceStable(programs, func(i, j int) bool { return programs[i].Start < programs[j].Start })
+
+	return
+}
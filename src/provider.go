@@ -14,8 +14,24 @@ import (
 )
 
 // fileType: Welcher Dateityp soll aktualisiert werden (m3u, hdhr, xml) | fileID: Update einer bestimmten Datei (Provider ID)
+// Das Update läuft als Job, gleichzeitige Anfragen für die selben Dateien werden zusammengefasst.
 func getProviderData(fileType, fileID string) (err error) {
 
+	var key, description = "provider." + fileType, "Update " + fileType
+	if len(fileID) > 0 {
+		key += "." + fileID
+		description += ": " + getProviderParameter(fileID, fileType, "name")
+	}
+
+	var job = startJob("provider", key, "database", description, func(job *jobEntry) error {
+		return updateProviderData(job, fileType, fileID)
+	})
+
+	return job.wait()
+}
+
+func updateProviderData(job *jobEntry, fileType, fileID string) (err error) {
+
 	showInfo("Provider:" + "getProviderData called with fileType=" + fileType + " fileID=" + fileID)
 	
 	// Check for fast startup environment variable
@@ -156,17 +172,39 @@ func getProviderData(fileType, fileID string) (err error) {
 
 		var filePath = System.Folder.Data + data["file."+System.AppName].(string)
 
+		// Lokale Kopie vor dem Überschreiben für den Änderungsbericht lesen
+		var previous []interface{}
+		if fileType != "xmltv" {
+			if checkFile(filePath) == nil {
+				previous, _ = parsePlaylist(filePath, fileType)
+			}
+		}
+
 		err = writeByteToFile(filePath, body)
 
 		if err == nil {
 			data["last.update"] = time.Now().Format("2006-01-02 15:04:05")
 			data["counter.download"] = data["counter.download"].(float64) + 1
+
+			if previous != nil {
+				if current, err := parsePlaylist(filePath, fileType); err == nil {
+					recordPlaylistChanges(id, fileType, previous, current)
+				}
+			}
 		}
 
 		return
 
 	}
 
+	// Adressen der importierten HDHomeRun Tuner aktualisieren, nur bei der Aktualisierung aller Tuner.
+	// Die Aktualisierungen einzelner Dateien verwenden den Job hdhr.addresses des Zeitplans.
+	if fileType == "hdhr" && len(fileID) == 0 {
+		if errAddress := updateHDHRAddresses(); errAddress != nil {
+			ShowError(errAddress, 0)
+		}
+	}
+
 	switch fileType {
 
 	case "m3u":
@@ -183,8 +221,17 @@ func getProviderData(fileType, fileID string) (err error) {
 
 	}
 
+	var count int
+
 	for dataID, d := range dataMap {
 
+		if job.canceled() {
+			return
+		}
+
+		job.setProgress(count * 100 / len(dataMap))
+		count++
+
 		var data = d.(map[string]interface{})
 		var fileSource = data["file.source"].(string)
 		var httpProxyIp = ""
@@ -214,6 +261,8 @@ func getProviderData(fileType, fileID string) (err error) {
 			}
 		}
 
+		job.addLog("Update: %s", fileSource)
+
 		switch fileType {
 
 		case "hdhr":
@@ -344,7 +393,9 @@ func getProviderData(fileType, fileID string) (err error) {
 
 		case "xmltv":
 			Settings.Files.XMLTV = dataMap
+			systemMutex.Lock()
 			delete(Data.Cache.XMLTV, System.Folder.Data+dataID+fileExtension)
+			systemMutex.Unlock()
 
 		}
 
@@ -0,0 +1,425 @@
+package src
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"threadfin/src/internal/fuzzy"
+)
+
+// Mindestwert für eine automatische Zuordnung, unter rematchReviewScore wird der Kanal als neu behandelt
+const (
+	rematchAutoScore   = 0.9
+	rematchReviewScore = 0.6
+)
+
+// rematcher : Findet den bisherigen XEPG Kanal eines umbenannten Streams
+type rematcher struct {
+	orphans map[string][]XEPGChannelStruct
+	used    map[string]bool
+	matches map[string]ChannelMatch
+	pending map[string]string
+}
+
+// Hash, mit dem der XEPG Kanal eines Streams gefunden wird (tvg-name + Playlist, Live Events: URL + Playlist)
+func getM3UChannelHash(m3uChannel M3UChannelStructXEPG) string {
+
+	if m3uChannel.LiveEvent == "true" {
+		hash := md5.Sum([]byte(m3uChannel.URL + m3uChannel.FileM3UID))
+		return hex.EncodeToString(hash[:])
+	}
+
+	return m3uChannel.TvgName + m3uChannel.FileM3UID
+}
+
+// XEPG Kanäle, deren Stream in keiner Playlist mehr vorhanden ist, kommen für einen umbenannten Kanal in Frage.
+// Nur herausgefilterte Streams kommen nicht in Frage. Ohne Zuordnung werden die Kanäle von cleanupXEPG entfernt.
+func newRematcher(xepgChannels map[string]XEPGChannelStruct, streams []interface{}) (r *rematcher) {
+
+	r = &rematcher{
+		orphans: make(map[string][]XEPGChannelStruct),
+		used:    make(map[string]bool),
+		pending: make(map[string]string),
+	}
+
+	var activeHashes = make(map[string]bool)
+	var activeUUIDs = make(map[string]bool)
+
+	for _, dsa := range streams {
+
+		var m3uChannel M3UChannelStructXEPG
+		if err := json.Unmarshal([]byte(mapToJSON(dsa)), &m3uChannel); err != nil {
+			continue
+		}
+
+		if m3uChannel.TvgName == "" {
+			m3uChannel.TvgName = m3uChannel.Name
+		}
+
+		activeHashes[getM3UChannelHash(m3uChannel)] = true
+
+		if len(m3uChannel.UUIDValue) > 0 {
+			activeUUIDs[m3uChannel.FileM3UID+m3uChannel.UUIDValue] = true
+		}
+
+	}
+
+	for hash, channel := range xepgChannels {
+
+		if activeHashes[hash] || (len(channel.UUIDValue) > 0 && activeUUIDs[channel.FileM3UID+channel.UUIDValue]) {
+			continue
+		}
+
+		r.orphans[channel.FileM3UID] = append(r.orphans[channel.FileM3UID], channel)
+
+	}
+
+	// Feste Reihenfolge, mit der Reihenfolge der Map wären Gleichstände zufällig
+	for id := range r.orphans {
+		sort.Slice(r.orphans[id], func(i, j int) bool { return r.orphans[id][i].XEPG < r.orphans[id][j].XEPG })
+	}
+
+	var err error
+	r.matches, err = loadChannelMatches()
+	if err != nil {
+		ShowError(err, 000)
+		r.matches = make(map[string]ChannelMatch)
+	}
+
+	return
+}
+
+// find : Bisheriger XEPG Kanal des Streams. Reihenfolge: tvg-id, normalisierter Name, Stream ID der URL, Ähnlichkeit des Namens.
+// Unsichere Zuordnungen warten auf eine manuelle Bestätigung (matches.json).
+func (r *rematcher) find(m3uChannel M3UChannelStructXEPG, hash string) (channel XEPGChannelStruct, method string, score float64, ok bool) {
+
+	var candidates = make([]XEPGChannelStruct, 0)
+	for _, orphan := range r.orphans[m3uChannel.FileM3UID] {
+		if !r.used[orphan.XEPG] {
+			candidates = append(candidates, orphan)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return
+	}
+
+	var unique = func(match func(c XEPGChannelStruct) bool) (found XEPGChannelStruct, ok bool) {
+
+		var count int
+		for _, candidate := range candidates {
+			if match(candidate) {
+				found = candidate
+				count++
+			}
+		}
+
+		return found, count == 1
+	}
+
+	if len(m3uChannel.TvgID) > 0 {
+		if channel, ok = unique(func(c XEPGChannelStruct) bool { return c.TvgID == m3uChannel.TvgID }); ok {
+			return r.use(channel), "tvg-id", 1, true
+		}
+	}
+
+	var name = fuzzy.Normalize(m3uChannel.Name)
+	if len(name) > 0 {
+		if channel, ok = unique(func(c XEPGChannelStruct) bool { return fuzzy.Normalize(c.Name) == name }); ok {
+			return r.use(channel), "name", 1, true
+		}
+	}
+
+	var streamID = fuzzy.StreamID(m3uChannel.URL)
+	if len(streamID) > 0 {
+		if channel, ok = unique(func(c XEPGChannelStruct) bool { return fuzzy.StreamID(c.URL) == streamID }); ok {
+			return r.use(channel), "stream-id", 1, true
+		}
+	}
+
+	var best XEPGChannelStruct
+	var bestScore, secondScore float64
+
+	for _, candidate := range candidates {
+
+		var s = fuzzy.Similarity(candidate.Name, m3uChannel.Name)
+		if s > bestScore {
+			best, secondScore, bestScore = candidate, bestScore, s
+		} else if s > secondScore {
+			secondScore = s
+		}
+
+	}
+
+	switch {
+
+	case bestScore >= rematchAutoScore && bestScore > secondScore:
+		return r.use(best), "similarity", bestScore, true
+
+	case bestScore >= rematchReviewScore:
+		r.queue(best, m3uChannel, hash, bestScore)
+
+	}
+
+	return
+}
+
+func (r *rematcher) use(channel XEPGChannelStruct) XEPGChannelStruct {
+	r.used[channel.XEPG] = true
+	return channel
+}
+
+// Unsichere Zuordnung vormerken, eine bereits getroffene Entscheidung wird nicht erneut abgefragt
+func (r *rematcher) queue(old XEPGChannelStruct, m3uChannel M3UChannelStructXEPG, hash string, score float64) {
+
+	var sum = md5.Sum([]byte(old.XEPG + "|" + old.ChannelUniqueID + "|" + hash))
+	var id = hex.EncodeToString(sum[:])
+
+	if _, ok := r.matches[id]; ok {
+		return
+	}
+
+	r.used[old.XEPG] = true
+	r.pending[hash] = id
+	r.matches[id] = ChannelMatch{
+		ID:          id,
+		Time:        time.Now().Format("2006-01-02 15:04:05"),
+		Status:      "pending",
+		Method:      "similarity",
+		Score:       score,
+		FileM3UID:   m3uChannel.FileM3UID,
+		FileM3UName: m3uChannel.FileM3UName,
+		OldName:     old.Name,
+		NewName:     m3uChannel.Name,
+		NewHash:     hash,
+		Old:         old,
+	}
+
+	showInfo(fmt.Sprintf("XEPG:Possible rename '%s' -> '%s' (%.2f), waiting for confirmation", old.Name, m3uChannel.Name, score))
+
+}
+
+// Der neue XEPG Kanal einer vorgemerkten Zuordnung
+func (r *rematcher) created(hash, xepg string) {
+
+	if id, ok := r.pending[hash]; ok {
+		var match = r.matches[id]
+		match.NewXEPG = xepg
+		r.matches[id] = match
+	}
+
+}
+
+func (r *rematcher) save() {
+
+	if len(r.pending) == 0 {
+		return
+	}
+
+	if err := saveChannelMatches(r.matches); err != nil {
+		ShowError(err, 000)
+	}
+
+}
+
+// Werte des Providers vom neuen Stream mit den Benutzereinstellungen des bisherigen Kanals
+func carryChannelCustomisations(old, channel XEPGChannelStruct) XEPGChannelStruct {
+
+	var name, logo = channel.Name, channel.TvgLogo
+
+	channel.XActive = old.XActive
+	channel.XCategory = old.XCategory
+	channel.XChannelID = old.XChannelID
+	channel.TvgChno = old.TvgChno
+	channel.XGroupTitle = old.XGroupTitle
+	channel.XGroupRewrite = old.XGroupRewrite
+	channel.XMapping = old.XMapping
+	channel.XmltvFile = old.XmltvFile
+	channel.XEPGSources = old.XEPGSources
+	channel.XEPGOffset = old.XEPGOffset
+	channel.XPpvExtra = old.XPpvExtra
+	channel.XBackupChannel1 = old.XBackupChannel1
+	channel.XBackupChannel2 = old.XBackupChannel2
+	channel.XBackupChannel3 = old.XBackupChannel3
+	channel.BackupChannel1 = old.BackupChannel1
+	channel.BackupChannel2 = old.BackupChannel2
+	channel.BackupChannel3 = old.BackupChannel3
+	channel.XHideChannel = old.XHideChannel
+	channel.XDescription = old.XDescription
+	channel.XUpdateChannelIcon = old.XUpdateChannelIcon
+	channel.XUpdateChannelName = old.XUpdateChannelName
+
+	channel.XName = old.XName
+	if old.XUpdateChannelName {
+		channel.XName = name
+	}
+
+	channel.TvgLogo = old.TvgLogo
+	if old.XUpdateChannelIcon {
+		channel.TvgLogo = logo
+	}
+
+	return channel
+}
+
+// XEPG Kanal mit den Werten eines Streams (nur die Werte des Providers)
+func newXEPGChannelFromStream(m3uChannel M3UChannelStructXEPG, hash string) (channel XEPGChannelStruct) {
+
+	channel.FileM3UID = m3uChannel.FileM3UID
+	channel.FileM3UName = m3uChannel.FileM3UName
+	channel.FileM3UPath = m3uChannel.FileM3UPath
+	channel.Values = m3uChannel.Values
+	channel.GroupTitle = m3uChannel.GroupTitle
+	channel.Name = m3uChannel.Name
+	channel.TvgID = m3uChannel.TvgID
+	channel.TvgName = m3uChannel.TvgName
+	channel.URL = m3uChannel.URL
+	channel.UUIDKey = m3uChannel.UUIDKey
+	channel.UUIDValue = m3uChannel.UUIDValue
+	channel.Live, _ = strconv.ParseBool(m3uChannel.LiveEvent)
+	channel.ChannelUniqueID = hash
+
+	var imgc = Data.Cache.Images
+	if imgc != nil {
+		channel.TvgLogo = imgc.Image.GetURL(m3uChannel.TvgLogo, Settings.HttpThreadfinDomain, Settings.Port, Settings.ForceHttps, Settings.HttpsPort, Settings.HttpsThreadfinDomain)
+	} else {
+		channel.TvgLogo = m3uChannel.TvgLogo
+	}
+
+	return
+}
+
+// Vorgemerkte Zuordnungen (API: matches.list)
+func getChannelMatches() (list []ChannelMatch, err error) {
+
+	matches, err := loadChannelMatches()
+	if err != nil {
+		return
+	}
+
+	for _, match := range matches {
+		list = append(list, match)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Time > list[j].Time })
+
+	return
+}
+
+// Vorgemerkte Zuordnung bestätigen oder ablehnen. Bei einer Bestätigung werden die Einstellungen des alten Kanals übernommen.
+func decideChannelMatch(id string, confirm bool) (err error) {
+
+	xepgMutex.Lock()
+
+	matches, err := loadChannelMatches()
+	if err != nil {
+		xepgMutex.Unlock()
+		return
+	}
+
+	match, ok := matches[id]
+	if !ok || match.Status != "pending" {
+		xepgMutex.Unlock()
+		return errors.New(getErrMsg(1023))
+	}
+
+	match.Status = "rejected"
+
+	if confirm {
+
+		var channel XEPGChannelStruct
+		var found bool
+
+		for _, dxc := range Data.XEPG.Channels {
+
+			if err = json.Unmarshal([]byte(mapToJSON(dxc)), &channel); err != nil {
+				continue
+			}
+
+			if channel.ChannelUniqueID == match.NewHash && channel.FileM3UID == match.FileM3UID {
+				found = true
+				break
+			}
+
+		}
+
+		if !found {
+			xepgMutex.Unlock()
+			return errors.New(getErrMsg(1024))
+		}
+
+		var newChannelID = channel.XChannelID
+		channel = carryChannelCustomisations(match.Old, channel)
+
+		// Die alte Kanalnummer könnte inzwischen von einem anderen Kanal verwendet werden
+		for _, dxc := range Data.XEPG.Channels {
+
+			var other XEPGChannelStruct
+			if json.Unmarshal([]byte(mapToJSON(dxc)), &other) != nil || other.XEPG == channel.XEPG {
+				continue
+			}
+
+			if other.XChannelID == channel.XChannelID {
+				showInfo(fmt.Sprintf("XEPG:Channel number %s is already used by '%s', '%s' keeps %s", channel.XChannelID, other.XName, channel.XName, newChannelID))
+				channel.XChannelID, channel.TvgChno = newChannelID, newChannelID
+				break
+			}
+
+		}
+
+		Data.XEPG.Channels[channel.XEPG] = channel
+		match.NewXEPG = channel.XEPG
+		match.Status = "confirmed"
+
+		err = saveMapToJSONFile(System.File.XEPG, Data.XEPG.Channels)
+		if err != nil {
+			xepgMutex.Unlock()
+			return
+		}
+
+	}
+
+	matches[id] = match
+	err = saveChannelMatches(matches)
+	xepgMutex.Unlock()
+
+	if err == nil && confirm {
+		buildXEPG(true)
+	}
+
+	return
+}
+
+func loadChannelMatches() (matches map[string]ChannelMatch, err error) {
+
+	matches = make(map[string]ChannelMatch)
+
+	tmpMap, err := loadJSONFileToMap(System.File.Matches)
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal([]byte(mapToJSON(tmpMap)), &matches)
+
+	return
+}
+
+// Entschiedene Zuordnungen werden nach 30 Tagen entfernt
+func saveChannelMatches(matches map[string]ChannelMatch) error {
+
+	var limit = time.Now().AddDate(0, 0, -30).Format("2006-01-02 15:04:05")
+
+	for id, match := range matches {
+		if match.Status != "pending" && match.Time < limit {
+			delete(matches, id)
+		}
+	}
+
+	return saveMapToJSONFile(System.File.Matches, matches)
+}
@@ -0,0 +1,135 @@
+package src
+
+import (
+	"fmt"
+
+	"threadfin/src/internal/rewrite"
+)
+
+// compileRewriteRules : Aktive Regeln in der Reihenfolge der Einstellungen
+func compileRewriteRules(rules []RewriteRule) (set *rewrite.Set, err error) {
+
+	var list = make([]rewrite.Rule, 0, len(rules))
+
+	for _, rule := range rules {
+
+		if !rule.Active {
+			continue
+		}
+
+		list = append(list, rewrite.Rule{
+			Type:          rule.Type,
+			Field:         rule.Field,
+			Pattern:       rule.Pattern,
+			Value:         rule.Value,
+			CaseSensitive: rule.CaseSensitive,
+		})
+
+	}
+
+	set, err = rewrite.Compile(list)
+	if err != nil {
+		err = fmt.Errorf("%s: %s", getErrMsg(1025), err)
+	}
+
+	return
+}
+
+// rewriteChannel : Name, Gruppe, tvg-id und Logo des Streams nach den Regeln
+func rewriteChannel(set *rewrite.Set, m3uChannel M3UChannelStructXEPG) rewrite.Channel {
+
+	return set.Apply(rewrite.Channel{
+		Name:  m3uChannel.Name,
+		Group: m3uChannel.GroupTitle,
+		TvgID: m3uChannel.TvgID,
+		Logo:  m3uChannel.TvgLogo,
+	})
+}
+
+// rewriteXEPGChannel : Regeln auf die Werte des Providers eines XEPG Kanals anwenden
+func rewriteXEPGChannel(set *rewrite.Set, xepgChannel XEPGChannelStruct) rewrite.Channel {
+
+	return set.Apply(rewrite.Channel{
+		Name:  xepgChannel.Name,
+		Group: xepgChannel.GroupTitle,
+		TvgID: xepgChannel.TvgID,
+		Logo:  xepgChannel.TvgLogo,
+	})
+}
+
+// previewRewriteRules : Wendet die Regeln auf alle aktiven Streams an, ohne sie zu speichern. Die Gruppe gilt für Kanäle,
+// deren Gruppe nicht bearbeitet wurde, die tvg-id wird für Kanäle ohne XMLTV Kanal verwendet.
+func previewRewriteRules(rules []RewriteRule) (preview *RewritePreview, err error) {
+
+	set, err := compileRewriteRules(rules)
+	if err != nil {
+		return
+	}
+
+	preview = &RewritePreview{Channels: []RewritePreviewChannel{}}
+
+	for _, s := range Data.Streams.Active {
+
+		stream, ok := s.(map[string]string)
+		if !ok {
+			continue
+		}
+
+		var m3uChannel = M3UChannelStructXEPG{
+			FileM3UName: stream["_file.m3u.name"],
+			GroupTitle:  stream["group-title"],
+			Name:        stream["name"],
+			TvgID:       stream["tvg-id"],
+			TvgLogo:     stream["tvg-logo"],
+		}
+
+		preview.Total++
+
+		var channel = rewriteChannel(set, m3uChannel)
+		var old = RewriteValues{Name: m3uChannel.Name, Group: m3uChannel.GroupTitle, TvgID: m3uChannel.TvgID, Logo: m3uChannel.TvgLogo}
+		var new = RewriteValues{Name: channel.Name, Group: channel.Group, TvgID: channel.TvgID, Logo: channel.Logo}
+
+		if old == new {
+			continue
+		}
+
+		preview.Changed++
+		preview.Channels = append(preview.Channels, RewritePreviewChannel{Playlist: m3uChannel.FileM3UName, Old: old, New: new})
+
+	}
+
+	return
+}
+
+// saveRewriteRules : Speichert die Regeln und erstellt die XEPG Datenbank neu
+func saveRewriteRules(rules []RewriteRule) (settings SettingsStruct, err error) {
+
+	if rules == nil {
+		rules = []RewriteRule{}
+	}
+
+	// Inaktive Regeln werden ebenfalls geprüft, sie müssen beim Einschalten gültig sein
+	var all = make([]RewriteRule, len(rules))
+	for i, rule := range rules {
+		rule.Active = true
+		all[i] = rule
+	}
+
+	_, err = compileRewriteRules(all)
+	if err != nil {
+		return
+	}
+
+	Settings.Rewrite = rules
+
+	err = saveSettings(Settings)
+	if err != nil {
+		return
+	}
+
+	settings = Settings
+
+	buildXEPG(false)
+
+	return
+}
@@ -0,0 +1,412 @@
+package src
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"threadfin/src/internal/cron"
+	"threadfin/src/internal/xmltvindex"
+)
+
+// scheduleEntry : Laufzeitstatus eines geplanten Jobs
+type scheduleEntry struct {
+	ScheduleJob
+
+	order         int
+	schedule      cron.Schedule
+	jitter        time.Duration
+	deferWhenBusy bool
+	deferredSince time.Time
+	next          time.Time
+	triggered     bool
+	run           func() error
+}
+
+var (
+	scheduleMutex sync.Mutex
+	scheduleJobs  = make(map[string]*scheduleEntry)
+)
+
+// Jobs des Zeitplans aus den aktuellen Einstellungen erstellen
+func scheduleDefinitions() (entries []*scheduleEntry) {
+
+	var clock cron.Schedule
+	if len(Settings.Update) > 0 {
+		clock, _ = cron.ParseClock(Settings.Update)
+	}
+
+	var clockSpec = fmt.Sprintf("%v", Settings.Update)
+
+	// Backup erstellen
+	entries = append(entries, &scheduleEntry{
+		ScheduleJob: ScheduleJob{Name: "backup", Description: "Automatic backup", Schedule: clockSpec},
+		schedule:    clock,
+		run:         ThreadfinAutoBackup,
+	})
+
+	// Adressen und Anzahl der Tuner der importierten HDHomeRun Tuner, einmal pro Aktualisierung vor den Playlisten
+	if len(importedHDHRTuners()) > 0 {
+		entries = append(entries, &scheduleEntry{
+			ScheduleJob: ScheduleJob{Name: "hdhr.addresses", Description: "Update the addresses of the imported HDHomeRun tuners", Schedule: clockSpec},
+			schedule:    clock,
+			run:         updateHDHRAddresses,
+		})
+	}
+
+	// Playlist und XMLTV Dateien aktualisieren, jede Quelle hat ihren eigenen Zeitplan
+	var fileTypes = []string{"m3u", "hdhr"}
+	if Settings.EpgSource == "XEPG" {
+		fileTypes = append(fileTypes, "xmltv")
+	}
+
+	for _, fileType := range fileTypes {
+
+		var dataMap map[string]interface{}
+
+		switch fileType {
+		case "m3u":
+			dataMap = Settings.Files.M3U
+		case "hdhr":
+			dataMap = Settings.Files.HDHR
+		case "xmltv":
+			dataMap = Settings.Files.XMLTV
+		}
+
+		var ids = make([]string, 0, len(dataMap))
+		for id := range dataMap {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		for _, id := range ids {
+
+			var data, ok = dataMap[id].(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			var fileType, id = fileType, id
+			var entry = &scheduleEntry{
+				ScheduleJob: ScheduleJob{
+					Name:        fmt.Sprintf("provider.%s.%s", fileType, id),
+					Description: fmt.Sprintf("Update %s: %s", fileType, getProviderParameter(id, fileType, "name")),
+					Schedule:    clockSpec,
+				},
+				schedule: clock,
+				run: func() (err error) {
+					err = getProviderData(fileType, id)
+					triggerScheduleJob("xepg.rebuild")
+					return
+				},
+			}
+
+			if spec, ok := data["update.schedule"].(string); ok && len(spec) > 0 {
+
+				schedule, err := cron.Parse(spec)
+				if err != nil {
+					ShowError(fmt.Errorf("%s: %s", entry.Description, err), 1015)
+				} else {
+					entry.schedule = schedule
+					entry.Schedule = spec
+				}
+
+			}
+
+			if jitter, ok := data["update.jitter"].(float64); ok && jitter > 0 {
+				entry.jitter = time.Duration(jitter) * time.Minute
+				entry.Jitter = int(jitter)
+			}
+
+			if deferWhenBusy, ok := data["update.defer"].(bool); ok {
+				entry.deferWhenBusy = deferWhenBusy
+				entry.DeferWhenBusy = deferWhenBusy
+			}
+
+			entries = append(entries, entry)
+
+		}
+
+	}
+
+	// Bilder Cache aufräumen
+	entries = append(entries, &scheduleEntry{
+		ScheduleJob: ScheduleJob{Name: "images.cleanup", Description: "Remove cached images", Schedule: clockSpec},
+		schedule:    clock,
+		run: func() (err error) {
+
+			systemMutex.Lock()
+			var remove = !Settings.CacheImages && System.ImageCachingInProgress == 0
+			systemMutex.Unlock()
+
+			if remove {
+				err = removeChildItems(System.Folder.ImagesCache)
+			}
+
+			return
+		},
+	})
+
+	// Event Kanäle (PPV) nur um ihre Eventzeit aktivieren
+	if Settings.PPVAuto && Settings.EpgSource == "XEPG" {
+
+		var every, _ = cron.Parse("@every 1m")
+		entries = append(entries, &scheduleEntry{
+			ScheduleJob: ScheduleJob{Name: "ppv.activation", Description: "Activate event channels around their event time", Schedule: "@every 1m"},
+			schedule:    every,
+			run: func() error {
+				return queuePPVActivation().wait()
+			},
+		})
+
+	}
+
+	// DVR Datenbank und XEPG Dateien erstellen, ausgelöst durch die Aktualisierung der Provider
+	entries = append(entries, &scheduleEntry{
+		ScheduleJob: ScheduleJob{Name: "xepg.rebuild", Description: "Rebuild DVR database and XEPG files", Schedule: "on demand"},
+		run: func() (err error) {
+
+			err = buildDatabaseDVR()
+			if err != nil {
+				return
+			}
+
+			systemMutex.Lock()
+			Data.Cache.XMLTV = make(map[string]*xmltvindex.Index)
+			systemMutex.Unlock()
+
+			buildXEPG(false)
+			return
+		},
+	})
+
+	// Threadfin aktualisieren (Binary)
+	var update, _ = cron.ParseClock([]string{System.TimeForAutoUpdate})
+	entries = append(entries, &scheduleEntry{
+		ScheduleJob: ScheduleJob{Name: "threadfin.update", Description: "Update Threadfin (binary)", Schedule: System.TimeForAutoUpdate},
+		schedule:    update,
+		run:         BinaryUpdate,
+	})
+
+	for i, entry := range entries {
+		entry.order = i
+	}
+
+	return
+}
+
+// Jobs mit den aktuellen Einstellungen abgleichen, der Laufzeitstatus bleibt erhalten
+func syncScheduleJobs(now time.Time) {
+
+	var entries = scheduleDefinitions()
+	var active = make(map[string]bool)
+
+	scheduleMutex.Lock()
+	defer scheduleMutex.Unlock()
+
+	for _, entry := range entries {
+
+		active[entry.Name] = true
+
+		if old, ok := scheduleJobs[entry.Name]; ok {
+
+			entry.LastRun = old.LastRun
+			entry.Status = old.Status
+			entry.Error = old.Error
+			entry.Duration = old.Duration
+			entry.deferredSince = old.deferredSince
+			entry.triggered = old.triggered
+
+			// Unveränderter Zeitplan, die nächste Ausführung (inklusive Jitter) bleibt erhalten
+			if old.Schedule == entry.Schedule && old.Jitter == entry.Jitter {
+				entry.next = old.next
+			}
+
+		}
+
+		if entry.next.IsZero() && entry.schedule != nil {
+			entry.next = nextScheduleTime(entry, now)
+		}
+
+		if len(entry.Status) == 0 {
+			entry.Status = "idle"
+		}
+
+		scheduleJobs[entry.Name] = entry
+
+	}
+
+	for name := range scheduleJobs {
+		if !active[name] {
+			delete(scheduleJobs, name)
+		}
+	}
+
+}
+
+func nextScheduleTime(entry *scheduleEntry, now time.Time) (next time.Time) {
+
+	next = entry.schedule.Next(now)
+	if !next.IsZero() && entry.jitter > 0 {
+		next = next.Add(time.Duration(rand.Int63n(int64(entry.jitter))))
+	}
+
+	return
+}
+
+// Alle fälligen Jobs nacheinander ausführen
+func runScheduleJobs(now time.Time) {
+
+	syncScheduleJobs(now)
+
+	scheduleMutex.Lock()
+	var entries = make([]*scheduleEntry, 0, len(scheduleJobs))
+	for _, entry := range scheduleJobs {
+		entries = append(entries, entry)
+	}
+	scheduleMutex.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].order < entries[j].order })
+
+	for _, entry := range entries {
+
+		scheduleMutex.Lock()
+		var due = entry.triggered || (!entry.next.IsZero() && !now.Before(entry.next))
+		scheduleMutex.Unlock()
+
+		if !due {
+			continue
+		}
+
+		// Während eine Datenbank erstellt wird, wird der Job beim nächsten Durchlauf wiederholt
+		systemMutex.Lock()
+		var scanInProgress = System.ScanInProgress == 1
+		systemMutex.Unlock()
+
+		if scanInProgress {
+			continue
+		}
+
+		if entry.deferWhenBusy && !entry.triggered && tunersInUse() > 0 {
+
+			scheduleMutex.Lock()
+			if entry.deferredSince.IsZero() {
+				entry.deferredSince = now
+			}
+			var expired = now.Sub(entry.deferredSince) >= time.Duration(Settings.UpdateDeferMax)*time.Minute
+			if !expired {
+				entry.Status = "deferred"
+				scheduleMutex.Unlock()
+				continue
+			}
+			scheduleMutex.Unlock()
+
+			showInfo(fmt.Sprintf("Scheduler:%s was deferred for %d minutes, running now", entry.Name, Settings.UpdateDeferMax))
+		}
+
+		runScheduleJob(entry)
+
+	}
+
+}
+
+func runScheduleJob(entry *scheduleEntry) {
+
+	var start = time.Now()
+
+	scheduleMutex.Lock()
+	entry.Status = "running"
+	entry.Error = ""
+	entry.triggered = false
+	entry.deferredSince = time.Time{}
+	scheduleMutex.Unlock()
+
+	showInfo(fmt.Sprintf("Scheduler:Run %s", entry.Name))
+
+	var err = entry.run()
+
+	scheduleMutex.Lock()
+	defer scheduleMutex.Unlock()
+
+	entry.LastRun = start.Format("2006-01-02 15:04:05")
+	entry.Duration = time.Since(start).Seconds()
+	entry.Status = "ok"
+
+	if err != nil {
+		entry.Status = "failed"
+		entry.Error = err.Error()
+		ShowError(fmt.Errorf("%s: %s", entry.Name, err), 000)
+	}
+
+	if entry.schedule != nil {
+		entry.next = nextScheduleTime(entry, time.Now())
+	}
+
+}
+
+// Job beim nächsten Durchlauf des Zeitplans ausführen
+func triggerScheduleJob(name string) (err error) {
+
+	scheduleMutex.Lock()
+	defer scheduleMutex.Unlock()
+
+	entry, ok := scheduleJobs[name]
+	if !ok {
+		return errors.New(getErrMsg(1016))
+	}
+
+	entry.triggered = true
+	entry.Status = "queued"
+
+	return
+}
+
+// Status aller Jobs für die API
+func getScheduleJobs() (jobs []ScheduleJob) {
+
+	scheduleMutex.Lock()
+	defer scheduleMutex.Unlock()
+
+	var entries = make([]*scheduleEntry, 0, len(scheduleJobs))
+	for _, entry := range scheduleJobs {
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].order < entries[j].order })
+
+	for _, entry := range entries {
+
+		var job = entry.ScheduleJob
+
+		if !entry.next.IsZero() {
+			job.NextRun = entry.next.Format("2006-01-02 15:04:05")
+		}
+
+		jobs = append(jobs, job)
+
+	}
+
+	return
+}
+
+// Anzahl der Tuner, die gerade einen Stream über den Puffer liefern
+func tunersInUse() (count int) {
+
+	BufferInformation.Range(func(key, value interface{}) bool {
+
+		if playlist, ok := value.(Playlist); ok {
+			for _, client := range playlist.Clients {
+				if client.Connection > 0 {
+					count++
+				}
+			}
+		}
+
+		return true
+	})
+
+	return
+}
@@ -257,6 +257,58 @@ func getErrMsg(errCode int) (errMsg string) {
 		errMsg = fmt.Sprintf("Invalid settings file (settings.json), file must be at least version %s", System.Compatibility)
 	case 1014:
 		errMsg = fmt.Sprintf("Invalid filter rule")
+	case 1015:
+		errMsg = fmt.Sprintf("Invalid update schedule, use a cron expression (m h dom mon dow) or an interval (@every 6h)")
+	case 1016:
+		errMsg = fmt.Sprintf("Scheduler job not found")
+	case 1017:
+		errMsg = fmt.Sprintf("Job not found")
+	case 1018:
+		errMsg = fmt.Sprintf("Job was canceled")
+	case 1019:
+		errMsg = fmt.Sprintf("Playlist change report not found")
+	case 1023:
+		errMsg = fmt.Sprintf("Channel match not found or already decided")
+	case 1024:
+		errMsg = fmt.Sprintf("The renamed channel no longer exists in the XEPG database")
+	case 1025:
+		errMsg = fmt.Sprintf("Invalid rewrite rule")
+	case 1026:
+		errMsg = fmt.Sprintf("Invalid numbering policy, use sequential, name or tvg-chno")
+	case 1027:
+		errMsg = fmt.Sprintf("Filter not found")
+	case 1028:
+		errMsg = fmt.Sprintf("Invalid virtual device")
+	case 1029:
+		errMsg = fmt.Sprintf("HDHomeRun tuner not found, run the discovery again")
+	case 1032:
+		errMsg = fmt.Sprintf("XEPG channel not found")
+	case 1033:
+		errMsg = fmt.Sprintf("Invalid EPG offset, use minutes between -1440 and 1440 (e.g. 60 or -30)")
+	case 1034:
+		errMsg = fmt.Sprintf("Invalid EPG timezone, use an IANA name (Europe/Berlin) or an offset (+0100)")
+	case 1035:
+		errMsg = fmt.Sprintf("Invalid EPG window, use a number of hours / days (0 keeps all programmes)")
+	case 1036:
+		errMsg = fmt.Sprintf("Invalid time, use RFC 3339 (2024-01-01T20:00:00Z) or a Unix timestamp")
+	case 1037:
+		errMsg = fmt.Sprintf("Search query is empty")
+	case 1038:
+		errMsg = fmt.Sprintf("The XMLTV file has not been created yet")
+	case 1039:
+		errMsg = fmt.Sprintf("Invalid manual programme")
+	case 1040:
+		errMsg = fmt.Sprintf("Invalid dummy template")
+	case 1041:
+		errMsg = fmt.Sprintf("Invalid event time rule")
+	case 1042:
+		errMsg = fmt.Sprintf("Invalid event duration, use 0 - 1440 minutes (0 = until the end of the day)")
+	case 1043:
+		errMsg = fmt.Sprintf("Invalid PPV activation window, use 0 - 1440 minutes")
+	case 1044:
+		errMsg = fmt.Sprintf("Invalid genre rule")
+	case 1045:
+		errMsg = fmt.Sprintf("Invalid genre normalisation, use off, add or replace")
 
 	case 1020:
 		errMsg = fmt.Sprintf("Data could not be saved, invalid keyword")
@@ -321,6 +373,8 @@ func getErrMsg(errCode int) (errMsg string) {
 		errMsg = fmt.Sprintf("Buffer is disabled for this stream.")
 	case 2005:
 		errMsg = fmt.Sprintf("There are no channels mapped, use the mapping menu to assign EPG data to the channels.")
+	case 2006:
+		errMsg = fmt.Sprintf("The active lineup has more than %d channels, Plex ignores the remaining channels. Enable lineup shards to split the lineup into several devices.", System.PlexChannelLimit)
 	case 2010:
 		errMsg = fmt.Sprintf("No valid streaming URL")
 	case 2020:
@@ -0,0 +1,184 @@
+package src
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"threadfin/src/internal/hdhomerun"
+)
+
+// Aufteilung des Lineups. Plex verwendet nur die ersten System.PlexChannelLimit Kanäle eines Geräts.
+// Mit lineup.shards wird das aktive Lineup auf mehrere Geräte aufgeteilt: Shard 1 ist das Hauptgerät,
+// jeder weitere Shard wird als virtuelles Gerät "shard-<n>" angeboten. Ein Kanal behält seinen Shard über
+// Neuerstellungen hinweg (shards.json), neue Kanäle kommen in den ersten Shard mit freiem Platz.
+
+var (
+	lineupShards map[string]int
+	shardDevices []VirtualDevice
+)
+
+// updateLineupShards : Verteilt die aktiven Kanäle auf die Shards, läuft nach jedem XEPG Build
+func updateLineupShards() (err error) {
+
+	var active = activeLineupChannels()
+
+	if !Settings.LineupShards {
+
+		deviceMutex.Lock()
+		var changed = len(shardDevices) > 0
+		lineupShards, shardDevices = nil, nil
+		deviceMutex.Unlock()
+
+		if changed {
+			advertiseVirtualDevices()
+		}
+
+		if len(active) > System.PlexChannelLimit {
+			showInfo(fmt.Sprintf("XEPG:Active channels: %d", len(active)))
+			showWarning(2006)
+		}
+
+		return
+	}
+
+	shards, err := loadLineupShards()
+	if err != nil {
+		return
+	}
+
+	var limit = System.PlexChannelLimit
+	var count = make(map[int]int)
+	var assigned = make(map[string]int)
+
+	// Kanäle behalten ihren Shard, entfernte Kanäle geben ihren Platz frei
+	for _, channel := range active {
+		if shard, ok := shards[channel.XEPG]; ok && shard > 0 && count[shard] < limit {
+			assigned[channel.XEPG] = shard
+			count[shard]++
+		}
+	}
+
+	for _, channel := range active {
+
+		if _, ok := assigned[channel.XEPG]; ok {
+			continue
+		}
+
+		var shard = 1
+		for count[shard] >= limit {
+			shard++
+		}
+
+		assigned[channel.XEPG] = shard
+		count[shard]++
+
+	}
+
+	err = saveMapToJSONFile(System.File.Shards, assigned)
+	if err != nil {
+		return
+	}
+
+	var devices = make([]VirtualDevice, 0)
+	var channels = make(map[int][]string)
+	var last int
+
+	for id, shard := range assigned {
+		channels[shard] = append(channels[shard], id)
+		if shard > last {
+			last = shard
+		}
+	}
+
+	for shard := 2; shard <= last; shard++ {
+
+		var id = fmt.Sprintf("shard-%d", shard)
+
+		sort.Strings(channels[shard])
+
+		devices = append(devices, VirtualDevice{
+			Active:   true,
+			ID:       id,
+			Name:     fmt.Sprintf("%s %d", System.Name, shard),
+			DeviceID: hdhomerun.FormatDeviceID(hdhomerun.DeviceID(Settings.UUID + id)),
+			Tuner:    Settings.Tuner,
+			Channels: channels[shard],
+		})
+
+	}
+
+	deviceMutex.Lock()
+	var changed = len(devices) != len(shardDevices)
+	lineupShards, shardDevices = assigned, devices
+	deviceMutex.Unlock()
+
+	if changed {
+		advertiseVirtualDevices()
+	}
+
+	showInfo(fmt.Sprintf("XEPG:Lineup shards: %d (%d channels)", max(last, 1), len(active)))
+
+	return
+}
+
+// inMainShard : true, wenn der Kanal zum Lineup des Hauptgeräts gehört
+func inMainShard(xepg string) bool {
+
+	deviceMutex.RLock()
+	defer deviceMutex.RUnlock()
+
+	if lineupShards == nil || len(xepg) == 0 {
+		return true
+	}
+
+	shard, ok := lineupShards[xepg]
+
+	return !ok || shard == 1
+}
+
+// activeLineupChannels : Kanäle des Lineups nach Kanalnummer sortiert
+func activeLineupChannels() (list []XEPGChannelStruct) {
+
+	for _, dxc := range Data.XEPG.Channels {
+
+		var xepgChannel XEPGChannelStruct
+		if err := json.Unmarshal([]byte(mapToJSON(dxc)), &xepgChannel); err != nil {
+			continue
+		}
+
+		if xepgChannel.XActive && !xepgChannel.XHideChannel {
+			list = append(list, xepgChannel)
+		}
+
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+
+		a, errA := strconv.ParseFloat(list[i].XChannelID, 64)
+		b, errB := strconv.ParseFloat(list[j].XChannelID, 64)
+
+		if errA != nil || errB != nil || a == b {
+			return list[i].XEPG < list[j].XEPG
+		}
+
+		return a < b
+	})
+
+	return
+}
+
+func loadLineupShards() (shards map[string]int, err error) {
+
+	shards = make(map[string]int)
+
+	tmpMap, err := loadJSONFileToMap(System.File.Shards)
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal([]byte(mapToJSON(tmpMap)), &shards)
+
+	return
+}
@@ -8,6 +8,8 @@ import (
   "os/signal"
   "time"
 
+  "threadfin/src/internal/hdhomerun"
+
   "github.com/koron/go-ssdp"
 )
 
@@ -39,6 +41,8 @@ func SSDP() (err error) {
     ssdp.Logger = log.New(os.Stderr, "[SSDP] ", log.LstdFlags)
   }
 
+  advertiseDLNA()
+
   go func(adv *ssdp.Advertiser) {
 
     aliveTick := time.Tick(300 * time.Second)
@@ -60,6 +64,8 @@ func SSDP() (err error) {
       case <-quit:
         adv.Bye()
         adv.Close()
+        byeVirtualDevices()
+        byeDLNA()
         os.Exit(0)
         break loop
 
@@ -75,7 +81,7 @@ func SSDP() (err error) {
 // startUDPDiscovery starts UDP discovery service on port 65001 for HDHomeRun compatibility
 func startUDPDiscovery() {
   go func() {
-    addr, err := net.ResolveUDPAddr("udp", ":65001")
+    addr, err := net.ResolveUDPAddr("udp", fmt.Sprintf(":%d", hdhomerun.Port))
     if err != nil {
       ShowError(err, 0)
       return
@@ -98,9 +104,18 @@ func startUDPDiscovery() {
         continue
       }
 
+      // Binäres HDHomeRun Discovery Paket (TLV + CRC)
+      replies, err := hdhomerun.Respond(buffer[:n], discoveryDevices())
+      if err == nil {
+        for _, reply := range replies {
+          conn.WriteToUDP(reply, clientAddr)
+        }
+        continue
+      }
+
       request := string(buffer[:n])
-      
-      // HDHomeRun discovery protocol - must match exact format
+
+      // Ältere Clients senden die Anfrage als Text
       if request == "getmyaddr" {
         response := fmt.Sprintf("getmyaddr\n%s\n", clientAddr.IP.String())
         conn.WriteToUDP([]byte(response), clientAddr)
@@ -108,6 +123,11 @@ func startUDPDiscovery() {
         // HDHomeRun discovery response format
         response := fmt.Sprintf("discover\n%s %s\n", System.DeviceID, System.URLBase)
         conn.WriteToUDP([]byte(response), clientAddr)
+
+        // Virtuelle Geräte antworten jeweils mit eigener Geräte ID
+        for _, line := range virtualDeviceDiscovery() {
+          conn.WriteToUDP([]byte(fmt.Sprintf("discover\n%s\n", line)), clientAddr)
+        }
       }
     }
   }()
@@ -21,9 +21,20 @@ type Capability struct {
 		ModelNumber  string `xml:"modelNumber"`
 		SerialNumber string `xml:"serialNumber"`
 		UDN          string `xml:"UDN"`
+
+		ServiceList []CapabilityService `xml:"serviceList>service,omitempty"`
 	} `xml:"device"`
 }
 
+// CapabilityService : UPnP Service (DLNA)
+type CapabilityService struct {
+	ServiceType string `xml:"serviceType"`
+	ServiceID   string `xml:"serviceId"`
+	SCPDURL     string `xml:"SCPDURL"`
+	ControlURL  string `xml:"controlURL"`
+	EventSubURL string `xml:"eventSubURL"`
+}
+
 // Discover : HDHR Discover /discover.json
 type Discover struct {
 	BaseURL         string `json:"BaseURL"`
@@ -46,6 +57,17 @@ type LineupStatus struct {
 	SourceList     []string `json:"SourceList"`
 }
 
+// TunerStatus : HDHR Tuner Status /status.json
+type TunerStatus struct {
+	Resource              string `json:"Resource"`
+	VctNumber             string `json:"VctNumber,omitempty"`
+	VctName               string `json:"VctName,omitempty"`
+	SignalStrengthPercent int    `json:"SignalStrengthPercent,omitempty"`
+	SignalQualityPercent  int    `json:"SignalQualityPercent,omitempty"`
+	SymbolQualityPercent  int    `json:"SymbolQualityPercent,omitempty"`
+	NetworkRate           int    `json:"NetworkRate,omitempty"`
+}
+
 // Lineup : HDHR Lineup /lineup.json
 type Lineup []LineupStream
 
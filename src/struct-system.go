@@ -1,6 +1,10 @@
 package src
 
-import "threadfin/src/internal/imgcache"
+import (
+	"threadfin/src/internal/filterexpr"
+	"threadfin/src/internal/imgcache"
+	"threadfin/src/internal/xmltvindex"
+)
 
 // ServerProtocolStruct : Protocol settings for different server endpoints
 type ServerProtocolStruct struct {
@@ -46,9 +50,14 @@ type SystemStruct struct {
 
 	File struct {
 		Authentication string
+		Changes        string
 		M3U            string
+		Matches        string
 		PMS            string
+		Probe          string
+		Programs       string
 		Settings       string
+		Shards         string
 		URLS           string
 		XEPG           string
 		XML            string
@@ -134,9 +143,11 @@ type DataStruct struct {
 		ImagesFiles []string
 		ImagesURLS  []string
 		PMS         map[string]string
+		Probe       map[string]ProbeInfoStruct
 
 		StreamingURLS map[string]StreamInfo
-		XMLTV         map[string]XMLTV
+		XMLTV         map[string]*xmltvindex.Index
+		XMLTVBlocks   map[string]xmltvBlock
 
 		Streams struct {
 			Active []string
@@ -179,9 +190,18 @@ type DataStruct struct {
 
 // Filter : Wird für die Filterregeln verwendet
 type Filter struct {
+	Action        string
 	CaseSensitive bool
+	Expression    *filterexpr.Expr
+	ID            int64
 	LiveEvent     bool
+	Name          string
+	Numbering     string
+	NumberBlock   int
+	NumberAuto    bool
+	Priority      int
 	Rule          string
+	StartNumber   float64
 	Type          string
 }
 
@@ -205,8 +225,11 @@ type XEPGChannelStruct struct {
 	XChannelID         string        `json:"x-channelID"`
 	XEPG               string        `json:"x-epg"`
 	XGroupTitle        string        `json:"x-group-title"`
+	XGroupRewrite      string        `json:"x-group-rewrite,omitempty"` // Gruppe der Umschreibregeln, abweichende x-group-title wurden vom Benutzer geändert
 	XMapping           string        `json:"x-mapping"`
 	XmltvFile          string        `json:"x-xmltv-file"`
+	XEPGSources        []EPGSource   `json:"x-epg-sources,omitempty"`
+	XEPGOffset         string        `json:"x-epg-offset,omitempty"`
 	XPpvExtra          string        `json:"x-ppv-extra"`
 	XBackupChannel1    string        `json:"x-backup-channel-1"`
 	XBackupChannel2    string        `json:"x-backup-channel-2"`
@@ -224,6 +247,21 @@ type XEPGChannelStruct struct {
 	ChannelUniqueID    string        `json:"channelUniqueID"`
 }
 
+// EPGSource : Weitere EPG Quelle eines Kanals (x-epg-sources), füllt Lücken und fehlende Angaben der vorherigen Quellen
+type EPGSource struct {
+	File    string `json:"x-xmltv-file"`
+	Mapping string `json:"x-mapping"`
+}
+
+// EPGProvenance : Herkunft einer Sendung nach dem Zusammenführen der EPG Quellen
+type EPGProvenance struct {
+	Start  string            `json:"start"`
+	Stop   string            `json:"stop"`
+	Title  string            `json:"title"`
+	Source string            `json:"source"`
+	Filled map[string]string `json:"filled,omitempty"`
+}
+
 // M3UChannelStructXEPG : M3U Struktur für XEPG
 type M3UChannelStructXEPG struct {
 	FileM3UID       string `json:"_file.m3u.id,required"`
@@ -245,20 +283,332 @@ type M3UChannelStructXEPG struct {
 
 // FilterStruct : Filter Struktur
 type FilterStruct struct {
+	Action         string `json:"action"`
 	Active         bool   `json:"active"`
 	LiveEvent      bool   `json:"liveEvent"`
 	CaseSensitive  bool   `json:"caseSensitive"`
 	Description    string `json:"description"`
 	Exclude        string `json:"exclude"`
+	Expression     string `json:"expression"`
 	Filter         string `json:"filter"`
 	Include        string `json:"include"`
 	Name           string `json:"name"`
+	Numbering      string `json:"numbering"`
+	NumberingAuto  bool   `json:"numbering.auto"`
+	NumberingBlock int    `json:"numbering.block"`
+	Priority       int    `json:"priority"`
 	Rule           string `json:"rule,omitempty"`
 	Type           string `json:"type"`
 	StartingNumber string `json:"startingNumber"`
 	Category       string `json:"x-category"`
 }
 
+// ScheduleJob : Status eines geplanten Jobs (Scheduler)
+type ScheduleJob struct {
+	Name          string  `json:"name"`
+	Description   string  `json:"description"`
+	Schedule      string  `json:"schedule"`
+	Jitter        int     `json:"jitter,omitempty"`
+	DeferWhenBusy bool    `json:"defer,omitempty"`
+	LastRun       string  `json:"lastRun,omitempty"`
+	NextRun       string  `json:"nextRun,omitempty"`
+	Duration      float64 `json:"duration,omitempty"`
+	Status        string  `json:"status"`
+	Error         string  `json:"error,omitempty"`
+}
+
+// JobStruct : Hintergrundprozess (XEPG, Provider Update, Image Caching)
+type JobStruct struct {
+	ID          string   `json:"id"`
+	Type        string   `json:"type"`
+	Key         string   `json:"key"`
+	Description string   `json:"description"`
+	Status      string   `json:"status"`
+	Progress    int      `json:"progress"`
+	Log         []string `json:"log"`
+	Result      string   `json:"result,omitempty"`
+	Error       string   `json:"error,omitempty"`
+	Created     string   `json:"created"`
+	Started     string   `json:"started,omitempty"`
+	Finished    string   `json:"finished,omitempty"`
+}
+
+// PlaylistChangeReport : Änderungen einer Playlist zwischen zwei Aktualisierungen
+type PlaylistChangeReport struct {
+	ID       string           `json:"id"`
+	FileID   string           `json:"file.id"`
+	FileName string           `json:"file.name"`
+	FileType string           `json:"file.type"`
+	Time     string           `json:"time"`
+	Status   string           `json:"status"`
+	Summary  map[string]int   `json:"summary"`
+	Changes  []PlaylistChange `json:"changes,omitempty"`
+}
+
+// PlaylistChange : Einzelne Änderung (added, removed, renamed, url, group, epg)
+type PlaylistChange struct {
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Group  string `json:"group,omitempty"`
+	Old    string `json:"old,omitempty"`
+	New    string `json:"new,omitempty"`
+	URL    string `json:"url,omitempty"`
+	XEPG   string `json:"xepg,omitempty"`
+	XName  string `json:"x-name,omitempty"`
+	Active bool   `json:"x-active"`
+}
+
+// ChannelMatch : Umbenannter Kanal, der Vorschlag muss manuell bestätigt werden (matches.json)
+type ChannelMatch struct {
+	ID          string            `json:"id"`
+	Time        string            `json:"time"`
+	Status      string            `json:"status"`
+	Method      string            `json:"method"`
+	Score       float64           `json:"score"`
+	FileM3UID   string            `json:"_file.m3u.id"`
+	FileM3UName string            `json:"_file.m3u.name"`
+	OldName     string            `json:"old.name"`
+	NewName     string            `json:"new.name"`
+	NewHash     string            `json:"new.channelUniqueID"`
+	NewXEPG     string            `json:"new.x-epg,omitempty"`
+	Old         XEPGChannelStruct `json:"old"`
+}
+
+// EPGSuggestion : Vorschläge für die Zuordnung eines Kanals ohne EPG (Namensvergleich)
+type EPGSuggestion struct {
+	XEPG       string         `json:"x-epg"`
+	Name       string         `json:"name"`
+	Candidates []EPGCandidate `json:"candidates"`
+}
+
+// ManualProgram : Manuell eingetragene Sendung eines Kanals (programs.json)
+type ManualProgram struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"desc,omitempty"`
+	Category    string `json:"category,omitempty"`
+	Start       string `json:"start"`                // RFC 3339
+	Stop        string `json:"stop"`                 // RFC 3339
+	Recurrence  string `json:"recurrence,omitempty"` // daily, weekdays, weekends, weekly, weekly:mon,wed
+	Until       string `json:"until,omitempty"`      // RFC 3339, Ende der Wiederholung
+	Timezone    string `json:"timezone,omitempty"`   // Zeiten als Ortszeit dieser Zeitzone (Sommerzeit)
+}
+
+// DummyTemplate : Vorlage für den Dummy EPG von Kanälen oder Gruppen (settings.json)
+// Platzhalter in Titel und Beschreibung: {channel}, {group}, {category}, {daypart}, {weekday}, {day}, {date}, {start}, {stop}, {length}
+type DummyTemplate struct {
+	Name        string         `json:"name"`
+	Channels    []string       `json:"channels,omitempty"` // XEPG IDs
+	Groups      []string       `json:"groups,omitempty"`   // group-title / x-group-title
+	Title       string         `json:"title,omitempty"`
+	Description string         `json:"desc,omitempty"`
+	Category    string         `json:"category,omitempty"`
+	Icon        string         `json:"icon,omitempty"`
+	Length      int            `json:"length,omitempty"` // Minuten, 0 = Länge aus x-mapping
+	Parts       []DummyDayPart `json:"parts,omitempty"`
+}
+
+// DummyDayPart : Tageszeit einer Dummy Vorlage, z.B. "Morning News" 06:00 - 09:00
+type DummyDayPart struct {
+	Start       string `json:"start"`          // 06:00
+	Stop        string `json:"stop"`           // 09:00, vor dem Start = über Mitternacht
+	Days        string `json:"days,omitempty"` // leer / daily, weekdays, weekends oder mon,wed,fri
+	Title       string `json:"title,omitempty"`
+	Description string `json:"desc,omitempty"`
+	Category    string `json:"category,omitempty"`
+	Icon        string `json:"icon,omitempty"`
+	Length      int    `json:"length,omitempty"` // Minuten, 0 = eine Sendung für die ganze Tageszeit
+}
+
+// EventTimeRule : Erkennung der Startzeit von Live Events (PPV) im Kanalnamen für eine Playlist und / oder Gruppe (settings.json)
+type EventTimeRule struct {
+	Playlist string   `json:"playlist,omitempty"` // M3U ID, leer = alle Playlisten
+	Group    string   `json:"group,omitempty"`    // group-title / x-group-title, leer = alle Gruppen
+	Patterns []string `json:"patterns,omitempty"` // Reguläre Ausdrücke mit den Gruppen year, month, day, hour, minute, ampm, zone
+	Timezone string   `json:"timezone,omitempty"` // Zeitzone für Zeiten ohne Zone (Europe/London, CET, +01:00), leer = Serverzeit
+	DayFirst bool     `json:"dayFirst,omitempty"` // 18/10 statt 10/18
+	Duration int      `json:"duration,omitempty"` // Minuten, 0 = event.duration
+}
+
+// EventTimeResult : Erkannte Startzeit eines Kanalnamens (event.parse), wird nicht gespeichert
+type EventTimeResult struct {
+	Name  string `json:"name"`
+	Found bool   `json:"found"`
+	Start string `json:"start,omitempty"` // RFC 3339
+	Stop  string `json:"stop,omitempty"`  // RFC 3339
+}
+
+// GenreRule : Zuordnung von Kategorien (oder Titeln) der EPG Quellen zu einem Standard Genre (settings.json)
+type GenreRule struct {
+	Pattern string `json:"pattern"`          // Regulärer Ausdruck, Groß- / Kleinschreibung wird ignoriert
+	Genre   string `json:"genre"`            // Sports, Movie, News, Kids, Series oder None
+	Source  string `json:"source,omitempty"` // XMLTV Datei (ID), leer = alle Quellen
+	Title   bool   `json:"title,omitempty"`  // Regel für den Titel (genre.infer)
+}
+
+// PPVChannel : Aktivierungsfenster eines Event Kanals (ppv.list)
+type PPVChannel struct {
+	XEPG   string `json:"xepg"`
+	Name   string `json:"name"`
+	Found  bool   `json:"found"`          // Startzeit im Kanalnamen erkannt
+	From   string `json:"from,omitempty"` // RFC 3339, Start des Fensters
+	To     string `json:"to,omitempty"`   // RFC 3339, Ende des Fensters
+	Active bool   `json:"x-active"`
+}
+
+// EPGQuery : Abfrage des erstellten EPG (epg.nownext, epg.grid, epg.search)
+type EPGQuery struct {
+	Channels []string `json:"channels,omitempty"` // x-channelID, leer = alle Kanäle
+	Start    string   `json:"start,omitempty"`    // RFC 3339 oder Unix Zeit, leer = jetzt
+	Stop     string   `json:"stop,omitempty"`     // Nur epg.grid, leer = Start + 3 Stunden
+	Query    string   `json:"query,omitempty"`    // Nur epg.search
+	Limit    int      `json:"limit,omitempty"`    // Nur epg.search, leer = 100
+}
+
+// EPGChannelGuide : Programme eines Kanals
+type EPGChannelGuide struct {
+	Channel    string         `json:"channel"`
+	Name       string         `json:"name"`
+	Now        *EPGProgramme  `json:"now,omitempty"`
+	Next       *EPGProgramme  `json:"next,omitempty"`
+	Programmes []EPGProgramme `json:"programmes,omitempty"`
+}
+
+// EPGProgramme : Sendung (Zeiten als Unix Zeit)
+type EPGProgramme struct {
+	Channel    string   `json:"channel"`
+	Start      int64    `json:"start"`
+	Stop       int64    `json:"stop"`
+	Title      string   `json:"title"`
+	SubTitle   string   `json:"sub-title,omitempty"`
+	Desc       string   `json:"desc,omitempty"`
+	Category   []string `json:"category,omitempty"`
+	Icon       string   `json:"icon,omitempty"`
+	EpisodeNum string   `json:"episode-num,omitempty"`
+}
+
+// EPGCandidate : XMLTV Kanal mit Bewertung (0 - 1)
+type EPGCandidate struct {
+	File        string  `json:"x-xmltv-file"`
+	Mapping     string  `json:"x-mapping"`
+	DisplayName string  `json:"display-name"`
+	Score       float64 `json:"score"`
+	Method      string  `json:"method"`
+}
+
+// VirtualDevice : Virtueller HDHomeRun Tuner mit eigener Geräte ID und eigenem Lineup (settings.json)
+type VirtualDevice struct {
+	Active   bool     `json:"active"`
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	DeviceID string   `json:"deviceID"`
+	Tuner    int      `json:"tuner"`
+	Port     string   `json:"port,omitempty"`
+	Groups   []string `json:"groups"`
+	Filters  []int64  `json:"filters"`
+	Channels []string `json:"channels"`
+}
+
+// HDHRTuner : HDHomeRun Tuner im Netzwerk (Discovery)
+type HDHRTuner struct {
+	DeviceID   string `json:"deviceID"`
+	Name       string `json:"name"`
+	Model      string `json:"model"`
+	IP         string `json:"ip"`
+	BaseURL    string `json:"baseURL"`
+	LineupURL  string `json:"lineupURL"`
+	TunerCount int    `json:"tunerCount"`
+	PlaylistID string `json:"playlistID,omitempty"`
+}
+
+// RenumberPlan : Alte und neue Kanalnummern, wird erst mit apply übernommen
+type RenumberPlan struct {
+	Applied   bool               `json:"applied"`
+	Changes   []RenumberChange   `json:"changes"`
+	Conflicts []RenumberConflict `json:"conflicts"`
+}
+
+// RenumberChange : Neue Kanalnummer eines XEPG Kanals
+type RenumberChange struct {
+	XEPG   string `json:"xepg"`
+	Name   string `json:"x-name"`
+	Group  string `json:"x-group-title"`
+	Filter string `json:"filter"`
+	Old    string `json:"old"`
+	New    string `json:"new"`
+}
+
+// RenumberConflict : Kanalnummer, die mehrfach vergeben ist oder nicht in den reservierten Block passt
+type RenumberConflict struct {
+	Number   string   `json:"number"`
+	Reason   string   `json:"reason"`
+	Channels []string `json:"channels"`
+}
+
+// RewriteRule : Umschreibregel für Kanalname, Gruppe, tvg-id und Logo (settings.json)
+type RewriteRule struct {
+	Active        bool   `json:"active"`
+	Name          string `json:"name"`
+	Type          string `json:"type"`
+	Field         string `json:"field"`
+	Pattern       string `json:"pattern"`
+	Value         string `json:"value"`
+	CaseSensitive bool   `json:"caseSensitive"`
+}
+
+// RewritePreview : Ergebnis der Vorschau der Umschreibregeln, wird nicht gespeichert
+type RewritePreview struct {
+	Total    int                     `json:"total"`
+	Changed  int                     `json:"changed"`
+	Channels []RewritePreviewChannel `json:"channels"`
+}
+
+// RewritePreviewChannel : Werte eines Streams vor und nach den Umschreibregeln
+type RewritePreviewChannel struct {
+	Playlist string        `json:"playlist"`
+	Old      RewriteValues `json:"old"`
+	New      RewriteValues `json:"new"`
+}
+
+// RewriteValues : Umschreibbare Werte eines Kanals
+type RewriteValues struct {
+	Name  string `json:"name"`
+	Group string `json:"group"`
+	TvgID string `json:"tvg-id"`
+	Logo  string `json:"logo"`
+}
+
+// FilterPreview : Ergebnis der Filter Vorschau, wird nicht gespeichert
+type FilterPreview struct {
+	Active   int                    `json:"active"`
+	Inactive int                    `json:"inactive"`
+	Added    []FilterPreviewChannel `json:"added"`
+	Removed  []FilterPreviewChannel `json:"removed"`
+	Filters  []FilterPreviewFilter  `json:"filters"`
+}
+
+// FilterPreviewFilter : Streams, über die ein Filter entscheidet
+type FilterPreviewFilter struct {
+	ID         int64                  `json:"id"`
+	Name       string                 `json:"name"`
+	Action     string                 `json:"action"`
+	Priority   int                    `json:"priority"`
+	Expression string                 `json:"expression"`
+	Error      string                 `json:"error,omitempty"`
+	Include    []FilterPreviewChannel `json:"include"`
+	Exclude    []FilterPreviewChannel `json:"exclude"`
+}
+
+// FilterPreviewChannel : Stream mit dem Teil des Ausdrucks, der entschieden hat
+type FilterPreviewChannel struct {
+	Name     string `json:"name"`
+	Group    string `json:"group"`
+	TvgID    string `json:"tvg-id,omitempty"`
+	Playlist string `json:"playlist,omitempty"`
+	Filter   string `json:"filter,omitempty"`
+	Reason   string `json:"reason"`
+}
+
 // StreamingURLS : Informationen zu allen streaming URL's
 type StreamingURLS struct {
 	Streams map[string]StreamInfo `json:"channels,required"`
@@ -294,6 +644,7 @@ type SettingsStruct struct {
 	AuthenticationWEB bool     `json:"authentication.web"`
 	AuthenticationXML bool     `json:"authentication.xml"`
 	BackupKeep        int      `json:"backup.keep"`
+	ChangesKeep       int      `json:"changes.keep"`
 	BackupPath        string   `json:"backup.path"`
 	Branch            string   `json:"git.branch,omitempty"`
 	Buffer            string   `json:"buffer"`
@@ -323,10 +674,12 @@ type SettingsStruct struct {
 	M3U8AdaptiveBandwidthMBPS int                   `json:"m3u8.adaptive.bandwidth.mbps"`
 	MappingFirstChannel       float64               `json:"mapping.first.channel"`
 	Port                      string                `json:"port"`
+	Rewrite                   []RewriteRule         `json:"rewrite"`
 	SSDP                      bool                  `json:"ssdp"`
 	TempPath                  string                `json:"temp.path"`
 	Tuner                     int                   `json:"tuner"`
 	Update                    []string              `json:"update"`
+	UpdateDeferMax            int                   `json:"update.defer.max"`
 	UpdateURL                 string                `json:"update.url,omitempty"`
 	UserAgent                 string                `json:"user.agent"`
 	UUID                      string                `json:"uuid"`
@@ -346,6 +699,24 @@ type SettingsStruct struct {
 	EpgCategoriesColors       string                `json:"epgCategoriesColors"`
 	Dummy                     bool                  `json:"dummy"`
 	DummyChannel              string                `json:"dummyChannel"`
+	DummyTemplates            []DummyTemplate       `json:"dummy.templates"`
+	Devices                   []VirtualDevice       `json:"devices"`
+	LineupShards              bool                  `json:"lineup.shards"`
+	HDHRDeviceID              string                `json:"hdhr.deviceID"`
+	HDHRDiscovery             []string              `json:"hdhr.discovery"`
+	DLNA                      bool                  `json:"dlna"`
+	XMLTVIndexDisk            bool                  `json:"xmltv.index.disk"`
+	EPGWindowPast             int                   `json:"epg.window.past"`
+	EPGWindowFuture           int                   `json:"epg.window.future"`
+	EPGMatchNames             bool                  `json:"epg.match.names"`
+	EventDuration             int                   `json:"event.duration"`
+	EventRules                []EventTimeRule       `json:"event.rules"`
+	PPVAuto                   bool                  `json:"ppv.auto"`
+	PPVLead                   int                   `json:"ppv.lead"`
+	PPVLag                    int                   `json:"ppv.lag"`
+	GenreNormalize            string                `json:"genre.normalize"`
+	GenreInfer                bool                  `json:"genre.infer"`
+	GenreRules                []GenreRule           `json:"genre.rules"`
 	IgnoreFilters             bool                  `json:"ignoreFilters"`
 	OneRequestPerTuner        bool                  `json:"oneRequestPerTuner"`
 }
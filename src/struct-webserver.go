@@ -15,6 +15,9 @@ type RequestStruct struct {
 	// Restore
 	Base64 string `json:"base64,omitempty"`
 
+	// Hintergrundprozesse
+	JobID string `json:"jobID,omitempty"`
+
 	// Neue Werte für die Einstellungen (settings.json)
 	Settings struct {
 		API                      *bool     `json:"api,omitempty"`
@@ -26,6 +29,7 @@ type RequestStruct struct {
 		AuthenticationXML        *bool     `json:"authentication.xml,omitempty"`
 		BackupKeep               *int      `json:"backup.keep,omitempty"`
 		BackupPath               *string   `json:"backup.path,omitempty"`
+		ChangesKeep              *int      `json:"changes.keep,omitempty"`
 		Buffer                   *string   `json:"buffer,omitempty"`
 		BufferSize               *int      `json:"buffer.size.kb,omitempty"`
 		BufferTimeout            *float64  `json:"buffer.timeout,omitempty"`
@@ -41,6 +45,7 @@ type RequestStruct struct {
 		Tuner                    *int      `json:"tuner,omitempty"`
 		UDPxy                    *string   `json:"udpxy,omitempty"`
 		Update                   *[]string `json:"update,omitempty"`
+		UpdateDeferMax           *int      `json:"update.defer.max,omitempty"`
 		UserAgent                *string   `json:"user.agent,omitempty"`
 		XepgReplaceMissingImages *bool     `json:"xepg.replace.missing.images,omitempty"`
 		XepgReplaceChannelTitle  *bool     `json:"xepg.replace.channel.title,omitempty"`
@@ -59,6 +64,19 @@ type RequestStruct struct {
 		Dummy                    *bool     `json:"dummy,omitempty"`
 		DummyChannel             *string   `json:"dummyChannel,omitempty"`
 		IgnoreFilters            *bool     `json:"ignoreFilters,omitempty"`
+		LineupShards             *bool     `json:"lineup.shards,omitempty"`
+		HDHRDiscovery            *[]string `json:"hdhr.discovery,omitempty"`
+		DLNA                     *bool     `json:"dlna,omitempty"`
+		XMLTVIndexDisk           *bool     `json:"xmltv.index.disk,omitempty"`
+		EPGWindowPast            *int      `json:"epg.window.past,omitempty"`
+		EPGWindowFuture          *int      `json:"epg.window.future,omitempty"`
+		EPGMatchNames            *bool     `json:"epg.match.names,omitempty"`
+		EventDuration            *int      `json:"event.duration,omitempty"`
+		PPVAuto                  *bool     `json:"ppv.auto,omitempty"`
+		PPVLead                  *int      `json:"ppv.lead,omitempty"`
+		PPVLag                   *int      `json:"ppv.lag,omitempty"`
+		GenreNormalize           *string   `json:"genre.normalize,omitempty"`
+		GenreInfer               *bool     `json:"genre.infer,omitempty"`
 		OneRequestPerTuner       *bool     `json:"oneRequestPerTuner,omitempty"`
 	} `json:"settings,omitempty"`
 
@@ -68,6 +86,40 @@ type RequestStruct struct {
 	// Filter
 	Filter map[int64]interface{} `json:"filter,omitempty"`
 
+	// Umschreibregeln
+	Rewrite []RewriteRule `json:"rewrite,omitempty"`
+
+	// Dummy Vorlagen
+	DummyTemplates []DummyTemplate `json:"dummyTemplates,omitempty"`
+
+	// Regeln für die Startzeit von Live Events
+	EventRules []EventTimeRule `json:"eventRules,omitempty"`
+
+	// Genre Regeln
+	GenreRules []GenreRule `json:"genreRules,omitempty"`
+
+	// Virtuelle Geräte
+	Devices []VirtualDevice `json:"devices,omitempty"`
+
+	// HDHomeRun Tuner importieren (Geräte IDs)
+	Tuners []string `json:"tuners,omitempty"`
+
+	// EPG Vorschläge für einen Kanal (leer = alle Kanäle ohne EPG)
+	XEPG string `json:"x-epg,omitempty"`
+
+	// EPG Abfragen (Jetzt / Danach, Zeitraster, Suche)
+	EPG EPGQuery `json:"epg,omitempty"`
+
+	// Manuelle Sendungen des Kanals x-epg
+	Programs []ManualProgram `json:"programs,omitempty"`
+
+	// Kanalnummern neu vergeben
+	Renumber struct {
+		Filter string `json:"filter"`
+		Policy string `json:"policy"`
+		Apply  bool   `json:"apply"`
+	} `json:"renumber,omitempty"`
+
 	// Dateien (M3U, HDHR, XMLTV)
 	Files struct {
 		HDHR  map[string]interface{} `json:"hdhr,omitempty"`
@@ -128,6 +180,8 @@ type ResponseStruct struct {
 	Alert               string                 `json:"alert,omitempty"`
 	ConfigurationWizard bool                   `json:"configurationWizard,required"`
 	Error               string                 `json:"err,omitempty"`
+	Jobs                []JobStruct            `json:"jobs,omitempty"`
+	PPV                 []PPVChannel           `json:"ppv,omitempty"`
 	Log                 WebScreenLogStruct     `json:"log,required"`
 	LogoURL             string                 `json:"logoURL,omitempty"`
 	OpenLink            string                 `json:"openLink,omitempty"`
@@ -140,15 +194,30 @@ type ResponseStruct struct {
 	Wizard              int                    `json:"wizard,omitempty"`
 	XEPG                map[string]interface{} `json:"xepg,required"`
 	ProbeInfo           ProbeInfoStruct        `json:"probeInfo,omitempty"`
+	FilterPreview       *FilterPreview         `json:"filterPreview,omitempty"`
+	RewritePreview      *RewritePreview        `json:"rewritePreview,omitempty"`
+	Renumber            *RenumberPlan          `json:"renumber,omitempty"`
+	Tuners              []HDHRTuner            `json:"tuners,omitempty"`
+	Suggestions         []EPGSuggestion        `json:"suggestions,omitempty"`
+	EPG                 []EPGChannelGuide      `json:"epg,omitempty"`
+	Programmes          []EPGProgramme         `json:"programmes,omitempty"`
+	ManualPrograms      map[string][]ManualProgram `json:"programs,omitempty"`
 	SystemStats         SystemStatsStruct      `json:"systemStats,omitempty"`
 
 	Notification map[string]Notification `json:"notification,omitempty"`
 }
 
+// JobMessage : Statusänderung eines Hintergrundprozesses (Websocket Push)
+type JobMessage struct {
+	Cmd string    `json:"cmd"`
+	Job JobStruct `json:"job"`
+}
+
 type ProbeInfoStruct struct {
 	Resolution   string `json:"resolution,omitempty"`
 	FrameRate    string `json:"frameRate,omitempty"`
 	AudioChannel string `json:"audioChannel,omitempty"`
+	Time         string `json:"time,omitempty"`
 }
 
 // SystemStatsStruct : System monitoring information
@@ -220,26 +289,58 @@ type BufferStatusInfo struct {
 
 // APIRequestStruct : Anfrage über die API Schnittstelle
 type APIRequestStruct struct {
-	Cmd      string `json:"cmd"`
-	Password string `json:"password"`
-	Token    string `json:"token"`
-	Username string `json:"username"`
+	Apply    bool                  `json:"apply,omitempty"`
+	Cmd      string                `json:"cmd"`
+	EPG      EPGQuery              `json:"epg,omitempty"`
+	Programs []ManualProgram       `json:"programs,omitempty"`
+	Filter   map[int64]interface{} `json:"filter,omitempty"`
+	ID       string                `json:"id,omitempty"`
+	Name     string                `json:"name,omitempty"`
+	Password string                `json:"password"`
+	Policy   string                `json:"policy,omitempty"`
+	Rewrite  []RewriteRule         `json:"rewrite,omitempty"`
+	Templates []DummyTemplate      `json:"templates,omitempty"`
+	Events   []EventTimeRule       `json:"events,omitempty"`
+	Genres   []GenreRule           `json:"genres,omitempty"`
+	Token    string                `json:"token"`
+	Tuners   []string              `json:"tuners,omitempty"`
+	Username string                `json:"username"`
 }
 
 // APIResponseStruct : Antwort an den Client (API)
 type APIResponseStruct struct {
-	EpgSource        string `json:"epg.source,omitempty"`
-	Error            string `json:"err,omitempty"`
-	Status           bool   `json:"status,required"`
-	StreamsActive    int64  `json:"streams.active,omitempty"`
-	StreamsAll       int64  `json:"streams.all,omitempty"`
-	StreamsXepg      int64  `json:"streams.xepg,omitempty"`
-	Token            string `json:"token,omitempty"`
-	URLDvr           string `json:"url.dvr,omitempty"`
-	URLM3U           string `json:"url.m3u,omitempty"`
-	URLXepg          string `json:"url.xepg,omitempty"`
-	VersionAPI       string `json:"version.api,omitempty"`
-	VersionThreadfin string `json:"version.threadfin,omitempty"`
+	Changes          []PlaylistChangeReport `json:"changes,omitempty"`
+	Devices          []VirtualDevice        `json:"devices,omitempty"`
+	DummyTemplates   []DummyTemplate        `json:"dummy.templates,omitempty"`
+	EpgSource        string                 `json:"epg.source,omitempty"`
+	Event            *EventTimeResult       `json:"event,omitempty"`
+	EventRules       []EventTimeRule        `json:"event.rules,omitempty"`
+	Error            string                 `json:"err,omitempty"`
+	GenreRules       []GenreRule            `json:"genre.rules,omitempty"`
+	FilterPreview    *FilterPreview         `json:"filter.preview,omitempty"`
+	Jobs             []JobStruct            `json:"jobs,omitempty"`
+	Matches          []ChannelMatch         `json:"matches,omitempty"`
+	PPV              []PPVChannel           `json:"ppv,omitempty"`
+	Provenance       []EPGProvenance        `json:"provenance,omitempty"`
+	Suggestions      []EPGSuggestion        `json:"suggestions,omitempty"`
+	EPG              []EPGChannelGuide      `json:"epg,omitempty"`
+	Programmes       []EPGProgramme         `json:"programmes,omitempty"`
+	ManualPrograms   map[string][]ManualProgram `json:"programs,omitempty"`
+	Renumber         *RenumberPlan          `json:"renumber,omitempty"`
+	Rewrite          []RewriteRule          `json:"rewrite,omitempty"`
+	RewritePreview   *RewritePreview        `json:"rewrite.preview,omitempty"`
+	Schedule         []ScheduleJob          `json:"schedule,omitempty"`
+	Status           bool                   `json:"status,required"`
+	StreamsActive    int64                  `json:"streams.active,omitempty"`
+	StreamsAll       int64                  `json:"streams.all,omitempty"`
+	StreamsXepg      int64                  `json:"streams.xepg,omitempty"`
+	Token            string                 `json:"token,omitempty"`
+	Tuners           []HDHRTuner            `json:"tuners,omitempty"`
+	URLDvr           string                 `json:"url.dvr,omitempty"`
+	URLM3U           string                 `json:"url.m3u,omitempty"`
+	URLXepg          string                 `json:"url.xepg,omitempty"`
+	VersionAPI       string                 `json:"version.api,omitempty"`
+	VersionThreadfin string                 `json:"version.threadfin,omitempty"`
 }
 
 // WebScreenLogStruct : Logs werden im RAM gespeichert und für das Webinterface bereitgestellt
@@ -8,6 +8,8 @@ import (
 	"reflect"
 	"strings"
 	"time"
+
+	"threadfin/src/internal/hdhomerun"
 )
 
 // Entwicklerinfos anzeigen
@@ -80,6 +82,16 @@ func createSystemFiles() (err error) {
 			System.File.XEPG = filename
 		case "urls.json":
 			System.File.URLS = filename
+		case "changes.json":
+			System.File.Changes = filename
+		case "matches.json":
+			System.File.Matches = filename
+		case "probe.json":
+			System.File.Probe = filename
+		case "shards.json":
+			System.File.Shards = filename
+		case "programs.json":
+			System.File.Programs = filename
 
 		}
 
@@ -139,6 +151,24 @@ func loadSettings() (settings SettingsStruct, err error) {
 	defaults["files"] = dataMap
 	defaults["files.update"] = true
 	defaults["filter"] = make(map[string]interface{})
+	defaults["rewrite"] = make([]interface{}, 0)
+	defaults["dummy.templates"] = make([]interface{}, 0)
+	defaults["devices"] = make([]interface{}, 0)
+	defaults["lineup.shards"] = false
+	defaults["hdhr.discovery"] = make([]string, 0)
+	defaults["dlna"] = false
+	defaults["xmltv.index.disk"] = true
+	defaults["epg.window.past"] = 0
+	defaults["epg.window.future"] = 0
+	defaults["epg.match.names"] = true
+	defaults["event.duration"] = 0
+	defaults["event.rules"] = make([]interface{}, 0)
+	defaults["ppv.auto"] = false
+	defaults["ppv.lead"] = 30
+	defaults["ppv.lag"] = 180
+	defaults["genre.normalize"] = "off"
+	defaults["genre.infer"] = false
+	defaults["genre.rules"] = make([]interface{}, 0)
 	defaults["git.branch"] = System.Branch
 	defaults["language"] = "en"
 	defaults["log.entries.ram"] = 500
@@ -164,6 +194,8 @@ func loadSettings() (settings SettingsStruct, err error) {
 	defaults["tuner"] = 1
 	defaults["oneRequestPerTuner"] = false
 	defaults["update"] = []string{"0000"}
+	defaults["update.defer.max"] = 120
+	defaults["changes.keep"] = 20
 	defaults["user.agent"] = System.Name
 	defaults["uuid"] = createUUID()
 	defaults["udpxy"] = ""
@@ -213,6 +245,12 @@ func loadSettings() (settings SettingsStruct, err error) {
 
 	settings.Version = System.DBVersion
 
+	// Geräte ID mit gültiger Prüfsumme für die binäre HDHomeRun Discovery. Wird einmal erstellt und gespeichert,
+	// discover.json, device.xml und SSDP verwenden weiterhin die bisherige ID (System.DeviceID).
+	if len(settings.HDHRDeviceID) == 0 {
+		settings.HDHRDeviceID = hdhomerun.FormatDeviceID(hdhomerun.DeviceID(settings.UUID))
+	}
+
 	err = saveSettings(settings)
 	if err != nil {
 		return SettingsStruct{}, err
@@ -346,7 +384,6 @@ func setDeviceID() {
 func createStreamingURL(streamingType, playlistID, channelNumber, channelName, url string, backup_channel_1 *BackupStream, backup_channel_2 *BackupStream, backup_channel_3 *BackupStream) (streamingURL string, err error) {
 
 	var streamInfo StreamInfo
-	var serverProtocol string
 
 	if len(Data.Cache.StreamingURLS) == 0 {
 		Data.Cache.StreamingURLS = make(map[string]StreamInfo)
@@ -371,6 +408,15 @@ func createStreamingURL(streamingType, playlistID, channelNumber, channelName, u
 
 	}
 
+	streamingURL = streamingBaseURL(streamingType) + "/stream/" + streamInfo.URLid
+	return
+}
+
+// Protokoll und Domain der Streaming URLs
+func streamingBaseURL(streamingType string) string {
+
+	var serverProtocol string
+
 	switch streamingType {
 
 	case "DVR":
@@ -388,8 +434,7 @@ func createStreamingURL(streamingType, playlistID, channelNumber, channelName, u
 		}
 	}
 
-	streamingURL = fmt.Sprintf("%s://%s/stream/%s", serverProtocol, System.Domain, streamInfo.URLid)
-	return
+	return fmt.Sprintf("%s://%s", serverProtocol, System.Domain)
 }
 
 func getStreamInfo(urlID string) (streamInfo StreamInfo, err error) {
@@ -0,0 +1,219 @@
+package src
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"threadfin/src/internal/hdhomerun"
+)
+
+// HDHomeRun Tuner im LAN. Die Suche sendet die binäre Discovery Anfrage an die Broadcast Adresse
+// und an die Adressen in hdhr.discovery (host[:port], für Netzwerke ohne Broadcast).
+// Importierte Tuner sind normale HDHR Playlisten mit der Geräte ID in device.id, die Adresse (file.source)
+// wird einmal pro Aktualisierung der HDHR Playlisten aktualisiert.
+
+const hdhrDiscoveryTimeout = 2 * time.Second
+
+// discoverHDHRTuners : Tuner im LAN mit den Werten aus discover.json, die eigenen Geräte von Threadfin werden übersprungen
+func discoverHDHRTuners() (tuners []HDHRTuner, err error) {
+
+	var addresses = []string{net.JoinHostPort("255.255.255.255", strconv.Itoa(hdhomerun.Port))}
+	for _, address := range Settings.HDHRDiscovery {
+
+		if _, _, errSplit := net.SplitHostPort(address); errSplit != nil {
+			address = net.JoinHostPort(address, strconv.Itoa(hdhomerun.Port))
+		}
+
+		addresses = append(addresses, address)
+
+	}
+
+	devices, err := hdhomerun.Discover(addresses, hdhrDiscoveryTimeout)
+	if err != nil {
+		return
+	}
+
+	var own = make(map[uint32]bool)
+	for _, device := range discoveryDevices() {
+		own[device.ID] = true
+	}
+
+	var client = &http.Client{Timeout: 5 * time.Second}
+	var imported = importedHDHRTuners()
+
+	for _, device := range devices {
+
+		if own[device.ID] {
+			continue
+		}
+
+		var tuner = HDHRTuner{
+			DeviceID:   hdhomerun.FormatDeviceID(device.ID),
+			IP:         device.IP.String(),
+			BaseURL:    device.BaseURL,
+			LineupURL:  device.LineupURL,
+			TunerCount: device.TunerCount,
+		}
+
+		// Ältere Modelle senden keine Base URL
+		if len(tuner.BaseURL) == 0 {
+			tuner.BaseURL = "http://" + tuner.IP
+		}
+
+		info, errInfo := hdhomerun.FetchInfo(client, tuner.BaseURL)
+		if errInfo != nil {
+			ShowError(errInfo, 0)
+		} else {
+			tuner.Name = info.FriendlyName
+			tuner.Model = info.ModelNumber
+			tuner.LineupURL = info.LineupURL
+			tuner.TunerCount = max(info.TunerCount, tuner.TunerCount)
+		}
+
+		if len(tuner.Name) == 0 {
+			tuner.Name = "HDHomeRun " + tuner.DeviceID
+		}
+
+		tuner.PlaylistID = imported[tuner.DeviceID]
+		tuners = append(tuners, tuner)
+
+	}
+
+	sort.Slice(tuners, func(i, j int) bool { return tuners[i].DeviceID < tuners[j].DeviceID })
+
+	showInfo(fmt.Sprintf("HDHomeRun:Found %d tuners", len(tuners)))
+
+	return
+}
+
+// importHDHRTuners : Fügt die ausgewählten Tuner als HDHR Playlisten hinzu, bereits importierte Tuner erhalten die neue Adresse und Anzahl der Tuner
+func importHDHRTuners(deviceIDs []string) (settings SettingsStruct, err error) {
+
+	tuners, err := discoverHDHRTuners()
+	if err != nil {
+		return
+	}
+
+	var found = make(map[string]HDHRTuner)
+	for _, tuner := range tuners {
+		found[tuner.DeviceID] = tuner
+	}
+
+	for _, deviceID := range deviceIDs {
+
+		tuner, ok := found[deviceID]
+		if !ok {
+			err = fmt.Errorf("%s: %s", getErrMsg(1029), deviceID)
+			return
+		}
+
+		var request RequestStruct
+		var playlistID = tuner.PlaylistID
+		var data = map[string]interface{}{
+			"file.source": tunerSource(tuner),
+			"tuner":       float64(tuner.TunerCount),
+			"device.id":   tuner.DeviceID,
+		}
+
+		if len(playlistID) == 0 {
+			playlistID = "-"
+			data["name"] = tuner.Name
+		}
+
+		request.Files.HDHR = map[string]interface{}{playlistID: data}
+
+		err = saveFiles(request, "hdhr")
+		if err != nil {
+			return
+		}
+
+		showInfo(fmt.Sprintf("HDHomeRun:Imported %s (%s, %d tuners)", tuner.Name, tuner.DeviceID, tuner.TunerCount))
+
+	}
+
+	settings = Settings
+
+	return
+}
+
+// updateHDHRAddresses : Sucht die importierten Tuner im LAN und aktualisiert Adresse und Anzahl der Tuner, falls sie sich geändert haben.
+// Läuft einmal pro Aktualisierung (Job hdhr.addresses des Zeitplans) und vor einer vollständigen Aktualisierung aller Playlisten.
+func updateHDHRAddresses() (err error) {
+
+	var imported = importedHDHRTuners()
+	if len(imported) == 0 {
+		return
+	}
+
+	tuners, err := discoverHDHRTuners()
+	if err != nil {
+		return
+	}
+
+	var changed bool
+
+	for _, tuner := range tuners {
+
+		data, ok := Settings.Files.HDHR[imported[tuner.DeviceID]].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if count, _ := data["tuner"].(float64); tuner.TunerCount > 0 && int(count) != tuner.TunerCount {
+
+			showInfo(fmt.Sprintf("HDHomeRun:%s has %d tuners", tuner.DeviceID, tuner.TunerCount))
+
+			data["tuner"] = float64(tuner.TunerCount)
+			changed = true
+
+		}
+
+		var source = tunerSource(tuner)
+		if data["file.source"] == source {
+			continue
+		}
+
+		showInfo(fmt.Sprintf("HDHomeRun:%s has a new address: %s -> %s", tuner.DeviceID, data["file.source"], source))
+
+		data["file.source"] = source
+		changed = true
+
+	}
+
+	if changed {
+		err = saveSettings(Settings)
+	}
+
+	return
+}
+
+// importedHDHRTuners : Geräte ID -> Playlist ID
+func importedHDHRTuners() (imported map[string]string) {
+
+	imported = make(map[string]string)
+
+	for id, d := range Settings.Files.HDHR {
+		if data, ok := d.(map[string]interface{}); ok {
+			if deviceID, ok := data["device.id"].(string); ok && len(deviceID) > 0 {
+				imported[deviceID] = id
+			}
+		}
+	}
+
+	return
+}
+
+// tunerSource : host[:port] des Tuners für file.source, provider.go lädt http://<file.source>/lineup.json
+func tunerSource(tuner HDHRTuner) string {
+
+	if u, err := url.Parse(tuner.BaseURL); err == nil && len(u.Host) > 0 {
+		return u.Host
+	}
+
+	return tuner.IP
+}
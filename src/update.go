@@ -294,6 +294,21 @@ checkVersion:
 			}
 
 		case "2.1.0":
+			break
+
+		case "0.5.0":
+			// Filter Ausdrücke (filterexpr). Die alten {include} / !{exclude} Regeln bleiben gespeichert und werden
+			// beim Erstellen der Filter umgewandelt (compileFilter), Änderungen im Webinterface bleiben so wirksam.
+			settingsMap["version"] = "0.6.0"
+
+			err = saveMapToJSONFile(System.File.Settings, settingsMap)
+			if err != nil {
+				return
+			}
+
+			goto checkVersion
+
+		case "0.6.0":
 			// Falls es in einem späteren Update Änderungen an der Datenbank gibt, geht es hier weiter
 
 			break
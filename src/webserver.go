@@ -11,6 +11,7 @@ import (
 	"net/url"
 	"os"
 	"path"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
@@ -21,6 +22,8 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+var tunerPath = regexp.MustCompile(`^/(?:auto|tuner(\d+))/v([^/]+)$`)
+
 // Rate limiting for updateLog requests to reduce noise
 var (
 	updateLogMutex sync.RWMutex
@@ -50,6 +53,10 @@ func StartWebserver() (err error) {
 	http.HandleFunc("/ppv/enable", enablePPV)
 	http.HandleFunc("/ppv/disable", disablePPV)
 	http.HandleFunc("/auto/", Auto)
+	http.HandleFunc("/devices/", Devices)
+	http.HandleFunc("/dlna/", DLNA)
+
+	startVirtualDevices()
 
 	systemMutex.Lock()
 	ips := len(System.IPAddressesV4) + len(System.IPAddressesV6) - 1
@@ -73,8 +80,6 @@ func StartWebserver() (err error) {
 
 // Index : Web Server /
 func Index(w http.ResponseWriter, r *http.Request) {
-	var err error
-	var response []byte
 	var path = r.URL.Path
 
 	systemMutex.Lock()
@@ -85,9 +90,17 @@ func Index(w http.ResponseWriter, r *http.Request) {
 	}
 	systemMutex.Unlock()
 
+	serveHDHR(w, r, nil, path)
+}
+
+// serveHDHR : HDHomeRun Dateien des Hauptgeräts (nil) oder eines virtuellen Geräts
+func serveHDHR(w http.ResponseWriter, r *http.Request, device *VirtualDevice, path string) {
+	var err error
+	var response []byte
+
 	switch path {
 	case "/discover.json":
-		response, err = getDiscover()
+		response, err = getDiscover(device)
 		w.Header().Set("Content-Type", "application/json")
 	case "/lineup_status.json":
 		response, err = getLineupStatus()
@@ -105,13 +118,35 @@ func Index(w http.ResponseWriter, r *http.Request) {
 		} else {
 			systemMutex.Unlock()
 		}
-		response, err = getLineup()
+		response, err = getLineup(device)
+		w.Header().Set("Content-Type", "application/json")
+	case "/status.json":
+		response, err = getTunerStatus(device)
 		w.Header().Set("Content-Type", "application/json")
+	case "/lineup.post":
+		if r.Method != http.MethodPost {
+			httpStatusError(w, r, 405)
+			return
+		}
+		switch r.URL.Query().Get("scan") {
+		case "start":
+			startLineupScan()
+		case "abort":
+			abortLineupScan()
+		default:
+			httpStatusError(w, r, 400)
+			return
+		}
 	case "/device.xml", "/capability":
-		response, err = getCapability()
+		response, err = getCapability(device)
 		w.Header().Set("Content-Type", "application/xml")
 	default:
-		response, err = getCapability()
+		// /auto/v<number> und /tuner<n>/v<number>
+		if match := tunerPath.FindStringSubmatch(path); match != nil {
+			serveTuner(w, r, device, match[1], match[2])
+			return
+		}
+		response, err = getCapability(device)
 		w.Header().Set("Content-Type", "application/xml")
 	}
 
@@ -125,9 +160,32 @@ func Index(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+// serveTuner : Stream eines Senders über die Sendernummer, tuner ist leer bei /auto/
+func serveTuner(w http.ResponseWriter, r *http.Request, device *VirtualDevice, tuner, guideNumber string) {
+
+	if len(tuner) > 0 {
+		var _, _, _, tunerCount = deviceInfo(device)
+		if n, err := strconv.Atoi(tuner); err != nil || n >= tunerCount {
+			httpStatusError(w, r, 404)
+			return
+		}
+	}
+
+	urlID, ok := getLineupStream(device, guideNumber)
+	if !ok {
+		httpStatusError(w, r, 404)
+		return
+	}
+
+	serveStream(w, r, urlID)
+}
+
 // Stream : Web Server /stream/
 func Stream(w http.ResponseWriter, r *http.Request) {
-	var path = strings.Replace(r.RequestURI, "/stream/", "", 1)
+	serveStream(w, r, strings.Replace(r.RequestURI, "/stream/", "", 1))
+}
+
+func serveStream(w http.ResponseWriter, r *http.Request, path string) {
 	streamInfo, err := getStreamInfo(path)
 	if err != nil {
 		ShowError(err, 1203)
@@ -247,11 +305,9 @@ func Stream(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
-// Auto : HDHR routing (wird derzeit nicht benutzt)
+// Auto : Web Server /auto/v<Sendernummer>
 func Auto(w http.ResponseWriter, r *http.Request) {
-	var channelID = strings.Replace(r.RequestURI, "/auto/v", "", 1)
-	fmt.Println(channelID)
-	return
+	Index(w, r)
 }
 
 // Threadfin : Web Server /xmltv/ und /m3u/
@@ -433,6 +489,10 @@ func WS(w http.ResponseWriter, r *http.Request) {
 	connID := fmt.Sprintf("conn_%d", time.Now().UnixNano())
 	showDebug("WebSocket: Connection "+connID+" established", 3)
 
+	// Writes are shared with the job status push (broadcastWebSocket)
+	var client = &wsClient{conn: conn}
+	defer wsClients.Delete(connID)
+
 	systemMutex.Lock()
 	if Settings.HttpThreadfinDomain != "" {
 		setGlobalDomain(getBaseUrl(Settings.HttpThreadfinDomain, Settings.Port))
@@ -485,7 +545,7 @@ func WS(w http.ResponseWriter, r *http.Request) {
 					response.Error = err.Error()
 					request.Cmd = "-"
 
-					if err = conn.WriteJSON(response); err != nil {
+					if err = client.writeJSON(response); err != nil {
 						ShowError(err, 1102)
 					}
 
@@ -509,6 +569,9 @@ func WS(w http.ResponseWriter, r *http.Request) {
 		}
 		systemMutex.Unlock()
 
+		// Authenticated connections receive the job status updates
+		wsClients.Store(connID, client)
+
 		switch request.Cmd {
 		// Data read commands
 		case "getServerConfig":
@@ -531,7 +594,7 @@ func WS(w http.ResponseWriter, r *http.Request) {
 			}
 
 			response = setDefaultResponseData(response, false)
-			if err = conn.WriteJSON(response); err != nil {
+			if err = client.writeJSON(response); err != nil {
 				ShowError(err, 1022)
 			} else {
 				return
@@ -636,6 +699,66 @@ func WS(w http.ResponseWriter, r *http.Request) {
 				response.OpenMenu = strconv.Itoa(indexOfString("filter", System.WEB.Menu))
 			}
 
+		case "previewFilter":
+			response.FilterPreview = previewFilter(request.Filter)
+
+		case "saveDevices":
+			response.Settings, err = saveVirtualDevices(request.Devices)
+
+		case "discoverTuners":
+			response.Tuners, err = discoverHDHRTuners()
+
+		case "importTuners":
+			response.Settings, err = importHDHRTuners(request.Tuners)
+			if err == nil {
+				response.OpenMenu = strconv.Itoa(indexOfString("playlist", System.WEB.Menu))
+			}
+
+		case "epgNowNext":
+			response.EPG, _, err = epgQuery("epg.nownext", request.EPG)
+
+		case "epgGrid":
+			response.EPG, _, err = epgQuery("epg.grid", request.EPG)
+
+		case "epgSearch":
+			_, response.Programmes, err = epgQuery("epg.search", request.EPG)
+
+		case "getPrograms":
+			response.ManualPrograms, err = getManualPrograms(request.XEPG)
+
+		case "savePrograms":
+			response.ManualPrograms, err = saveManualPrograms(request.XEPG, request.Programs)
+
+		case "epgSuggestions":
+			response.Suggestions, err = getEPGSuggestions(request.XEPG)
+
+		case "applyEPGSuggestions":
+			response.Suggestions, err = applyEPGSuggestions()
+
+		case "renumberChannels":
+			response.Renumber, err = renumberChannels(request.Renumber.Filter, request.Renumber.Policy, request.Renumber.Apply)
+
+		case "saveRewriteRules":
+			response.Settings, err = saveRewriteRules(request.Rewrite)
+
+		case "previewRewriteRules":
+			response.RewritePreview, err = previewRewriteRules(request.Rewrite)
+
+		case "saveDummyTemplates":
+			response.Settings, err = saveDummyTemplates(request.DummyTemplates)
+
+		case "previewDummyTemplates":
+			response.Programmes, err = previewDummyTemplates(request.XEPG, request.DummyTemplates)
+
+		case "saveGenreRules":
+			response.Settings, err = saveGenreRules(request.GenreRules)
+
+		case "getPPVChannels":
+			response.PPV = getPPVChannels(time.Now())
+
+		case "saveEventRules":
+			response.Settings, err = saveEventRules(request.EventRules)
+
 		case "saveEpgMapping":
 			err = saveXEpgMapping(request)
 
@@ -691,7 +814,7 @@ func WS(w http.ResponseWriter, r *http.Request) {
 			if len(request.Base64) > 0 {
 				response.LogoURL, err = uploadLogo(request.Base64, request.Filename)
 				if err == nil {
-					if err = conn.WriteJSON(response); err != nil {
+					if err = client.writeJSON(response); err != nil {
 						ShowError(err, 1022)
 					} else {
 						return
@@ -719,6 +842,13 @@ func WS(w http.ResponseWriter, r *http.Request) {
 			showDebug("WebSocket: Getting system statistics for "+connID, 3)
 			response.SystemStats = GetSystemStats()
 
+		case "getJobs":
+			response.Jobs = getJobs()
+
+		case "cancelJob":
+			err = cancelJob(request.JobID)
+			response.Jobs = getJobs()
+
 		default:
 			fmt.Println("+ + + + + + + + + + +", request.Cmd)
 		}
@@ -734,9 +864,7 @@ func WS(w http.ResponseWriter, r *http.Request) {
 			response.ConfigurationWizard = System.ConfigurationWizard
 		}
 
-		// Reset write deadline before sending response
-		conn.SetWriteDeadline(time.Now().Add(30 * time.Second))
-		if err = conn.WriteJSON(response); err != nil {
+		if err = client.writeJSON(response); err != nil {
 			ShowError(err, 1022)
 		} else {
 			// Only log important responses, not routine ones
@@ -753,6 +881,39 @@ func WS(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+// wsClient : Websocket Verbindung, Schreibzugriffe werden über den Mutex synchronisiert
+type wsClient struct {
+	conn  *websocket.Conn
+	mutex sync.Mutex
+}
+
+var wsClients sync.Map
+
+func (c *wsClient) writeJSON(v interface{}) error {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(30 * time.Second))
+	return c.conn.WriteJSON(v)
+}
+
+// Nachricht an alle angemeldeten Websocket Verbindungen senden
+func broadcastWebSocket(v interface{}) {
+
+	wsClients.Range(func(key, value interface{}) bool {
+
+		if client, ok := value.(*wsClient); ok {
+			if err := client.writeJSON(v); err != nil {
+				showDebug(fmt.Sprintf("WebSocket: Push to %v failed (%s)", key, err), 3)
+			}
+		}
+
+		return true
+	})
+
+}
+
 // Web : Web Server /web/
 func Web(w http.ResponseWriter, r *http.Request) {
 
@@ -1123,6 +1284,134 @@ func API(w http.ResponseWriter, r *http.Request) {
 	case "update.xepg":
 		buildXEPG(false)
 
+	case "jobs.list":
+		response.Jobs = getJobs()
+
+	case "jobs.get":
+		var job JobStruct
+		job, err = getJob(request.ID)
+		if err == nil {
+			response.Jobs = []JobStruct{job}
+		}
+
+	case "jobs.cancel":
+		err = cancelJob(request.ID)
+		if err == nil {
+			response.Jobs = getJobs()
+		}
+
+	case "changes.list":
+		response.Changes, err = getPlaylistChanges()
+
+	case "changes.get":
+		var report PlaylistChangeReport
+		report, err = getPlaylistChangeReport(request.ID)
+		if err == nil {
+			response.Changes = []PlaylistChangeReport{report}
+		}
+
+	case "filter.preview":
+		response.FilterPreview = previewFilter(request.Filter)
+
+	case "devices.list":
+		response.Devices = Settings.Devices
+
+	case "hdhr.discover":
+		response.Tuners, err = discoverHDHRTuners()
+
+	case "hdhr.import":
+		_, err = importHDHRTuners(request.Tuners)
+		if err == nil {
+			response.Tuners, err = discoverHDHRTuners()
+		}
+
+	case "epg.nownext", "epg.grid", "epg.search":
+		response.EPG, response.Programmes, err = epgQuery(request.Cmd, request.EPG)
+
+	case "programs.list":
+		response.ManualPrograms, err = getManualPrograms(request.ID)
+
+	case "programs.save":
+		response.ManualPrograms, err = saveManualPrograms(request.ID, request.Programs)
+
+	case "epg.suggestions":
+		response.Suggestions, err = getEPGSuggestions(request.ID)
+
+	case "epg.suggestions.apply":
+		response.Suggestions, err = applyEPGSuggestions()
+
+	case "epg.provenance":
+		response.Provenance, err = epgProvenance(request.ID)
+
+	case "channels.renumber":
+		response.Renumber, err = renumberChannels(request.ID, request.Policy, request.Apply)
+
+	case "rewrite.list":
+		response.Rewrite = Settings.Rewrite
+
+	case "rewrite.preview":
+		response.RewritePreview, err = previewRewriteRules(request.Rewrite)
+
+	case "rewrite.save":
+		_, err = saveRewriteRules(request.Rewrite)
+		if err == nil {
+			response.Rewrite = Settings.Rewrite
+		}
+
+	case "dummy.templates.list":
+		response.DummyTemplates = Settings.DummyTemplates
+
+	case "dummy.templates.preview":
+		response.Programmes, err = previewDummyTemplates(request.ID, request.Templates)
+
+	case "dummy.templates.save":
+		_, err = saveDummyTemplates(request.Templates)
+		if err == nil {
+			response.DummyTemplates = Settings.DummyTemplates
+		}
+
+	case "event.rules.list":
+		response.EventRules = Settings.EventRules
+
+	case "event.rules.save":
+		_, err = saveEventRules(request.Events)
+		if err == nil {
+			response.EventRules = Settings.EventRules
+		}
+
+	case "genre.rules.list":
+		response.GenreRules = Settings.GenreRules
+
+	case "genre.rules.save":
+		_, err = saveGenreRules(request.Genres)
+		if err == nil {
+			response.GenreRules = Settings.GenreRules
+		}
+
+	case "ppv.list":
+		response.PPV = getPPVChannels(time.Now())
+
+	case "event.parse":
+		response.Event, err = parseEventName(request.ID, request.Name, request.Events)
+
+	case "matches.list":
+		response.Matches, err = getChannelMatches()
+
+	case "matches.confirm", "matches.reject":
+		err = decideChannelMatch(request.ID, request.Cmd == "matches.confirm")
+		if err == nil {
+			response.Matches, err = getChannelMatches()
+		}
+
+	case "schedule.list":
+		response.Schedule = getScheduleJobs()
+
+	case "schedule.run":
+		err = triggerScheduleJob(request.Name)
+		if err == nil {
+			response.Schedule = getScheduleJobs()
+		}
+
 	default:
 		err = errors.New(getErrMsg(5000))
 
@@ -1,6 +1,7 @@
 package src
 
 import (
+	"bytes"
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
@@ -18,22 +19,43 @@ import (
 	"unicode"
 
 	"threadfin/src/internal/imgcache"
+	"threadfin/src/internal/xmltvindex"
 	_ "time/tzdata"
 )
 
 // Provider XMLTV Datei überprüfen
 func checkXMLCompatibility(id string, body []byte) (err error) {
 
-	var xmltv XMLTV
 	var compatibility = make(map[string]int)
 
-	err = xml.Unmarshal(body, &xmltv)
+	// Nur das Wurzelelement prüfen, HTML Fehlerseiten der Provider ersetzen nicht die lokale Kopie
+	var decoder = xml.NewDecoder(bytes.NewReader(body))
+	for {
+
+		token, errToken := decoder.Token()
+		if errToken != nil {
+			return errors.New(getErrMsg(1003))
+		}
+
+		if element, ok := token.(xml.StartElement); ok {
+
+			if element.Name.Local != "tv" {
+				return errors.New(getErrMsg(1003))
+			}
+
+			break
+		}
+
+	}
+
+	// Der Index zählt die Elemente, ohne den gesamten EPG in den Speicher zu laden
+	ix, err := xmltvindex.Build(bytes.NewReader(body))
 	if err != nil {
 		return
 	}
 
-	compatibility["xmltv.channels"] = len(xmltv.Channel)
-	compatibility["xmltv.programs"] = len(xmltv.Program)
+	compatibility["xmltv.channels"] = len(ix.Channels)
+	compatibility["xmltv.programs"] = ix.Count()
 
 	setProviderCompatibility(id, "xmltv", compatibility)
 
@@ -42,142 +64,139 @@ func checkXMLCompatibility(id string, body []byte) (err error) {
 
 var buildXEPGCount int
 
-// XEPG Daten erstellen
+// XEPG Daten erstellen. Der Job wird mit anderen Datenbank Jobs (Provider Updates) nacheinander ausgeführt,
+// ein bereits wartender XEPG Job wird wiederverwendet.
 func buildXEPG(background bool) {
-	xepgMutex.Lock()
-	defer func() {
-		xepgMutex.Unlock()
-	}()
 
-	if System.ScanInProgress == 1 {
-		return
+	var job = startJob("xepg", "xepg.build", "database", "Build XEPG", runBuildXEPG)
+
+	if !background {
+		job.wait()
 	}
 
+}
+
+// XMLTV Datei in der Warteschlange der Datenbank erstellen, nach Änderungen die nur den EPG betreffen.
+// Ein wartender Auftrag wird wiederverwendet, die Datei wird nie gleichzeitig mit einem XEPG Build geschrieben.
+func queueXMLTVFile() *jobEntry {
+
+	return startJob("xepg", "xepg.files", "database", "Create XMLTV file", func(job *jobEntry) error {
+		return createXMLTVFile()
+	})
+
+}
+
+func runBuildXEPG(job *jobEntry) (err error) {
+
+	systemMutex.Lock()
 	System.ScanInProgress = 1
+	systemMutex.Unlock()
+
+	defer func() {
+		systemMutex.Lock()
+		System.ScanInProgress = 0
+		systemMutex.Unlock()
+	}()
 
 	// Clear streaming URL cache
 	Data.Cache.StreamingURLS = make(map[string]StreamInfo)
 	saveMapToJSONFile(System.File.URLS, Data.Cache.StreamingURLS)
 
-	var err error
-
 	Data.Cache.Images, err = imgcache.New(System.Folder.ImagesCache, fmt.Sprintf("%s://%s/images/", System.ServerProtocol.WEB, System.Domain), Settings.CacheImages)
 	if err != nil {
 		ShowError(err, 0)
+		err = nil
 	}
 
-	if Settings.EpgSource == "XEPG" {
-
-		switch background {
-
-		case true:
-
-			go func() {
-
-				createXEPGMapping()
-				createXEPGDatabase()
-				mapping()
-				cleanupXEPG()
-				createXMLTVFile()
-				createM3UFile()
-
-				showInfo("XEPG:" + fmt.Sprintf("Ready to use"))
-
-				if Settings.CacheImages && System.ImageCachingInProgress == 0 {
-
-					go func() {
-
-						systemMutex.Lock()
-						System.ImageCachingInProgress = 1
-						systemMutex.Unlock()
-
-						showInfo(fmt.Sprintf("Image Caching:Images are cached (%d)", len(Data.Cache.Images.Queue)))
-
-						Data.Cache.Images.Image.Caching()
-						Data.Cache.Images.Image.Remove()
-						showInfo("Image Caching:Done")
-
-						createXMLTVFile()
-						createM3UFile()
-
-						systemMutex.Lock()
-						System.ImageCachingInProgress = 0
-						systemMutex.Unlock()
-
-					}()
-
-				}
-
-				systemMutex.Lock()
-				System.ScanInProgress = 0
-				systemMutex.Unlock()
-
-				// Cache löschen
-				/*
-					Data.Cache.XMLTV = make(map[string]XMLTV)
-					Data.Cache.XMLTV = nil
-				*/
-				runtime.GC()
-
-			}()
+	if Settings.EpgSource != "XEPG" {
+		job.addLog("Create lineup")
+		getLineup(nil)
+		updateDLNAObjects()
+		return
+	}
 
-		case false:
+	var steps = []struct {
+		name string
+		run  func() error
+	}{
+		{"Create XEPG mapping", func() error { createXEPGMapping(); return nil }},
+		{"Create XEPG database", createXEPGDatabase},
+		{"Mapping", mapping},
+		{"Clean up XEPG database", func() error { cleanupXEPG(); return nil }},
+		{"Lineup shards", updateLineupShards},
+		{"Create XMLTV file", createXMLTVFile},
+		{"Create M3U file", func() error { createM3UFile(); return nil }},
+		{"DLNA", updateDLNAObjects},
+	}
 
-			createXEPGMapping()
-			createXEPGDatabase()
-			mapping()
-			cleanupXEPG()
-			createXMLTVFile()
-			createM3UFile()
+	for i, step := range steps {
 
-			go func() {
+		// Bei einem Abbruch bleiben die zuletzt erstellten Dateien erhalten
+		if job.canceled() {
+			job.addLog("Canceled before: %s", step.name)
+			return
+		}
 
-				if Settings.CacheImages && System.ImageCachingInProgress == 0 {
+		job.addLog(step.name)
+		if err := step.run(); err != nil {
+			job.addLog("%s: %s", step.name, err)
+		}
+		job.setProgress((i + 1) * 100 / len(steps))
 
-					go func() {
+	}
 
-						systemMutex.Lock()
-						System.ImageCachingInProgress = 1
-						systemMutex.Unlock()
+	showInfo("XEPG:" + fmt.Sprintf("Ready to use"))
+	job.setResult(fmt.Sprintf("%d channels", len(Data.XEPG.Channels)))
 
-						showInfo(fmt.Sprintf("Image Caching:Images are cached (%d)", len(Data.Cache.Images.Queue)))
+	if Settings.CacheImages {
+		startImageCaching()
+	}
 
-						Data.Cache.Images.Image.Caching()
-						Data.Cache.Images.Image.Remove()
-						showInfo("Image Caching:Done")
+	runtime.GC()
 
-						createXMLTVFile()
-						createM3UFile()
+	return
+}
 
-						systemMutex.Lock()
-						System.ImageCachingInProgress = 0
-						systemMutex.Unlock()
+// Bilder im Hintergrund cachen, danach werden die XMLTV und M3U Dateien mit den neuen URLs erstellt
+func startImageCaching() *jobEntry {
 
-					}()
+	return startJob("images", "images.cache", "images", "Cache images", func(job *jobEntry) (err error) {
 
-				}
+		systemMutex.Lock()
+		System.ImageCachingInProgress = 1
+		var cache = Data.Cache.Images
+		systemMutex.Unlock()
 
-				showInfo("XEPG:" + fmt.Sprintf("Ready to use"))
+		defer func() {
+			systemMutex.Lock()
+			System.ImageCachingInProgress = 0
+			systemMutex.Unlock()
+		}()
 
-				systemMutex.Lock()
-				System.ScanInProgress = 0
-				systemMutex.Unlock()
+		if cache == nil {
+			return
+		}
 
-				// Cache löschen
-				//Data.Cache.XMLTV = make(map[string]XMLTV)
-				//Data.Cache.XMLTV = nil
-				runtime.GC()
+		showInfo(fmt.Sprintf("Image Caching:Images are cached (%d)", len(cache.Queue)))
+		job.addLog("Images in queue: %d", len(cache.Queue))
 
-			}()
+		cache.Image.CachingContext(job.ctx, func(done, total int) {
+			job.setProgress(done * 90 / total)
+		})
 
+		if job.canceled() {
+			return
 		}
 
-	} else {
+		cache.Image.Remove()
+		showInfo("Image Caching:Done")
 
-		getLineup()
-		System.ScanInProgress = 0
+		job.addLog("Create XMLTV and M3U file")
+		createXMLTVFile()
+		createM3UFile()
 
-	}
+		return
+	})
 
 }
 
@@ -280,6 +299,13 @@ func createXEPGMapping() {
 
 					channel["id"] = c.ID
 					channel["display-name"] = friendlyDisplayName(*c)
+
+					// Alle Namen des Kanals für die Zuordnung über den Namen (epgmatch.go)
+					var aliases = make([]string, 0, len(c.DisplayName))
+					for _, dn := range c.DisplayName {
+						aliases = append(aliases, dn.Value)
+					}
+					channel["aliases"] = aliases
 					channel["icon"] = imgc.Image.GetURL(c.Icon.Src, Settings.HttpThreadfinDomain, Settings.Port, Settings.ForceHttps, Settings.HttpsPort, Settings.HttpsThreadfinDomain)
 					channel["active"] = c.Active
 
@@ -359,6 +385,12 @@ func createXEPGDatabase() (err error) {
 	settings_json, _ := json.Marshal(settings)
 	json.Unmarshal(settings_json, &Settings)
 
+	// Umschreibregeln für Kanalname, Gruppe, tvg-id und Logo. Fehlerhafte Regeln werden nicht angewendet
+	rewriteRules, errRewrite := compileRewriteRules(Settings.Rewrite)
+	if errRewrite != nil {
+		ShowError(errRewrite, 0)
+	}
+
 	// Get current M3U channels
 	m3uChannels := make(map[string]M3UChannelStructXEPG)
 	for _, dsa := range Data.Streams.Active {
@@ -475,6 +507,9 @@ func createXEPGDatabase() (err error) {
 		xepgChannelsValuesMap[channelHash] = channel
 	}
 
+	// Umbenannte Kanäle anhand von tvg-id, Name, Stream ID der URL und Ähnlichkeit des Namens finden
+	var rematch = newRematcher(xepgChannelsValuesMap, Data.Streams.All)
+
 	for _, dsa := range Data.Streams.Active {
 		var channelExists = false  // Entscheidet ob ein Kanal neu zu Datenbank hinzugefügt werden soll.  Decides whether a channel should be added to the database
 		var channelHasUUID = false // Überprüft, ob der Kanal (Stream) eindeutige ID's besitzt.  Checks whether the channel (stream) has unique IDs
@@ -501,6 +536,8 @@ func createXEPGDatabase() (err error) {
 
 		Data.Cache.Streams.Active = append(Data.Cache.Streams.Active, m3uChannelHash)
 
+		var rewritten = rewriteChannel(rewriteRules, m3uChannel)
+
 		if val, ok := xepgChannelsValuesMap[m3uChannelHash]; ok {
 			channelExists = true
 			currentXEPGID = val.XEPG
@@ -533,6 +570,27 @@ func createXEPGDatabase() (err error) {
 			}
 		}
 
+		// Der Kanal wurde vom Provider umbenannt, die Einstellungen des bisherigen Kanals werden übernommen
+		if !channelExists {
+
+			if old, method, score, ok := rematch.find(m3uChannel, m3uChannelHash); ok {
+
+				var channel = carryChannelCustomisations(old, newXEPGChannelFromStream(m3uChannel, m3uChannelHash))
+				channel.XEPG = old.XEPG
+
+				Data.XEPG.Channels[old.XEPG] = channel
+				xepgChannelsValuesMap[m3uChannelHash] = channel
+
+				channelExists = true
+				currentXEPGID = old.XEPG
+				currentChannelNumber = old.TvgChno
+
+				showInfo(fmt.Sprintf("XEPG:Channel renamed '%s' -> '%s' (%s, %.2f)", old.Name, m3uChannel.Name, method, score))
+
+			}
+
+		}
+
 		switch channelExists {
 
 		case true:
@@ -562,15 +620,40 @@ func createXEPGDatabase() (err error) {
 				if channelHasUUID {
 					programData, _ := getProgramData(xepgChannel)
 					if xepgChannel.XUpdateChannelName || strings.Contains(xepgChannel.TvgID, "threadfin-") || (m3uChannel.LiveEvent == "true" && len(programData.Program) <= 3) {
-						xepgChannel.XName = m3uChannel.Name
+						xepgChannel.XName = rewritten.Name
 					}
 				}
 
 				// Kanallogo aktualisieren. Wird bei vorhandenem Logo in der XMLTV Datei wieder überschrieben
 				if xepgChannel.XUpdateChannelIcon {
 					var imgc = Data.Cache.Images
-					xepgChannel.TvgLogo = imgc.Image.GetURL(m3uChannel.TvgLogo, Settings.HttpThreadfinDomain, Settings.Port, Settings.ForceHttps, Settings.HttpsPort, Settings.HttpsThreadfinDomain)
+					xepgChannel.TvgLogo = imgc.Image.GetURL(rewritten.Logo, Settings.HttpThreadfinDomain, Settings.Port, Settings.ForceHttps, Settings.HttpsPort, Settings.HttpsThreadfinDomain)
+				}
+			}
+
+			// Umschreibregeln auch bei vorhandenen Kanälen anwenden, sofern Name / Logo aktualisiert werden dürfen.
+			// Das Logo wird wie oben über den Bilder Cache gesetzt.
+			if rewriteRules.Len() > 0 {
+
+				if xepgChannel.XUpdateChannelName {
+					xepgChannel.XName = rewritten.Name
+				}
+
+				if xepgChannel.XUpdateChannelIcon {
+					var imgc = Data.Cache.Images
+					xepgChannel.TvgLogo = imgc.Image.GetURL(rewritten.Logo, Settings.HttpThreadfinDomain, Settings.Port, Settings.ForceHttps, Settings.HttpsPort, Settings.HttpsThreadfinDomain)
+				}
+
+			}
+
+			// Gruppe der Umschreibregeln übernehmen, solange die Gruppe nicht vom Benutzer geändert wurde
+			if rewriteRules.Len() > 0 || len(xepgChannel.XGroupRewrite) > 0 {
+
+				if xepgChannel.XGroupTitle == xepgChannel.XGroupRewrite || xepgChannel.XGroupTitle == xepgChannel.GroupTitle || len(xepgChannel.XGroupTitle) == 0 {
+					xepgChannel.XGroupTitle = rewritten.Group
+					xepgChannel.XGroupRewrite = rewritten.Group
 				}
+
 			}
 
 			Data.XEPG.Channels[currentXEPGID] = xepgChannel
@@ -611,7 +694,7 @@ func createXEPGDatabase() (err error) {
 			newChannel.GroupTitle = m3uChannel.GroupTitle
 			newChannel.Name = m3uChannel.Name
 			newChannel.TvgID = m3uChannel.TvgID
-			newChannel.TvgLogo = m3uChannel.TvgLogo
+			newChannel.TvgLogo = rewritten.Logo
 			newChannel.TvgName = m3uChannel.TvgName
 			newChannel.URL = m3uChannel.URL
 			newChannel.Live, _ = strconv.ParseBool(m3uChannel.LiveEvent)
@@ -621,7 +704,7 @@ func createXEPGDatabase() (err error) {
 				if !ok {
 					continue
 				}
-				if channel, ok := channelsMap[m3uChannel.TvgID]; ok {
+				if channel, ok := channelsMap[rewritten.TvgID]; ok {
 					filters := []FilterStruct{}
 					for _, filter := range Settings.Filter {
 						filter_json, _ := json.Marshal(filter)
@@ -669,6 +752,11 @@ func createXEPGDatabase() (err error) {
 				newChannel.XmltvFile = "Threadfin Dummy"
 				newChannel.XMapping = "PPV"
 				newChannel.XActive = true
+
+				// With automatic activation the channel is only active around the event
+				if Settings.PPVAuto {
+					newChannel.XActive = ppvChannelStatus(newChannel, time.Now()).Active
+				}
 			}
 
 			if len(m3uChannel.UUIDKey) > 0 {
@@ -679,18 +767,27 @@ func createXEPGDatabase() (err error) {
 				newChannel.UUIDValue = ""
 			}
 
-			newChannel.XName = m3uChannel.Name
-			newChannel.XGroupTitle = m3uChannel.GroupTitle
+			newChannel.XName = rewritten.Name
+			newChannel.XGroupTitle = rewritten.Group
+			if rewriteRules.Len() > 0 {
+				newChannel.XGroupRewrite = rewritten.Group
+			}
 			newChannel.XEPG = xepg
 			newChannel.TvgChno = xChannelID
 			newChannel.XChannelID = xChannelID
 			newChannel.ChannelUniqueID = m3uChannelHash
 			Data.XEPG.Channels[xepg] = newChannel
 			xepgChannelsValuesMap[m3uChannelHash] = newChannel
+			rematch.created(m3uChannelHash, xepg)
 
 		}
 	}
 
+	rematch.save()
+
+	// Kanalnummern der Filter mit automatischer Nummerierung neu vergeben
+	autoRenumber()
+
 	showInfo("XEPG:" + "Save DB file")
 
 	err = saveMapToJSONFile(System.File.XEPG, Data.XEPG.Channels)
@@ -698,6 +795,9 @@ func createXEPGDatabase() (err error) {
 		return
 	}
 
+	// Berichte der letzten Playlist Aktualisierungen mit den XEPG Kanälen ergänzen
+	applyPlaylistChanges()
+
 	return
 }
 
@@ -705,6 +805,10 @@ func createXEPGDatabase() (err error) {
 func mapping() (err error) {
 	showInfo("XEPG:" + "Map channels")
 
+	rewriteRules, _ := compileRewriteRules(Settings.Rewrite)
+
+	var matcher *epgMatcher
+
 	for xepg, dxc := range Data.XEPG.Channels {
 
 		var xepgChannel XEPGChannelStruct
@@ -752,7 +856,7 @@ func mapping() (err error) {
 			// Werte kann "-" sein, deswegen len < 1
 			if len(xepgChannel.XmltvFile) < 1 {
 
-				var tvgID = xepgChannel.TvgID
+				var tvgID = rewriteXEPGChannel(rewriteRules, xepgChannel).TvgID
 
 				xepgChannel.XmltvFile = "-"
 				xepgChannel.XMapping = "-"
@@ -806,6 +910,26 @@ func mapping() (err error) {
 					}
 
 				}
+
+				// Zuordnung über den Kanalnamen, wenn über die tvg-id kein XMLTV Kanal gefunden wurde (epgmatch.go)
+				if xepgChannel.XmltvFile == "-" && Settings.EPGMatchNames {
+
+					if matcher == nil {
+						matcher = newEPGMatcher()
+					}
+
+					if candidate, ok := matcher.auto(channelMatchNames(xepgChannel)...); ok {
+
+						xepgChannel.XmltvFile = candidate.File
+						xepgChannel.XMapping = candidate.Mapping
+						xepgChannel.XActive = true
+
+						Data.XEPG.Channels[xepg] = xepgChannel
+						showInfo("XEPG:" + fmt.Sprintf("Mapped by name: %s -> %s (%.2f)", xepgChannel.Name, candidate.DisplayName, candidate.Score))
+
+					}
+
+				}
 			}
 		}
 
@@ -902,8 +1026,6 @@ func createXMLTVFile() (err error) {
 
 	// Image Cache
 	// 4edd81ab7c368208cc6448b615051b37.jpg
-	var imgc = Data.Cache.Images
-
 	Data.Cache.ImagesFiles = []string{}
 	Data.Cache.ImagesURLS = []string{}
 	Data.Cache.ImagesCache = []string{}
@@ -928,90 +1050,57 @@ func createXMLTVFile() (err error) {
 
 	showInfo("XEPG:" + fmt.Sprintf("Create XMLTV file (%s)", System.File.XML))
 
-	var xepgXML XMLTV
-
-	xepgXML.Generator = System.Name
+	var source string
 
 	if System.Branch == "main" {
-		xepgXML.Source = fmt.Sprintf("%s - %s", System.Name, System.Version)
+		source = fmt.Sprintf("%s - %s", System.Name, System.Version)
 	} else {
-		xepgXML.Source = fmt.Sprintf("%s - %s.%s", System.Name, System.Version, System.Build)
+		source = fmt.Sprintf("%s - %s.%s", System.Name, System.Version, System.Build)
 	}
 
-	var tmpProgram = &XMLTV{}
-
-	for _, dxc := range Data.XEPG.Channels {
-		var xepgChannel XEPGChannelStruct
-		err := json.Unmarshal([]byte(mapToJSON(dxc)), &xepgChannel)
-		if err == nil {
-			if xepgChannel.TvgName == "" {
-				xepgChannel.TvgName = xepgChannel.Name
-			}
-			if xepgChannel.XName == "" {
-				xepgChannel.XName = xepgChannel.TvgName
-			}
-
-			if xepgChannel.XActive && !xepgChannel.XHideChannel {
-				if (Settings.XepgReplaceChannelTitle && xepgChannel.XMapping == "PPV") || xepgChannel.XName != "" {
-					// Kanäle
-					var channel Channel
-					channel.ID = xepgChannel.XChannelID
-					channel.Icon = Icon{Src: imgc.Image.GetURL(xepgChannel.TvgLogo, Settings.HttpThreadfinDomain, Settings.Port, Settings.ForceHttps, Settings.HttpsPort, Settings.HttpsThreadfinDomain)}
-					channel.DisplayName = append(channel.DisplayName, DisplayName{Value: xepgChannel.XName})
-					channel.Active = xepgChannel.XActive
-					channel.Live = xepgChannel.Live
-					xepgXML.Channel = append(xepgXML.Channel, &channel)
-				}
-
-				// Programme
-				*tmpProgram, err = getProgramData(xepgChannel)
-				if err == nil {
-					xepgXML.Program = append(xepgXML.Program, tmpProgram.Program...)
-				}
-			}
-		} else {
-			showDebug("XEPG:"+fmt.Sprintf("Error: %s", err), 3)
-		}
-	}
-
-	var content, _ = xml.MarshalIndent(xepgXML, "  ", "    ")
-	var xmlOutput = []byte(xml.Header + string(content))
-	writeByteToFile(System.File.XML, xmlOutput)
-
-	showInfo("XEPG:" + fmt.Sprintf("Compress XMLTV file (%s)", System.Compressed.GZxml))
-	err = compressGZIP(&xmlOutput, System.Compressed.GZxml)
-
-	xepgXML = XMLTV{}
+	// XML und GZIP Datei werden gleichzeitig geschrieben (xmltvwriter.go)
+	err = writeXMLTV(System.File.XML, System.Compressed.GZxml, System.Name, source)
 
 	return
 }
 
 // Programmdaten erstellen (createXMLTVFile)
 func getProgramData(xepgChannel XEPGChannelStruct) (xepgXML XMLTV, err error) {
-	var xmltvFile = System.Folder.Data + xepgChannel.XmltvFile
-	var channelID = xepgChannel.XMapping
+	xepgXML, _, _, err = mergeProgramData(xepgChannel)
+	return
+}
+
+// Programmdaten einer EPG Quelle (XMLTV Datei + Kanal ID) für den Kanal
+func getSourceProgramData(xepgChannel XEPGChannelStruct, source EPGSource) (xepgXML XMLTV, err error) {
+	var xmltvFile = System.Folder.Data + source.File
+	var channelID = source.Mapping
 
 	var xmltv XMLTV
 
 	if strings.Contains(xmltvFile, "Threadfin Dummy") {
-		xmltv = createDummyProgram(xepgChannel)
+		var dummyChannel = xepgChannel
+		dummyChannel.XMapping = source.Mapping
+		xmltv = createDummyProgram(dummyChannel)
 	} else {
-		if xepgChannel.XmltvFile != "" {
-			err = getLocalXMLTV(xmltvFile, &xmltv)
+		if source.File != "" {
+			xmltv.Program, err = getLocalXMLTVPrograms(xmltvFile, channelID)
 			if err != nil {
 				return
 			}
 		}
 	}
 
+	// Zeitkorrektur (Zeitzone der XMLTV Datei, Versatz der Datei und des Kanals)
+	var shift = getEPGTimeShift(xepgChannel, source)
+
 	for _, xmltvProgram := range xmltv.Program {
 		if xmltvProgram.Channel == channelID {
 			var program = &Program{}
 
 			// Channel ID
 			program.Channel = xepgChannel.XChannelID
-			program.Start = xmltvProgram.Start
-			program.Stop = xmltvProgram.Stop
+			program.Start = shift.apply(xmltvProgram.Start)
+			program.Stop = shift.apply(xmltvProgram.Stop)
 
 			// Title
 			if len(xmltvProgram.Title) > 0 {
@@ -1036,6 +1125,7 @@ func getProgramData(xepgChannel XEPGChannelStruct) (xepgXML XMLTV, err error) {
 
 			// Category (Kategorie)
 			getCategory(program, xmltvProgram, xepgChannel, filters)
+			normalizeGenres(program, source)
 
 			// Sub-Title
 			program.SubTitle = xmltvProgram.SubTitle
@@ -1107,100 +1197,14 @@ func createLiveProgram(xepgChannel XEPGChannelStruct, channelId string) []*Progr
 		name = xepgChannel.TvgName
 	}
 
-	// Search for Datetime or Time
-	// Datetime examples: '12/31-11:59 PM', '1.1 6:30 AM', '09/15-10:00PM', '7/4 12:00 PM', '3.21 3:45 AM', '6/30-8:00 AM', '4/15 3AM'
-	// Time examples: '11:59 PM', '6:30 AM', '11:59PM', '1PM'
-	re := regexp.MustCompile(`((\d{1,2}[./]\d{1,2})[-\s])*(\d{1,2}(:\d{2})*\s*(AM|PM)?(?:\s*(ET|CT|MT|PT|EST|CST|MST|PST))?)`)
-	matches := re.FindStringSubmatch(name)
-	layout := "2006.1.2 3:04 PM"
-	if len(matches) > 0 {
-		timePart := matches[len(matches)-2]
-		if timePart == "" {
-			timePart = matches[len(matches)-1]
-		}
-
-		timeString := strings.TrimSpace(timePart)
-		timeString = strings.ReplaceAll(timeString, "  ", " ")
-
-		// Enhanced timezone handling with fallbacks
-		var location *time.Location = currentTime.Location() // Default to system timezone
-		
-		// Handle common US timezones
-		if strings.Contains(timeString, "ET") || strings.Contains(timeString, "EST") || strings.Contains(timeString, "EDT") {
-			if loc, err := time.LoadLocation("America/New_York"); err == nil {
-				location = loc
-			}
-		} else if strings.Contains(timeString, "CT") || strings.Contains(timeString, "CST") || strings.Contains(timeString, "CDT") {
-			if loc, err := time.LoadLocation("America/Chicago"); err == nil {
-				location = loc
-			}
-		} else if strings.Contains(timeString, "MT") || strings.Contains(timeString, "MST") || strings.Contains(timeString, "MDT") {
-			if loc, err := time.LoadLocation("America/Denver"); err == nil {
-				location = loc
-			}
-		} else if strings.Contains(timeString, "PT") || strings.Contains(timeString, "PST") || strings.Contains(timeString, "PDT") {
-			if loc, err := time.LoadLocation("America/Los_Angeles"); err == nil {
-				location = loc
-			}
-		}
-		// For unsupported timezones, fallback to system timezone (already set above)
-
-		// Remove timezone from timeString
-		timeString = strings.ReplaceAll(timeString, "ET", "")
-		timeString = strings.ReplaceAll(timeString, "CT", "")
-		timeString = strings.ReplaceAll(timeString, "MT", "")
-		timeString = strings.ReplaceAll(timeString, "PT", "")
-		timeString = strings.ReplaceAll(timeString, "EST", "")
-		timeString = strings.ReplaceAll(timeString, "CST", "")
-		timeString = strings.ReplaceAll(timeString, "MST", "")
-		timeString = strings.ReplaceAll(timeString, "PST", "")
-		timeString = strings.TrimSpace(timeString)
-
-		// Handle different date formats
-		var datePart string
-		if len(matches) > 3 && matches[2] != "" {
-			datePart = matches[2]
-			// Convert slashes to dots for consistency
-			datePart = strings.ReplaceAll(datePart, "/", ".")
-		}
-
-		// Build the full time string
-		var fullTimeString string
-		if datePart != "" {
-			// If we have a date part, use it
-			parts := strings.Split(datePart, ".")
-			if len(parts) == 2 {
-				month := parts[0]
-				day := parts[1]
-				fullTimeString = fmt.Sprintf("%d.%s.%s %s", currentTime.Year(), month, day, timeString)
-			}
-		} else {
-			// If no date part, use current date
-			fullTimeString = fmt.Sprintf("%d.%d.%d %s", currentTime.Year(), currentTime.Month(), currentTime.Day(), timeString)
-		}
-
-		// Determine layout based on time format
-		if strings.Contains(timeString, ":") {
-			if strings.Contains(timeString, "AM") || strings.Contains(timeString, "PM") {
-				layout = "2006.1.2 3:04 PM"
-			} else {
-				layout = "2006.1.2 15:04"
-			}
-		} else {
-			if strings.Contains(timeString, "AM") || strings.Contains(timeString, "PM") {
-				layout = "2006.1.2 3PM"
-			} else {
-				layout = "2006.1.2 15"
-			}
-		}
-
-		startTimeParsed, err := time.ParseInLocation(layout, fullTimeString, location)
-		if err != nil {
-			startTime = time.Date(currentTime.Year(), currentTime.Month(), currentTime.Day(), 6, 0, 0, 0, location)
-		} else {
-			localTime := startTimeParsed.In(localLocation)
-			startTime = localTime
-		}
+	// Start time (and stop with a duration) of the event from the name, see event.rules
+	// Examples: '12/31-11:59 PM', '7/4 12:00 PM ET', '17:30 UK', 'Sat 19 Oct 20:00 CEST', '2024-10-18T19:00Z'
+	var eventRule = eventTimeRuleFor(Settings.EventRules, xepgChannel)
+	var eventHasDuration bool
+	if eventStart, eventStop, ok := eventTime(eventRule, name, currentTime); ok {
+		startTime = eventStart
+		stopTime = eventStop
+		eventHasDuration = eventRule.Duration > 0 || Settings.EventDuration > 0
 	}
 
 	// Add "CHANNEL OFFLINE" program for the time before the event
@@ -1261,6 +1265,9 @@ func createLiveProgram(xepgChannel XEPGChannelStruct, channelId string) []*Progr
 	// Add "CHANNEL OFFLINE" program for the time after the event
 	midnightNextDayStart := time.Date(stopTime.Year(), stopTime.Month(), stopTime.Day()+1, 0, 0, 0, currentTime.Nanosecond(), localLocation)
 	midnightNextDayStop := time.Date(stopTime.Year(), stopTime.Month(), stopTime.Day()+1, 23, 59, 59, currentTime.Nanosecond(), localLocation)
+	if eventHasDuration {
+		midnightNextDayStart = stopTime
+	}
 	programAfter := &Program{
 		Channel: channelId,
 		Start:   midnightNextDayStart.Format("20060102150405 -0700"),
@@ -1291,24 +1298,13 @@ func createDummyProgram(xepgChannel XEPGChannelStruct) (dummyXMLTV XMLTV) {
 
 	showInfo("Create Dummy Guide:" + "Time offset" + offset + " - " + xepgChannel.XName)
 
-	var dummyLength int = 30 // Default to 30 minutes if parsing fails
-	var err error
-	var dl = strings.Split(xepgChannel.XMapping, "_")
-	if dl[0] != "" {
-		// Check if the first part is a valid integer
-		if match, _ := regexp.MatchString(`^\d+$`, dl[0]); match {
-			dummyLength, err = strconv.Atoi(dl[0])
-			if err != nil {
-				ShowError(err, 000)
-				// Continue with default value instead of returning
-			}
-		} else {
-			// For non-numeric formats that aren't "PPV" (which is handled above),
-			// use the default value
-			showInfo(fmt.Sprintf("Non-numeric format for XMapping: %s, using default duration of 30 minutes", xepgChannel.XMapping))
-		}
+	if template, ok := dummyTemplateFor(Settings.DummyTemplates, xepgChannel); ok {
+		dummyXMLTV.Program = templateDummyProgram(xepgChannel, template, currentTime)
+		return
 	}
 
+	var dummyLength = dummyMappingLength(xepgChannel.XMapping)
+
 	for d := 0; d < 4; d++ {
 
 		var epgStartTime = startTime.Add(time.Hour * time.Duration(d*24))
@@ -1381,6 +1377,34 @@ func createDummyProgram(xepgChannel XEPGChannelStruct) (dummyXMLTV XMLTV) {
 	return
 }
 
+// Länge der Dummy Sendungen aus x-mapping (30_Minutes), 30 Minuten wenn die Länge nicht gelesen werden kann
+func dummyMappingLength(xmapping string) (dummyLength int) {
+
+	dummyLength = 30 // Default to 30 minutes if parsing fails
+
+	var err error
+	var dl = strings.Split(xmapping, "_")
+	if dl[0] != "" {
+		// Check if the first part is a valid integer
+		if match, _ := regexp.MatchString(`^\d+$`, dl[0]); match {
+			dummyLength, err = strconv.Atoi(dl[0])
+			if err != nil {
+				ShowError(err, 000)
+				// Continue with default value instead of returning
+			}
+			if dummyLength <= 0 {
+				dummyLength = 30
+			}
+		} else {
+			// For non-numeric formats that aren't "PPV" (which is handled above),
+			// use the default value
+			showInfo(fmt.Sprintf("Non-numeric format for XMapping: %s, using default duration of 30 minutes", xmapping))
+		}
+	}
+
+	return
+}
+
 // Kategorien erweitern (createXMLTVFile)
 func getCategory(program *Program, xmltvProgram *Program, xepgChannel XEPGChannelStruct, filters []FilterStruct) {
 
@@ -1479,33 +1503,87 @@ func getVideo(program *Program, xmltvProgram *Program, xepgChannel XEPGChannelSt
 // Lokale Provider XMLTV Datei laden
 func getLocalXMLTV(file string, xmltv *XMLTV) (err error) {
 
-	if _, ok := Data.Cache.XMLTV[file]; !ok {
+	index, err := getXMLTVIndex(file)
+	if err != nil {
+		return
+	}
 
-		// Cache initialisieren
-		if len(Data.Cache.XMLTV) == 0 {
-			Data.Cache.XMLTV = make(map[string]XMLTV)
-		}
+	// Nur die Kanäle lesen, die Programme werden pro Kanal über den Index gelesen (getLocalXMLTVPrograms)
+	xmltv.Channel = make([]*Channel, 0, len(index.Channels))
 
-		// XML Daten lesen
-		content, err := readByteFromFile(file)
+	err = xmltvindex.Read(file, index.Channels, func(element []byte) error {
 
-		// Lokale XML Datei existiert nicht im Ordner: data
-		if err != nil {
-			err = errors.New("Local copy of the file no longer exists")
+		var channel = &Channel{}
+		if err := xml.Unmarshal(element, channel); err != nil {
 			return err
 		}
 
-		// XML Datei parsen
-		err = xml.Unmarshal(content, &xmltv)
-		if err != nil {
+		xmltv.Channel = append(xmltv.Channel, channel)
+		return nil
+	})
+
+	return
+}
+
+// Programme eines Kanals aus der lokalen XMLTV Datei
+func getLocalXMLTVPrograms(file, channelID string) (programs []*Program, err error) {
+
+	index, err := getXMLTVIndex(file)
+	if err != nil {
+		return
+	}
+
+	var spans = index.Programmes[channelID]
+	programs = make([]*Program, 0, len(spans))
+
+	err = xmltvindex.Read(file, spans, func(element []byte) error {
+
+		var program = &Program{}
+		if err := xml.Unmarshal(element, program); err != nil {
 			return err
 		}
 
-		Data.Cache.XMLTV[file] = *xmltv
+		programs = append(programs, program)
+		return nil
+	})
 
-	} else {
-		*xmltv = Data.Cache.XMLTV[file]
+	return
+}
+
+// Index der lokalen XMLTV Datei (Position der Kanäle und Programme in der Datei)
+func getXMLTVIndex(file string) (index *xmltvindex.Index, err error) {
+
+	systemMutex.Lock()
+	index, ok := Data.Cache.XMLTV[file]
+	systemMutex.Unlock()
+
+	if ok {
+		return
+	}
+
+	// Der Index wird im Cache Ordner gespeichert und nach einem Neustart wiederverwendet, solange die Datei unverändert ist
+	var indexFile string
+	if Settings.XMLTVIndexDisk {
+		indexFile = System.Folder.Cache + "xmltv" + string(os.PathSeparator) + getFilenameFromPath(file) + ".idx"
+	}
+
+	index, err = xmltvindex.Open(file, indexFile)
+	if err != nil {
+
+		// Lokale XML Datei existiert nicht im Ordner: data
+		if os.IsNotExist(err) {
+			err = errors.New("Local copy of the file no longer exists")
+		}
+
+		return
+	}
+
+	systemMutex.Lock()
+	if Data.Cache.XMLTV == nil {
+		Data.Cache.XMLTV = make(map[string]*xmltvindex.Index)
 	}
+	Data.Cache.XMLTV[file] = index
+	systemMutex.Unlock()
 
 	return
 }
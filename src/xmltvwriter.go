@@ -0,0 +1,309 @@
+package src
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// Die XMLTV Datei wird Element für Element in temporäre Dateien geschrieben (XML und gzip gleichzeitig)
+// und umbenannt, sobald beide vollständig sind, Clients sehen nie einen halb geschriebenen Guide.
+// Die kodierten Sendungen jedes Kanals werden als Block behalten und im nächsten Build wiederverwendet,
+// solange Kanal, EPG Quellen und Einstellungen unverändert sind.
+
+// xmltvBlock : Kodierte Sendungen eines Kanals
+type xmltvBlock struct {
+	fingerprint string
+	data        []byte
+	pruned      epgPruneStats // Beim Erstellen des Blocks entfernte Sendungen
+}
+
+const (
+	xmltvPrefix = "  "
+	xmltvIndent = "    "
+)
+
+// xmltvWriter : Schreibt die XMLTV Datei und die gzip Datei
+type xmltvWriter struct {
+	xml, gz *os.File
+	buffer  *bufio.Writer
+	gzip    *gzip.Writer
+	w       io.Writer
+	err     error
+}
+
+func newXMLTVWriter(xmlFile, gzFile string) (w *xmltvWriter, err error) {
+
+	w = &xmltvWriter{}
+
+	if w.xml, err = os.Create(getPlatformFile(xmlFile + ".tmp")); err != nil {
+		return
+	}
+
+	if w.gz, err = os.Create(getPlatformFile(gzFile + ".tmp")); err != nil {
+		w.xml.Close()
+		os.Remove(w.xml.Name())
+		return
+	}
+
+	w.gzip = gzip.NewWriter(w.gz)
+	w.buffer = bufio.NewWriterSize(io.MultiWriter(w.xml, w.gzip), 256*1024)
+	w.w = w.buffer
+
+	return
+}
+
+func (w *xmltvWriter) write(data []byte) {
+
+	if w.err == nil {
+		_, w.err = w.w.Write(data)
+	}
+
+}
+
+func (w *xmltvWriter) writeString(s string) {
+	w.write([]byte(s))
+}
+
+// close : Schließt beide Dateien ab und benennt sie um, bei einem Fehler werden die temporären Dateien entfernt
+func (w *xmltvWriter) close(xmlFile, gzFile string) (err error) {
+
+	if w.err == nil {
+		w.err = w.buffer.Flush()
+	}
+
+	for _, closeErr := range []error{w.gzip.Close(), w.gz.Close(), w.xml.Close()} {
+		if w.err == nil {
+			w.err = closeErr
+		}
+	}
+
+	if w.err == nil {
+		w.err = os.Rename(w.xml.Name(), getPlatformFile(xmlFile))
+	}
+
+	if w.err == nil {
+		w.err = os.Rename(w.gz.Name(), getPlatformFile(gzFile))
+	}
+
+	if w.err != nil {
+		os.Remove(w.xml.Name())
+		os.Remove(w.gz.Name())
+	}
+
+	return w.err
+}
+
+// encodeXMLTVElement : Element mit der Einrückung der XMLTV Datei (Kind von <tv>), beginnt mit einer neuen Zeile
+func encodeXMLTVElement(buffer *bytes.Buffer, name string, v interface{}) (err error) {
+
+	buffer.WriteByte('\n')
+
+	var enc = xml.NewEncoder(buffer)
+	enc.Indent(xmltvPrefix+xmltvIndent, xmltvIndent)
+
+	if err = enc.EncodeElement(v, xml.StartElement{Name: xml.Name{Local: name}}); err != nil {
+		return
+	}
+
+	return enc.Flush()
+}
+
+// xmltvFingerprint : Alles außer dem Kanal selbst, was die Sendungen verändert
+func xmltvFingerprint() string {
+
+	var images int
+	if Settings.CacheImages {
+		images = len(Data.Cache.ImagesCache)
+	}
+
+	var now = time.Now()
+	var from, to = epgWindow(now, Settings.EPGWindowPast, Settings.EPGWindowFuture)
+
+	// Das Datum ist Teil des Fingerprints, die Dummy Sendungen ändern sich jeden Tag. Das EPG Zeitfenster verschiebt sich jede Stunde.
+	return getMD5(fmt.Sprintf("%s|%d|%s|%s", mapToJSON(Settings), images, now.Format("20060102"), windowFingerprint(from, to)))
+}
+
+// windowFingerprint : Grenzen des EPG Zeitfensters auf die Stunde gerundet, leer ohne Zeitfenster
+func windowFingerprint(from, to time.Time) string {
+
+	if from.IsZero() && to.IsZero() {
+		return ""
+	}
+
+	return fmt.Sprintf("%s-%s", from.Truncate(time.Hour).Format("2006010215"), to.Truncate(time.Hour).Format("2006010215"))
+}
+
+// channelFingerprint : Kanal und der Zustand seiner EPG Quellen
+func channelFingerprint(global string, xepgChannel XEPGChannelStruct) string {
+
+	var now = time.Now()
+	var key = global + "|" + mapToJSON(xepgChannel) + "|" + mapToJSON(channelManualPrograms(xepgChannel.XEPG))
+
+	for _, source := range epgSources(xepgChannel) {
+
+		key += "|" + source.File
+
+		if source.File == "Threadfin Dummy" {
+			continue
+		}
+
+		if index, err := getXMLTVIndex(System.Folder.Data + source.File); err == nil {
+			key += fmt.Sprintf(":%d:%d", index.Size, index.ModTime.UnixNano())
+		}
+
+		key += ":" + windowFingerprint(sourceEPGWindow(now, source.File))
+
+	}
+
+	return getMD5(key)
+}
+
+// writeXMLTV : Schreibt die Kanäle und Sendungen der aktiven XEPG Kanäle
+func writeXMLTV(xmlFile, gzFile, generator, source string) (err error) {
+
+	var imgc = Data.Cache.Images
+	var channels []XEPGChannelStruct
+
+	// Die Kanäle werden unter xepgMutex kopiert, der Zeitplan (ppv.activation) kann sie währenddessen ändern
+	xepgMutex.Lock()
+
+	var ids = make([]string, 0, len(Data.XEPG.Channels))
+	var snapshot = make(map[string]string, len(Data.XEPG.Channels))
+	for id, dxc := range Data.XEPG.Channels {
+		ids = append(ids, id)
+		snapshot[id] = mapToJSON(dxc)
+	}
+
+	xepgMutex.Unlock()
+
+	sort.Strings(ids)
+
+	for _, id := range ids {
+
+		var xepgChannel XEPGChannelStruct
+		if err := json.Unmarshal([]byte(snapshot[id]), &xepgChannel); err != nil {
+			showDebug("XEPG:"+fmt.Sprintf("Error: %s", err), 3)
+			continue
+		}
+
+		if xepgChannel.TvgName == "" {
+			xepgChannel.TvgName = xepgChannel.Name
+		}
+
+		if xepgChannel.XName == "" {
+			xepgChannel.XName = xepgChannel.TvgName
+		}
+
+		if xepgChannel.XActive && !xepgChannel.XHideChannel {
+			channels = append(channels, xepgChannel)
+		}
+
+	}
+
+	w, err := newXMLTVWriter(xmlFile, gzFile)
+	if err != nil {
+		return
+	}
+
+	var buffer bytes.Buffer
+
+	w.writeString(xml.Header)
+	w.writeString(fmt.Sprintf(`%s<tv generator-info-name="%s" source-info-name="%s">`, xmltvPrefix, xmlEscape(generator), xmlEscape(source)))
+
+	// Kanäle
+	for _, xepgChannel := range channels {
+
+		if !((Settings.XepgReplaceChannelTitle && xepgChannel.XMapping == "PPV") || xepgChannel.XName != "") {
+			continue
+		}
+
+		var channel Channel
+		channel.ID = xepgChannel.XChannelID
+		channel.Icon = Icon{Src: imgc.Image.GetURL(xepgChannel.TvgLogo, Settings.HttpThreadfinDomain, Settings.Port, Settings.ForceHttps, Settings.HttpsPort, Settings.HttpsThreadfinDomain)}
+		channel.DisplayName = append(channel.DisplayName, DisplayName{Value: xepgChannel.XName})
+		channel.Active = xepgChannel.XActive
+		channel.Live = xepgChannel.Live
+
+		buffer.Reset()
+		if err = encodeXMLTVElement(&buffer, "channel", &channel); err != nil {
+			w.err = err
+			w.close(xmlFile, gzFile)
+			return
+		}
+
+		w.write(buffer.Bytes())
+
+	}
+
+	// Sendungen, die Blöcke unveränderter Kanäle werden wiederverwendet
+	var global = xmltvFingerprint()
+	var blocks = make(map[string]xmltvBlock, len(channels))
+	var reused int
+	var stats epgPruneStats
+
+	for _, xepgChannel := range channels {
+
+		var fingerprint = channelFingerprint(global, xepgChannel)
+
+		if block, ok := Data.Cache.XMLTVBlocks[xepgChannel.XEPG]; ok && block.fingerprint == fingerprint {
+			blocks[xepgChannel.XEPG] = block
+			w.write(block.data)
+			stats.add(block.pruned)
+			reused++
+			continue
+		}
+
+		programData, _, pruned, errProgram := mergeProgramData(xepgChannel)
+		if errProgram != nil {
+			continue
+		}
+
+		stats.add(pruned)
+
+		buffer.Reset()
+
+		for _, program := range programData.Program {
+			if err = encodeXMLTVElement(&buffer, "programme", program); err != nil {
+				w.err = err
+				w.close(xmlFile, gzFile)
+				return
+			}
+		}
+
+		var block = xmltvBlock{fingerprint: fingerprint, data: append([]byte(nil), buffer.Bytes()...), pruned: pruned}
+		blocks[xepgChannel.XEPG] = block
+		w.write(block.data)
+
+	}
+
+	w.writeString("\n" + xmltvPrefix + "</tv>")
+
+	if err = w.close(xmlFile, gzFile); err != nil {
+		return
+	}
+
+	Data.Cache.XMLTVBlocks = blocks
+	showInfo("XEPG:" + fmt.Sprintf("Programmes of %d channels created, %d unchanged", len(channels)-reused, reused))
+
+	if stats.total() > 0 {
+		showInfo("XEPG:" + fmt.Sprintf("Programmes removed: %s", stats))
+	}
+
+	return
+}
+
+func xmlEscape(s string) string {
+
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+
+	return b.String()
+}
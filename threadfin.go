@@ -45,7 +45,7 @@ const Name = "Threadfin"
 const Version = "1.2.35"
 
 // DBVersion : Datanbank Version
-const DBVersion = "0.5.0"
+const DBVersion = "0.6.0"
 
 // APIVersion : API Version
 const APIVersion = "1.2.35"